@@ -0,0 +1,48 @@
+// Command spamfilter maintains the spam filter model in the forum database:
+//
+//	spamfilter learn    learn the moderator decisions made since the last run
+//	spamfilter rebuild  forget the model and learn the whole audit log again
+//
+// The database is configured as for the forum itself.
+package main
+
+import (
+	"context"
+	"fmt"
+	"forum1/db"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"forum1/internal/service"
+	"os"
+)
+
+func main() {
+	if len(os.Args) != 2 || (os.Args[1] != "learn" && os.Args[1] != "rebuild") {
+		fmt.Fprintln(os.Stderr, "usage: spamfilter learn|rebuild")
+		os.Exit(2)
+	}
+	if err := db.InitDB(); err != nil {
+		fmt.Fprintln(os.Stderr, "Ошибка подключения к базе:", err)
+		os.Exit(1)
+	}
+	defer db.CloseDB()
+
+	ctx := context.Background()
+	repo := repository.NewSpamRepository(db.GetDB())
+	spam := service.NewSpamService(repo, entity.DefaultSpamConfig())
+	learn := spam.Learn
+	if os.Args[1] == "rebuild" {
+		learn = spam.Rebuild
+	}
+	n, err := learn(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "spam filter:", err)
+		os.Exit(1)
+	}
+	m, err := repo.Model(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "spam filter:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("learned %d decisions, the model has %d spam and %d ham examples\n", n, m.SpamDocs, m.HamDocs)
+}
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 
 	_ "github.com/lib/pq"
 )
@@ -53,12 +54,21 @@ func CloseDB() {
 func runMigrations(db *sql.DB) error {
 	// Try likely migration paths depending on working directory
 	candidates := []string{
-		filepath.Join("migrations", "001_init.sql"),             // run from repo root
-		filepath.Join("..", "migrations", "001_init.sql"),       // run from cmd/forum
-		filepath.Join("..", "..", "migrations", "001_init.sql"), // run from deeper dirs
+		"migrations",                            // run from repo root
+		filepath.Join("..", "migrations"),       // run from cmd/forum
+		filepath.Join("..", "..", "migrations"), // run from deeper dirs
 	}
-	for _, path := range candidates {
-		if _, err := os.Stat(path); err == nil {
+	for _, dir := range candidates {
+		if _, err := os.Stat(filepath.Join(dir, "001_init.sql")); err != nil {
+			continue
+		}
+		// migrations are applied in file name order: 001_init.sql, 002_..., ...
+		files, err := filepath.Glob(filepath.Join(dir, "*.sql"))
+		if err != nil {
+			return fmt.Errorf("list migrations: %w", err)
+		}
+		sort.Strings(files)
+		for _, path := range files {
 			bytes, err := ioutil.ReadFile(path)
 			if err != nil {
 				return fmt.Errorf("read migration %s: %w", path, err)
@@ -66,8 +76,8 @@ func runMigrations(db *sql.DB) error {
 			if _, err := db.Exec(string(bytes)); err != nil {
 				return fmt.Errorf("apply migration %s: %w", path, err)
 			}
-			return nil
 		}
+		return nil
 	}
 	// fallback minimal ensures (valid syntax for PostgreSQL)
 	if _, err := db.Exec(`ALTER TABLE posts ADD COLUMN IF NOT EXISTS image_data BYTEA`); err != nil {
@@ -1,8 +1,10 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"forum1/db"
+	"forum1/internal/entity"
 	handler "forum1/internal/handler"
 	"forum1/internal/repository"
 	"forum1/internal/router"
@@ -28,18 +30,61 @@ func Run() {
 	postRepo := repository.NewPostRepository(database)
 	boardRepo := repository.NewBoardRepository(database)
 	commentRepo := repository.NewCommentRepository(database)
+	userRepo := repository.NewUserRepository(database)
 
 	// слой service
-	postService := service.NewPostService(postRepo)
-	boardService := service.NewBoardService(boardRepo)
-	commentService := service.NewCommentService(commentRepo)
+	moderationRepo := repository.NewModerationRepository(database)
+	notificationService := service.NewNotificationService(repository.NewNotificationRepository(database))
+	trustService := service.NewTrustService(repository.NewTrustRepository(database), entity.DefaultTrustConfig(), notificationService)
+	go trustService.RunRecalculation(context.Background(), envDurationOr("FORUM_TRUST_INTERVAL", service.DefaultTrustInterval))
+	spamService := service.NewSpamService(repository.NewSpamRepository(database), spamConfigFromEnv())
+	go func() {
+		// catch up on decisions the filter missed, e.g. while it was failing
+		if _, err := spamService.Learn(context.Background()); err != nil {
+			fmt.Println("spam filter:", err)
+		}
+	}()
+	contentRuleService := service.NewContentRuleService(repository.NewContentRuleRepository(database))
+	boardService := service.NewBoardService(boardRepo, service.WithBoardTrust(trustService))
+	postService := service.NewPostService(postRepo, service.WithHotConfig(hotConfigFromEnv()), service.WithBoardSettings(boardService),
+		service.WithPostTrust(trustService), service.WithPostSpamFilter(spamService),
+		service.WithPostContentRules(contentRuleService))
+	commentService := service.NewCommentService(commentRepo, service.WithPremoderation(boardService, postService),
+		service.WithCommentTrust(trustService), service.WithCommentSpamFilter(spamService),
+		service.WithCommentContentRules(contentRuleService))
+	feedService := service.NewFeedService(postService, repository.NewFeedRepository(database))
+	searchRepo := repository.NewSearchRepository(database)
+	savedSearchService := service.NewSavedSearchService(repository.NewSavedSearchRepository(database), searchRepo, userRepo, notificationService,
+		service.WithDigestInterval(envDurationOr("FORUM_DIGEST_INTERVAL", service.DefaultDigestInterval)))
+	go savedSearchService.RunMatcher(context.Background(), envDurationOr("FORUM_ALERT_INTERVAL", time.Minute))
 
 	// слой handler
-	userRepo := repository.NewUserRepository(database)
-	postHandler := handler.NewPostHandler(postService, userRepo)
-	commentHandler := handler.NewCommentHandler(commentService, userRepo).WithPosts(postService)
-	pageHandler := handler.NewPageHandler(postService, boardService).WithComments(commentService)
-	userHandler := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository(database)))
+	promoteAdmins(userRepo)
+	previewService := service.NewLinkPreviewService(repository.NewLinkPreviewRepository(database), nil)
+	postHandler := handler.NewPostHandler(postService).WithPreviews(previewService)
+	commentHandler := handler.NewCommentHandler(commentService).WithPosts(postService)
+	moderationService := service.NewModerationService(moderationRepo, boardService, service.WithSpamTraining(spamService))
+	moderationHandler := handler.NewModerationHandler(moderationService).WithBoards(boardService)
+	reportHandler := handler.NewReportHandler(service.NewReportService(repository.NewReportRepository(database), userRepo, notificationService))
+	sanctionService := service.NewSanctionService(repository.NewSanctionRepository(database), userRepo)
+	sanctionHandler := handler.NewSanctionHandler(sanctionService).WithBoards(boardService)
+	clubRepo := repository.NewClubRepository(database)
+	clubService := service.NewClubService(clubRepo, boardRepo, userRepo, notificationService, service.WithClubTrust(trustService))
+	eventService := service.NewEventService(repository.NewEventRepository(database), clubRepo, boardService, userRepo)
+	pageHandler := handler.NewPageHandler(postService, boardService).WithComments(commentService).WithPreviews(previewService).WithFeed(feedService).WithSavedSearches(savedSearchService).WithModeration(moderationService).WithEvents(eventService).WithTrust(trustService)
+	feedHandler := handler.NewFeedHandler(feedService, boardService)
+	clubHandler := handler.NewClubHandler(clubService)
+	clubPageHandler := handler.NewClubPageHandler(clubService).WithEvents(eventService)
+	boardHandler := handler.NewBoardHandler(boardService).WithClubs(clubService)
+	eventHandler := handler.NewEventHandler(eventService)
+	eventPageHandler := handler.NewEventPageHandler(eventService, boardService, clubService)
+	searchHandler := handler.NewSearchHandler(service.NewSearchService(searchRepo))
+	notificationHandler := handler.NewNotificationHandler(notificationService)
+	savedSearchHandler := handler.NewSavedSearchHandler(savedSearchService)
+	trustHandler := handler.NewTrustHandler(trustService)
+	contentRuleHandler := handler.NewContentRuleHandler(contentRuleService)
+	sessions := handler.NewSessions(sessionKeyFromEnv(), envDurationOr("FORUM_SESSION_TTL", handler.DefaultSessionTTL))
+	userHandler := handler.NewUserHandler(service.NewUserService(repository.NewUserRepository(database)), sessions)
 
 	// слой router
 	r := router.NewRouter(postHandler)
@@ -47,30 +92,94 @@ func Run() {
 	r.HandleFunc("/", pageHandler.HomePageHTML).Methods(http.MethodGet)
 	r.HandleFunc("/boards", pageHandler.BoardsListPage).Methods(http.MethodGet)
 	r.HandleFunc("/board/{slug}", pageHandler.BoardPage).Methods(http.MethodGet)
+	r.HandleFunc("/board/{slug}/hide", feedHandler.HideBoard).Methods(http.MethodPost)
+	r.HandleFunc("/board/{slug}/show", feedHandler.ShowBoard).Methods(http.MethodPost)
+	r.HandleFunc("/board/{slug}/modlog", moderationHandler.BoardLogPageHTML).Methods(http.MethodGet)
 	r.HandleFunc("/post/{id}", pageHandler.PostPageHTML).Methods(http.MethodGet)
 	// post image
 	r.HandleFunc("/post/{id}/image", pageHandler.PostImage).Methods(http.MethodGet)
-	// like/dislike GET endpoints
-	r.HandleFunc("/post/{id}/like", pageHandler.LikePost).Methods(http.MethodGet)
-	r.HandleFunc("/post/{id}/dislike", pageHandler.DislikePost).Methods(http.MethodGet)
-	r.HandleFunc("/comment/{id}/like", pageHandler.LikeComment).Methods(http.MethodGet)
-	r.HandleFunc("/comment/{id}/dislike", pageHandler.DislikeComment).Methods(http.MethodGet)
+	// like/dislike forms
+	r.HandleFunc("/post/{id}/like", pageHandler.LikePost).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/pin", postHandler.PinForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/unpin", postHandler.UnpinForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/lock", postHandler.LockForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/unlock", postHandler.UnlockForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/move", moderationHandler.MoveForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/merge", moderationHandler.MergeForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/split", moderationHandler.SplitForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id:[0-9]+}/delete", moderationHandler.DeletePostForm).Methods(http.MethodPost)
+	r.HandleFunc("/comment/{id:[0-9]+}/delete", moderationHandler.DeleteCommentForm).Methods(http.MethodPost)
+	r.HandleFunc("/mod/log", moderationHandler.LogPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/mod/queue", moderationHandler.QueuePageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/mod/queue/{type:post|comment}/{id:[0-9]+}/approve", moderationHandler.ApproveForm).Methods(http.MethodPost)
+	r.HandleFunc("/mod/reports", reportHandler.QueuePageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/mod/reports/{type:post|comment|user}/{id:[0-9]+}", reportHandler.ResolveForm).Methods(http.MethodPost)
+	r.HandleFunc("/mod/sanctions", sanctionHandler.ModPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/mod/sanctions", sanctionHandler.IssueForm).Methods(http.MethodPost)
+	r.HandleFunc("/mod/sanctions/{id:[0-9]+}/revoke", sanctionHandler.RevokeForm).Methods(http.MethodPost)
+	r.HandleFunc("/sanctions", sanctionHandler.OwnPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/trust", trustHandler.PageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/report", reportHandler.ReportForm).Methods(http.MethodPost)
+	r.HandleFunc("/post/{id}/dislike", pageHandler.DislikePost).Methods(http.MethodPost)
+	r.HandleFunc("/comment/{id}/like", pageHandler.LikeComment).Methods(http.MethodPost)
+	r.HandleFunc("/comment/{id}/dislike", pageHandler.DislikeComment).Methods(http.MethodPost)
 	r.HandleFunc("/profile/{id}", pageHandler.ProfilePageHTML).Methods(http.MethodGet)
 	r.HandleFunc("/login", pageHandler.LoginPageHTML).Methods(http.MethodGet)
 	r.HandleFunc("/register", pageHandler.RegisterPageHTML).Methods(http.MethodGet)
 	r.HandleFunc("/create-post", pageHandler.CreatePostPageHTML).Methods(http.MethodGet)
-	r.HandleFunc("/boards/search", pageHandler.BoardsSearchPageHTML).Methods(http.MethodGet)
-	r.HandleFunc("/search", pageHandler.SearchPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/clubs", clubPageHandler.ListPage).Methods(http.MethodGet)
+	r.HandleFunc("/clubs", clubPageHandler.CreateForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}", clubPageHandler.DetailPage).Methods(http.MethodGet)
+	r.HandleFunc("/clubs/{id:[0-9]+}/join", clubPageHandler.JoinForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/leave", clubPageHandler.LeaveForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/policy", clubPageHandler.PolicyForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/privacy", clubPageHandler.PrivacyForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/invite", clubPageHandler.InviteForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/boards", clubPageHandler.CreateBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/requests/{user_id:[0-9]+}/approve", clubPageHandler.ApproveForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/requests/{user_id:[0-9]+}/deny", clubPageHandler.DenyForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/members/{user_id:[0-9]+}/role", clubPageHandler.RoleForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/members/{user_id:[0-9]+}/kick", clubPageHandler.KickForm).Methods(http.MethodPost)
+	r.HandleFunc("/clubs/{id:[0-9]+}/events.ics", eventPageHandler.ClubFeed).Methods(http.MethodGet)
+	r.HandleFunc("/events", eventPageHandler.ListPage).Methods(http.MethodGet)
+	r.HandleFunc("/events", eventPageHandler.CreateForm).Methods(http.MethodPost)
+	r.HandleFunc("/events/new", eventPageHandler.NewPage).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id:[0-9]+}", eventPageHandler.DetailPage).Methods(http.MethodGet)
+	r.HandleFunc("/events/{id:[0-9]+}/rsvp", eventPageHandler.RSVPForm).Methods(http.MethodPost)
+	r.HandleFunc("/events/{id:[0-9]+}/delete", eventPageHandler.DeleteForm).Methods(http.MethodPost)
+	r.HandleFunc("/calendar/{token:[0-9a-f]+}.ics", eventPageHandler.UserFeed).Methods(http.MethodGet)
+	r.HandleFunc("/boards/search", searchHandler.BoardsSearchPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/search", searchHandler.SearchPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/search/save", savedSearchHandler.SaveSearch).Methods(http.MethodPost)
 	r.HandleFunc("/settings", pageHandler.SettingsPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/settings/calendar-token", eventPageHandler.ResetTokenForm).Methods(http.MethodPost)
+	r.HandleFunc("/settings/searches/{id}/mode", savedSearchHandler.SetModeForm).Methods(http.MethodPost)
+	r.HandleFunc("/settings/searches/{id}/delete", savedSearchHandler.DeleteForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards", boardHandler.AdminBoardsPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/admin/boards", boardHandler.CreateBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}", boardHandler.UpdateBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}/archive", boardHandler.ArchiveBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}/unarchive", boardHandler.UnarchiveBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}/move", boardHandler.MoveBoardForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}/settings", boardHandler.AdminBoardSettingsPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/admin/boards/{id:[0-9]+}/settings", boardHandler.UpdateSettingsForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/rules", contentRuleHandler.PageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/admin/rules", contentRuleHandler.CreateForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/rules/test", contentRuleHandler.TestForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/rules/{id:[0-9]+}/delete", contentRuleHandler.DeleteForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/categories", boardHandler.CreateCategoryForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/categories/{id:[0-9]+}", boardHandler.UpdateCategoryForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/categories/{id:[0-9]+}/delete", boardHandler.DeleteCategoryForm).Methods(http.MethodPost)
+	r.HandleFunc("/admin/categories/{id:[0-9]+}/move", boardHandler.MoveCategoryForm).Methods(http.MethodPost)
 	r.HandleFunc("/messages", pageHandler.MessagesPageHTML).Methods(http.MethodGet)
-	r.HandleFunc("/notifications", pageHandler.NotificationsPageHTML).Methods(http.MethodGet)
+	r.HandleFunc("/notifications", notificationHandler.NotificationsPageHTML).Methods(http.MethodGet)
 
 	// CORS (dev permissive)
 	r.Use(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handler.CSRFHeader)
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 			if req.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
@@ -89,6 +198,13 @@ func Run() {
 		})
 	})
 
+	// Resolve the signed in user for every request
+	r.Use(handler.Authenticate(userRepo, sessions))
+	// Refuse writes of signed in users without the token of their session
+	r.Use(handler.CheckCSRF)
+	// Keep suspended and banned users read-only, show new sanctions
+	r.Use(handler.EnforceSanctions(sanctionService))
+
 	// Swagger
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
@@ -100,7 +216,75 @@ func Run() {
 	api.HandleFunc("/register", userHandler.RegisterPage).Methods(http.MethodPost)
 	api.HandleFunc("/login", userHandler.Login).Methods(http.MethodPost)
 	api.HandleFunc("/comment", commentHandler.CreateComment).Methods(http.MethodPost)
+	api.HandleFunc("/post/{id:[0-9]+}/pin", postHandler.PinJSON).Methods(http.MethodPost, http.MethodDelete)
+	api.HandleFunc("/post/{id:[0-9]+}/lock", postHandler.LockJSON).Methods(http.MethodPost, http.MethodDelete)
+	api.HandleFunc("/post/{id:[0-9]+}/move", moderationHandler.MoveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/post/{id:[0-9]+}/merge", moderationHandler.MergeJSON).Methods(http.MethodPost)
+	api.HandleFunc("/post/{id:[0-9]+}/split", moderationHandler.SplitJSON).Methods(http.MethodPost)
+	api.HandleFunc("/mod/actions", moderationHandler.LogJSON).Methods(http.MethodGet)
+	api.HandleFunc("/mod/posts/{id:[0-9]+}", moderationHandler.DeletePostJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/mod/comments/{id:[0-9]+}", moderationHandler.DeleteCommentJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/mod/queue", moderationHandler.QueueJSON).Methods(http.MethodGet)
+	api.HandleFunc("/mod/queue/{type:post|comment}/{id:[0-9]+}/approve", moderationHandler.ApproveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/mod/reports", reportHandler.QueueJSON).Methods(http.MethodGet)
+	api.HandleFunc("/mod/reports/{type:post|comment|user}/{id:[0-9]+}", reportHandler.ResolveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/reports", reportHandler.ReportJSON).Methods(http.MethodPost)
+	api.HandleFunc("/users/{id:[0-9]+}/sanctions", sanctionHandler.ListJSON).Methods(http.MethodGet)
+	api.HandleFunc("/users/{id:[0-9]+}/trust", trustHandler.ProgressJSON).Methods(http.MethodGet)
+	api.HandleFunc("/users/{id:[0-9]+}/sanctions", sanctionHandler.IssueJSON).Methods(http.MethodPost)
+	api.HandleFunc("/sanctions/{id:[0-9]+}", sanctionHandler.RevokeJSON).Methods(http.MethodDelete)
 	api.HandleFunc("/delete_comment", commentHandler.DeleteComment).Methods(http.MethodPost)
+	api.HandleFunc("/search", searchHandler.SearchJSON).Methods(http.MethodGet)
+	api.HandleFunc("/search/suggest", searchHandler.SuggestJSON).Methods(http.MethodGet)
+	api.HandleFunc("/clubs", clubHandler.List).Methods(http.MethodGet)
+	api.HandleFunc("/clubs", clubHandler.Create).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}", clubHandler.GetByID).Methods(http.MethodGet)
+	api.HandleFunc("/clubs/{id:[0-9]+}/members", clubHandler.MembersJSON).Methods(http.MethodGet)
+	api.HandleFunc("/clubs/{id:[0-9]+}/members/{user_id:[0-9]+}", clubHandler.SetRoleJSON).Methods(http.MethodPut)
+	api.HandleFunc("/clubs/{id:[0-9]+}/members/{user_id:[0-9]+}", clubHandler.KickJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/clubs/{id:[0-9]+}/join", clubHandler.JoinJSON).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}/leave", clubHandler.LeaveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}/policy", clubHandler.SetPolicyJSON).Methods(http.MethodPut)
+	api.HandleFunc("/clubs/{id:[0-9]+}/privacy", clubHandler.SetPrivacyJSON).Methods(http.MethodPut)
+	api.HandleFunc("/clubs/{id:[0-9]+}/requests", clubHandler.RequestsJSON).Methods(http.MethodGet)
+	api.HandleFunc("/clubs/{id:[0-9]+}/requests/{user_id:[0-9]+}/approve", clubHandler.ApproveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}/requests/{user_id:[0-9]+}/deny", clubHandler.DenyJSON).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}/invites", clubHandler.InviteJSON).Methods(http.MethodPost)
+	api.HandleFunc("/clubs/{id:[0-9]+}/boards", clubHandler.CreateBoardJSON).Methods(http.MethodPost)
+	api.HandleFunc("/events", eventHandler.ListJSON).Methods(http.MethodGet)
+	api.HandleFunc("/events", eventHandler.CreateJSON).Methods(http.MethodPost)
+	api.HandleFunc("/events/{id:[0-9]+}", eventHandler.GetJSON).Methods(http.MethodGet)
+	api.HandleFunc("/events/{id:[0-9]+}", eventHandler.DeleteJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/events/{id:[0-9]+}/rsvp", eventHandler.RSVPJSON).Methods(http.MethodPut)
+	api.HandleFunc("/events/{id:[0-9]+}/rsvps", eventHandler.RSVPsJSON).Methods(http.MethodGet)
+	api.HandleFunc("/boards", boardHandler.ListJSON).Methods(http.MethodGet)
+	api.HandleFunc("/boards", boardHandler.CreateJSON).Methods(http.MethodPost)
+	api.HandleFunc("/boards/order", boardHandler.ReorderJSON).Methods(http.MethodPut)
+	api.HandleFunc("/boards/directory", boardHandler.DirectoryJSON).Methods(http.MethodGet)
+	api.HandleFunc("/boards/{id:[0-9]+}", boardHandler.UpdateJSON).Methods(http.MethodPut)
+	api.HandleFunc("/boards/{id:[0-9]+}/settings", boardHandler.UpdateSettingsJSON).Methods(http.MethodPut)
+	api.HandleFunc("/boards/{id:[0-9]+}/archive", boardHandler.ArchiveJSON).Methods(http.MethodPost)
+	api.HandleFunc("/boards/{id:[0-9]+}/archive", boardHandler.UnarchiveJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/boards/{id:[0-9]+}/modlog", moderationHandler.BoardLogJSON).Methods(http.MethodGet)
+	api.HandleFunc("/admin/rules", contentRuleHandler.ListJSON).Methods(http.MethodGet)
+	api.HandleFunc("/admin/rules", contentRuleHandler.CreateJSON).Methods(http.MethodPost)
+	api.HandleFunc("/admin/rules/test", contentRuleHandler.TestJSON).Methods(http.MethodPost)
+	api.HandleFunc("/admin/rules/{id:[0-9]+}", contentRuleHandler.DeleteJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/board-categories", boardHandler.CategoriesJSON).Methods(http.MethodGet)
+	api.HandleFunc("/board-categories", boardHandler.CreateCategoryJSON).Methods(http.MethodPost)
+	api.HandleFunc("/board-categories/order", boardHandler.ReorderCategoriesJSON).Methods(http.MethodPut)
+	api.HandleFunc("/board-categories/{id:[0-9]+}", boardHandler.UpdateCategoryJSON).Methods(http.MethodPut)
+	api.HandleFunc("/board-categories/{id:[0-9]+}", boardHandler.DeleteCategoryJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/notifications", notificationHandler.NotificationsJSON).Methods(http.MethodGet)
+	api.HandleFunc("/notifications/read", notificationHandler.MarkAllRead).Methods(http.MethodPost)
+	api.HandleFunc("/saved-searches", savedSearchHandler.ListJSON).Methods(http.MethodGet)
+	api.HandleFunc("/saved-searches", savedSearchHandler.CreateJSON).Methods(http.MethodPost)
+	api.HandleFunc("/saved-searches/{id}", savedSearchHandler.UpdateJSON).Methods(http.MethodPut)
+	api.HandleFunc("/saved-searches/{id}", savedSearchHandler.DeleteJSON).Methods(http.MethodDelete)
+	api.HandleFunc("/feed", feedHandler.FeedJSON).Methods(http.MethodGet)
+	api.HandleFunc("/feed/exclusions", feedHandler.ExclusionsJSON).Methods(http.MethodGet)
+	api.HandleFunc("/feed/exclusions", feedHandler.AddExclusion).Methods(http.MethodPost)
+	api.HandleFunc("/feed/exclusions/{board_id}", feedHandler.RemoveExclusion).Methods(http.MethodDelete)
 
 	fmt.Println("Server is running on http://localhost:8080")
 	http.ListenAndServe(":8080", r)
@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hotConfigFromEnv reads the front page ranking from FORUM_HOT_* variables,
+// keeping the defaults for unset or malformed ones
+func hotConfigFromEnv() entity.HotConfig {
+	cfg := entity.DefaultHotConfig()
+	envFloat("FORUM_HOT_GRAVITY", &cfg.Gravity)
+	envFloat("FORUM_HOT_VOTE_WEIGHT", &cfg.VoteWeight)
+	envFloat("FORUM_HOT_COMMENT_WEIGHT", &cfg.CommentWeight)
+	envDuration("FORUM_HOT_VELOCITY_WINDOW", &cfg.VelocityWindow)
+	envDuration("FORUM_HOT_MAX_AGE", &cfg.MaxAge)
+	return cfg
+}
+
+func spamConfigFromEnv() entity.SpamConfig {
+	cfg := entity.DefaultSpamConfig()
+	envFloat("FORUM_SPAM_THRESHOLD", &cfg.Threshold)
+	return cfg
+}
+
+func envFloat(key string, dst *float64) {
+	if v, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		*dst = v
+	}
+}
+
+func envDuration(key string, dst *time.Duration) {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		*dst = v
+	}
+}
+
+// envDurationOr returns the duration in key, or def if unset or malformed
+func envDurationOr(key string, def time.Duration) time.Duration {
+	envDuration(key, &def)
+	return def
+}
+
+// promoteAdmins gives the admin role to the comma separated usernames in
+// FORUM_ADMINS; that's how the first admin of a fresh install is made.
+func promoteAdmins(users repository.UserRepository) {
+	for _, name := range strings.Split(os.Getenv("FORUM_ADMINS"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if err := users.SetRole(context.Background(), name, entity.RoleAdmin); err != nil {
+			fmt.Println("FORUM_ADMINS:", name, err)
+		}
+	}
+}
+
+// sessionKeyFromEnv returns the key that signs session cookies from
+// FORUM_SESSION_KEY. Without it a random key is made, and everyone is signed
+// out when the server restarts.
+func sessionKeyFromEnv() []byte {
+	if key := os.Getenv("FORUM_SESSION_KEY"); key != "" {
+		return []byte(key)
+	}
+	fmt.Println("FORUM_SESSION_KEY is not set, sessions end when the server restarts")
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
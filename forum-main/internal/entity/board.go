@@ -1,8 +1,77 @@
 package entity
 
+import "time"
+
 type Board struct {
 	ID          int64  `json:"id"`
 	Slug        string `json:"slug"`
 	Title       string `json:"title"`
 	Description string `json:"description"`
+	Position    int    `json:"position"`
+	Archived    bool   `json:"archived"`
+	CategoryID  int64  `json:"category_id,omitempty"`
+	ParentID    int64  `json:"parent_id,omitempty"` // 0 for top level boards
+	ClubID      int64  `json:"club_id,omitempty"`
+
+	Settings BoardSettings `json:"settings"`
+}
+
+// Who may start posts in a board
+const (
+	PostEveryone   = "everyone"
+	PostMembers    = "members" // members of the board's club
+	PostModerators = "moderators"
+)
+
+// BoardSettings are the posting rules of a board
+type BoardSettings struct {
+	Rules             string `json:"rules"` // markdown, shown before posting
+	PostPermission    string `json:"post_permission"`
+	AllowImages       bool   `json:"allow_images"`
+	AllowLinks        bool   `json:"allow_links"`
+	MinAccountAgeDays int    `json:"min_account_age_days"`
+	PostTemplate      string `json:"post_template"` // prefills new posts
+	// PublicModLog publishes a redacted log of the moderation in the board
+	PublicModLog bool `json:"public_mod_log"`
+	// Pre-moderation, 0 turns a threshold off: posts and comments of
+	// accounts younger than PremodAccountAgeDays or with fewer than
+	// PremodMinApproved published posts and comments wait for a moderator
+	PremodAccountAgeDays int `json:"premod_account_age_days"`
+	PremodMinApproved    int `json:"premod_min_approved"`
+}
+
+type BoardCategory struct {
+	ID       int64  `json:"id"`
+	Title    string `json:"title"`
+	Position int    `json:"position"`
+}
+
+// BoardStats is the activity of a board; the times are zero for an empty
+// board.
+type BoardStats struct {
+	Posts          int       `json:"posts"`
+	LastPostID     int64     `json:"last_post_id,omitempty"`
+	LastPostTitle  string    `json:"last_post_title,omitempty"`
+	LastPostAt     time.Time `json:"last_post_at,omitzero"`
+	LastActivityAt time.Time `json:"last_activity_at,omitzero"`
+}
+
+// BoardNode is a board of the board directory with its sub-boards
+type BoardNode struct {
+	Board
+	Stats    BoardStats  `json:"stats"`
+	Children []BoardNode `json:"children,omitempty"`
+}
+
+// CategoryBoards is a category of the board directory. Boards without a
+// category are listed last under a category with ID 0.
+type CategoryBoards struct {
+	BoardCategory
+	Boards []BoardNode `json:"boards"`
+}
+
+// Breadcrumb is a step of the navigation path above a page
+type Breadcrumb struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
 }
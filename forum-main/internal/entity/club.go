@@ -1,8 +1,61 @@
 package entity
 
+import "time"
+
+// Club roles, from the highest
+const (
+	ClubRoleOwner   = "owner"
+	ClubRoleOfficer = "officer" // manages requests, invites and members
+	ClubRoleMember  = "member"
+)
+
+var clubRoleRank = map[string]int{ClubRoleMember: 1, ClubRoleOfficer: 2, ClubRoleOwner: 3}
+
+// ClubRoleAtLeast reports whether role is role min or higher; "" is no role
+func ClubRoleAtLeast(role, min string) bool {
+	return clubRoleRank[role] > 0 && clubRoleRank[role] >= clubRoleRank[min]
+}
+
+// How users join a club
+const (
+	JoinOpen     = "open"     // anyone joins at once
+	JoinApproval = "approval" // officers approve join requests
+	JoinInvite   = "invite"   // only invited users join
+)
+
+// Kinds of pending club requests
+const (
+	ClubRequestJoin   = "request"
+	ClubRequestInvite = "invite"
+)
+
 type Club struct {
 	ID          int64  `json:"id"`
 	Name        string `json:"name"`
 	Topic       string `json:"topic"`
 	Description string `json:"description"`
+	JoinPolicy  string `json:"join_policy"`
+	// Private clubs show their boards, posts and comments to members only
+	Private     bool `json:"private"`
+	MemberCount int  `json:"member_count"`
+
+	// Boards linked to the club by boards.club_id, filled on the club page
+	Boards []Board `json:"boards,omitempty"`
+}
+
+type ClubMember struct {
+	UserID   int64     `json:"user_id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+// ClubRequest is a pending join request or invitation
+type ClubRequest struct {
+	ClubID    int64     `json:"club_id"`
+	ClubName  string    `json:"club_name"`
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
 }
@@ -11,4 +11,7 @@ type Comment struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Likes     int       `json:"likes"`
 	Dislikes  int       `json:"dislikes"`
+	// Pending comments wait for a moderator, see Post.Pending
+	Pending    bool   `json:"pending,omitempty"`
+	HoldReason string `json:"hold_reason,omitempty"`
 }
@@ -0,0 +1,42 @@
+package entity
+
+import "time"
+
+// Kinds of content rules
+const (
+	RuleBlock    = "block"    // banned word: the post or comment is refused
+	RuleCensor   = "censor"   // the word is replaced with the rule's replacement
+	RuleModerate = "moderate" // the post or comment is held for review
+	// links must go to one of the allowed domains once there is any
+	RuleAllowDomain = "allow_domain"
+	RuleDenyDomain  = "deny_domain" // links to the domain are refused
+)
+
+// ContentRuleKinds are all the kinds of content rules
+var ContentRuleKinds = []string{RuleBlock, RuleCensor, RuleModerate, RuleAllowDomain, RuleDenyDomain}
+
+// ContentRule is an admin rule applied to posts and comments on save.
+// Patterns of word rules match whole words and phrases case-insensitively,
+// regex patterns are RE2 expressions; domain rules also cover subdomains.
+type ContentRule struct {
+	ID          int64     `json:"id"`
+	Kind        string    `json:"kind"`
+	Pattern     string    `json:"pattern"`
+	Regex       bool      `json:"regex"`
+	Replacement string    `json:"replacement,omitempty"` // for censor rules
+	Note        string    `json:"note,omitempty"`
+	CreatedBy   int64     `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ContentRuleResult is what the rules make of a post or comment: the
+// censored text, the rules that refuse it and those that hold it
+type ContentRuleResult struct {
+	Title    string        `json:"title,omitempty"`
+	Content  string        `json:"content"`
+	Censored bool          `json:"censored"`
+	Blocked  []ContentRule `json:"blocked,omitempty"`
+	Held     []ContentRule `json:"held,omitempty"`
+	// DeniedDomains are the link domains refused by the domain rules
+	DeniedDomains []string `json:"denied_domains,omitempty"`
+}
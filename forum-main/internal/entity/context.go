@@ -0,0 +1,18 @@
+package entity
+
+import "context"
+
+type ctxKey int
+
+const userCtxKey ctxKey = iota
+
+// ContextWithUser stores the signed in user for the rest of the request.
+func ContextWithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, userCtxKey, u)
+}
+
+// UserFromContext returns the signed in user, or nil for guests.
+func UserFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(userCtxKey).(*User)
+	return u
+}
@@ -0,0 +1,65 @@
+package entity
+
+import "time"
+
+// RSVP answers
+const (
+	RSVPGoing = "going"
+	RSVPMaybe = "maybe"
+	RSVPNo    = "no"
+)
+
+// Event belongs to a club or to a board. StartsAt and EndsAt are instants,
+// Timezone is the IANA zone the event is held in: recurrences keep its
+// wall clock time across DST changes.
+type Event struct {
+	ID          int64     `json:"id"`
+	ClubID      int64     `json:"club_id,omitempty"`
+	BoardID     int64     `json:"board_id,omitempty"`
+	AuthorID    int64     `json:"author_id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	StartsAt    time.Time `json:"starts_at"`
+	EndsAt      time.Time `json:"ends_at"`
+	Timezone    string    `json:"timezone"`
+	Location    string    `json:"location"`
+	// RRule is an iCalendar recurrence rule without the "RRULE:" prefix,
+	// empty for one-off events
+	RRule     string    `json:"rrule,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Owner names, RSVP counts and the viewer's answer, filled on reads
+	ClubName   string `json:"club_name,omitempty"`
+	BoardSlug  string `json:"board_slug,omitempty"`
+	BoardTitle string `json:"board_title,omitempty"`
+	Going      int    `json:"going"`
+	Maybe      int    `json:"maybe"`
+	MyRSVP     string `json:"my_rsvp,omitempty"`
+}
+
+// EventOccurrence is one occurrence of a possibly recurring event
+type EventOccurrence struct {
+	Event
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// EventFilter selects events; zero fields don't filter
+type EventFilter struct {
+	ClubID  int64
+	BoardID int64
+	// UserID selects the events of the user's clubs and the events they
+	// answered going or maybe to
+	UserID int64
+	// Until drops events starting at or after it, Since one-off events
+	// that ended before it
+	Since time.Time
+	Until time.Time
+}
+
+type EventRSVP struct {
+	UserID    int64     `json:"user_id"`
+	Username  string    `json:"username"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
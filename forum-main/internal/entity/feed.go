@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// HotConfig tunes the "hot" ranking of the front page feed:
+//
+//	score = (VoteWeight*net votes + CommentWeight*comments in the last VelocityWindow + 1)
+//	        / (age in hours + 2) ^ Gravity
+//
+// Posts older than MaxAge are not ranked at all.
+type HotConfig struct {
+	Gravity        float64
+	VoteWeight     float64
+	CommentWeight  float64
+	VelocityWindow time.Duration
+	MaxAge         time.Duration
+}
+
+func DefaultHotConfig() HotConfig {
+	return HotConfig{
+		Gravity:        1.8,
+		VoteWeight:     1,
+		CommentWeight:  0.5,
+		VelocityWindow: 6 * time.Hour,
+		MaxAge:         14 * 24 * time.Hour,
+	}
+}
@@ -0,0 +1,14 @@
+package entity
+
+import "time"
+
+// ImageVariant is a post image served over HTTP: either the original upload
+// (Width == 0) or a resized copy of it.
+type ImageVariant struct {
+	PostID      int64     `json:"post_id"`
+	Width       int       `json:"width"`
+	ContentType string    `json:"content_type"`
+	Data        []byte    `json:"-"`
+	ModTime     time.Time `json:"mod_time"`
+	Hash        string    `json:"hash,omitempty"` // SHA-256 of the original, stored when it is written
+}
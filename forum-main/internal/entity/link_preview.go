@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// LinkPreview is the unfurled metadata of an external link. Failed previews
+// are cached too (with empty fields) so broken links are not refetched on
+// every page view.
+type LinkPreview struct {
+	URL         string    `json:"url"`
+	Title       string    `json:"title"`
+	Description string    `json:"description,omitempty"`
+	ImageURL    string    `json:"image_url,omitempty"`
+	SiteName    string    `json:"site_name,omitempty"`
+	Failed      bool      `json:"-"`
+	FetchedAt   time.Time `json:"fetched_at"`
+}
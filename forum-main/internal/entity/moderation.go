@@ -0,0 +1,105 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Moderator and admin actions recorded in the audit log
+const (
+	ModMovePost   = "move_post"   // TargetID is the new board
+	ModMergePosts = "merge_posts" // TargetID is the post merged into
+	ModSplitPost  = "split_post"  // TargetID is the new post
+	// TargetID is the reported post, comment or user, details hold the action
+	ModResolveReports = "resolve_reports"
+	// TargetID is the sanctioned user, details hold the sanction
+	ModIssueSanction  = "issue_sanction"
+	ModRevokeSanction = "revoke_sanction"
+	// the snapshot holds the deleted post or comment
+	ModDeletePost    = "delete_post"
+	ModDeleteComment = "delete_comment"
+	ModPinPost       = "pin_post"
+	ModUnpinPost     = "unpin_post"
+	ModLockPost      = "lock_post"
+	ModUnlockPost    = "unlock_post"
+	// held content published by a moderator, details hold the hold reason
+	ModApprovePost    = "approve_post"
+	ModApproveComment = "approve_comment"
+	// board administration, the snapshot holds the board or category as it
+	// was before
+	ModCreateBoard       = "create_board"
+	ModUpdateBoard       = "update_board"
+	ModArchiveBoard      = "archive_board"
+	ModUnarchiveBoard    = "unarchive_board"
+	ModBoardSettings     = "board_settings"
+	ModReorderBoards     = "reorder_boards"
+	ModCreateCategory    = "create_category"
+	ModUpdateCategory    = "update_category"
+	ModDeleteCategory    = "delete_category"
+	ModReorderCategories = "reorder_categories"
+	// content rules, details hold the new rule and the snapshot the
+	// deleted one
+	ModCreateContentRule = "create_content_rule"
+	ModDeleteContentRule = "delete_content_rule"
+)
+
+// ModActions are all the actions of the audit log
+var ModActions = []string{
+	ModDeletePost, ModDeleteComment, ModApprovePost, ModApproveComment, ModPinPost, ModUnpinPost, ModLockPost, ModUnlockPost,
+	ModMovePost, ModMergePosts, ModSplitPost, ModResolveReports, ModIssueSanction, ModRevokeSanction,
+	ModCreateBoard, ModUpdateBoard, ModArchiveBoard, ModUnarchiveBoard, ModBoardSettings, ModReorderBoards,
+	ModCreateCategory, ModUpdateCategory, ModDeleteCategory, ModReorderCategories,
+	ModCreateContentRule, ModDeleteContentRule,
+}
+
+// What an audit log entry is about
+const (
+	TargetPost     = "post"
+	TargetComment  = "comment"
+	TargetUser     = "user"
+	TargetBoard    = "board"
+	TargetCategory = "category"
+	TargetRule     = "content_rule"
+)
+
+// ModAction is an audit log entry
+type ModAction struct {
+	ID            int64  `json:"id"`
+	ModeratorID   int64  `json:"moderator_id,omitempty"`
+	ModeratorName string `json:"moderator_name,omitempty"`
+	Action        string `json:"action"`
+	TargetType    string `json:"target_type,omitempty"`
+	PostID        int64  `json:"post_id,omitempty"`
+	TargetID      int64  `json:"target_id,omitempty"`
+	BoardID       int64  `json:"board_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+	// Snapshot is the target as it was before the action
+	Snapshot  json.RawMessage `json:"snapshot,omitempty"`
+	Details   json.RawMessage `json:"details,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// ModActionFilter selects audit log entries, zero fields match everything
+type ModActionFilter struct {
+	Action      string
+	TargetType  string
+	ModeratorID int64
+	BoardID     int64
+	Limit       int
+	Offset      int
+}
+
+// HeldItem is a post or comment waiting in the pre-moderation queue
+type HeldItem struct {
+	TargetType string    `json:"target_type"` // TargetPost or TargetComment
+	ID         int64     `json:"id"`
+	PostID     int64     `json:"post_id"` // the post itself, or the post of the comment
+	PostTitle  string    `json:"post_title"`
+	BoardID    int64     `json:"board_id"`
+	BoardTitle string    `json:"board_title"`
+	AuthorID   int64     `json:"author_id"`
+	AuthorName string    `json:"author_name"`
+	Content    string    `json:"content"`
+	HoldReason string    `json:"hold_reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
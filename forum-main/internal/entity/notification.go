@@ -0,0 +1,22 @@
+package entity
+
+import "time"
+
+// Notification kinds
+const (
+	NotificationSavedSearch = "saved_search"
+	NotificationClubInvite  = "club_invite"
+	NotificationClubJoined  = "club_joined" // a join request was approved
+	NotificationReport      = "report"      // a report of the user was resolved
+	NotificationTrustLevel  = "trust_level" // the user was promoted
+)
+
+type Notification struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"user_id"`
+	Kind      string     `json:"kind"`
+	Title     string     `json:"title"`
+	URL       string     `json:"url"`
+	ReadAt    *time.Time `json:"read_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
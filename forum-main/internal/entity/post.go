@@ -3,17 +3,30 @@ package entity
 import "time"
 
 type Post struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	BoardID   int       `json:"board_id"`
-	Content   string    `json:"content"`
-	AuthorID  int       `json:"author_id"`
-	ImageURL  string    `json:"image_url,omitempty"`
-	LinkURL   string    `json:"link_url,omitempty"`
-	ImageData []byte    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Likes     int       `json:"likes"`
-	Dislikes  int       `json:"dislikes"`
-	Comments  []Comment `json:"comments,omitempty"`
+	ID             int       `json:"id"`
+	Title          string    `json:"title"`
+	BoardID        int       `json:"board_id"`
+	Content        string    `json:"content"`
+	AuthorID       int       `json:"author_id"`
+	ImageURL       string    `json:"image_url,omitempty"`
+	LinkURL        string    `json:"link_url,omitempty"`
+	ImageData      []byte    `json:"-"`
+	ImageWidth     int       `json:"image_width,omitempty"`
+	ImageHeight    int       `json:"image_height,omitempty"`
+	HasImage       bool      `json:"has_image,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Likes          int       `json:"likes"`
+	Dislikes       int       `json:"dislikes"`
+	CommentCount   int       `json:"comment_count"`
+	LastActivityAt time.Time `json:"last_activity_at,omitzero"`
+	Pinned         bool      `json:"pinned,omitempty"`
+	Locked         bool      `json:"locked,omitempty"` // no new comments or votes
+	// Pending posts wait for a moderator, only the author and moderators
+	// see them; HoldReason tells why the post was held
+	Pending    bool   `json:"pending,omitempty"`
+	HoldReason string `json:"hold_reason,omitempty"`
+
+	Comments    []Comment    `json:"comments,omitempty"`
+	LinkPreview *LinkPreview `json:"link_preview,omitempty"`
 }
@@ -0,0 +1,41 @@
+package entity
+
+// Sort modes for post listings
+const (
+	SortNew      = "new"
+	SortOld      = "old"
+	SortTop      = "top"
+	SortComments = "comments"
+	SortActive   = "active"
+	SortHot      = "hot"
+)
+
+// Time windows for SortTop
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+	PeriodAll   = "all"
+)
+
+// PostListOptions selects one page of a post listing. Cursor is the opaque
+// NextCursor of the previous page, empty for the first one.
+type PostListOptions struct {
+	BoardID         int64
+	ExcludeBoardIDs []int64
+	// Board pages show pinned posts above the listing, apart from paging
+	OnlyPinned bool
+	SkipPinned bool
+	Sort       string
+	Period     string
+	Cursor     string
+	Limit      int
+
+	// Hot is the ranking used by SortHot, filled in by the post service
+	Hot HotConfig
+}
+
+type PostPage struct {
+	Posts      []Post `json:"posts"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,104 @@
+package entity
+
+import "time"
+
+// What can be reported
+const (
+	ReportPost    = "post"
+	ReportComment = "comment"
+	ReportUser    = "user"
+)
+
+// Report reasons
+const (
+	ReasonSpam     = "spam"
+	ReasonAbuse    = "abuse"
+	ReasonOfftopic = "offtopic"
+	ReasonIllegal  = "illegal"
+	ReasonOther    = "other"
+)
+
+var ReportReasons = []string{ReasonSpam, ReasonAbuse, ReasonOfftopic, ReasonIllegal, ReasonOther}
+
+// Report statuses
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// Moderator actions on reported content; all of them close the open
+// reports of the target
+const (
+	ResolveDismiss = "dismiss"
+	ResolveDelete  = "delete" // posts and comments only
+	ResolveWarn    = "warn"   // warns the author
+	ResolveBan     = "ban"    // bans the author
+)
+
+type Report struct {
+	ID           int64      `json:"id"`
+	ReporterID   int64      `json:"reporter_id"`
+	ReporterName string     `json:"reporter_name"`
+	TargetType   string     `json:"target_type"`
+	TargetID     int64      `json:"target_id"`
+	Reason       string     `json:"reason"`
+	Details      string     `json:"details"`
+	Status       string     `json:"status"`
+	Resolution   string     `json:"resolution,omitempty"`
+	ResolvedBy   int64      `json:"resolved_by,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// ReportTarget describes reported content for moderators
+type ReportTarget struct {
+	Type       string `json:"type"`
+	ID         int64  `json:"id"`
+	AuthorID   int64  `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	// Excerpt is the post title, the start of the comment or the user name
+	Excerpt string `json:"excerpt"`
+	URL     string `json:"url"`
+	// Deleted targets can only be dismissed
+	Deleted bool `json:"deleted"`
+}
+
+// ReportGroup is the open reports of one target in the moderation queue
+type ReportGroup struct {
+	Target  ReportTarget   `json:"target"`
+	Reports []Report       `json:"reports"`
+	Reasons map[string]int `json:"reasons"`
+}
+
+// Sanction kinds. Warnings only inform the user, suspensions and bans keep
+// them from writing anywhere, board bans from writing in one board.
+const (
+	SanctionWarning    = "warning"
+	SanctionSuspension = "suspension" // always has an end
+	SanctionBan        = "ban"
+	SanctionBoardBan   = "board_ban"
+)
+
+var SanctionKinds = []string{SanctionWarning, SanctionSuspension, SanctionBan, SanctionBoardBan}
+
+type Sanction struct {
+	ID            int64  `json:"id"`
+	UserID        int64  `json:"user_id"`
+	Kind          string `json:"kind"`
+	Reason        string `json:"reason"`
+	ModeratorID   int64  `json:"moderator_id"`
+	ModeratorName string `json:"moderator_name"`
+	BoardID       int64  `json:"board_id,omitempty"`
+	BoardTitle    string `json:"board_title,omitempty"`
+	// EndsAt is nil for sanctions without an end
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+	SeenAt    *time.Time `json:"seen_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// Active reports whether s is in force at now
+func (s Sanction) Active(now time.Time) bool {
+	return s.RevokedAt == nil && (s.EndsAt == nil || s.EndsAt.After(now))
+}
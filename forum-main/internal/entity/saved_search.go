@@ -0,0 +1,20 @@
+package entity
+
+import "time"
+
+// Alert modes of a saved search: a notification per match, or one summary
+// notification per digest interval.
+const (
+	AlertInstant = "instant"
+	AlertDigest  = "digest"
+)
+
+type SavedSearch struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	Query     string    `json:"query"`
+	Mode      string    `json:"mode"`
+	LastSeq   int64     `json:"-"` // publication number matched up to
+	LastRunAt time.Time `json:"last_run_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
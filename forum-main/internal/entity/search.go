@@ -0,0 +1,126 @@
+package entity
+
+import (
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// Result types of a search
+const (
+	SearchPosts    = "posts"
+	SearchComments = "comments"
+	SearchBoards   = "boards"
+	SearchUsers    = "users"
+)
+
+// SearchQuery is a search request; Page starts at 1. Text is the raw user
+// query, the other fields are what the search service parsed out of it.
+type SearchQuery struct {
+	Text  string
+	Type  string // SearchPosts, SearchComments, SearchBoards, SearchUsers or "" for all
+	Page  int
+	Limit int
+
+	Terms    []string  // plain words
+	Phrases  []string  // "quoted phrases"
+	Excluded []string  // -word and -"phrase"
+	Board    string    // board:slug
+	Author   string    // author:username
+	Tags     []string  // tag:go, matches #go in posts
+	Before   time.Time // before:2026-01-01, posts created before that day
+	After    time.Time // after:2026-01-01, posts created after that day
+	HasImage bool      // has:image
+	HasLink  bool      // has:link
+
+	Filters []SearchFilter
+
+	// Set by the saved search matcher, never parsed: only documents
+	// published with AfterSeq < published_seq <= UpToSeq not written by
+	// ExcludeAuthorID.
+	AfterSeq, UpToSeq int64
+	ExcludeAuthorID   int64
+}
+
+// HasText reports whether the query has words or phrases to match, as
+// opposed to filters only.
+func (q SearchQuery) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// SearchFilter is an active filter of a query, shown as a removable chip.
+type SearchFilter struct {
+	Label  string `json:"label"`
+	Remove string `json:"remove"` // the query without this filter
+}
+
+// PostHit is a post matching a search. Title and Snippet are HTML with the
+// matched terms wrapped in <mark>, everything else escaped.
+type PostHit struct {
+	ID         int64         `json:"id"`
+	Seq        int64         `json:"-"` // publication number
+	BoardID    int64         `json:"board_id"`
+	BoardSlug  string        `json:"board_slug"`
+	BoardTitle string        `json:"board_title"`
+	AuthorID   int64         `json:"author_id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Rank       float64       `json:"rank"`
+	Title      template.HTML `json:"title"`
+	Snippet    template.HTML `json:"snippet"`
+}
+
+// CommentHit is a comment matching a search, Snippet is highlighted like
+// in PostHit.
+type CommentHit struct {
+	ID         int64         `json:"id"`
+	Seq        int64         `json:"-"` // publication number
+	PostID     int64         `json:"post_id"`
+	PostTitle  string        `json:"post_title"`
+	AuthorID   int64         `json:"author_id"`
+	AuthorName string        `json:"author_name"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Rank       float64       `json:"rank"`
+	Snippet    template.HTML `json:"snippet"`
+}
+
+// URL links to the comment anchor on its post page
+func (h CommentHit) URL() string {
+	return fmt.Sprintf("/post/%d#comment-%d", h.PostID, h.ID)
+}
+
+type UserHit struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchCounts is the total number of matches of every result type
+type SearchCounts struct {
+	Posts    int `json:"posts"`
+	Comments int `json:"comments"`
+	Boards   int `json:"boards"`
+	Users    int `json:"users"`
+}
+
+// SearchResults groups the matches by type. Without a Type in the query
+// every group holds its first few matches; with a Type only that group is
+// filled, a page at a time.
+type SearchResults struct {
+	Query    string         `json:"query"`
+	Type     string         `json:"type"`
+	Filters  []SearchFilter `json:"filters"`
+	Counts   SearchCounts   `json:"counts"`
+	Posts    []PostHit      `json:"posts"`
+	Comments []CommentHit   `json:"comments"`
+	Boards   []Board        `json:"boards"`
+	Users    []UserHit      `json:"users"`
+	Page     int            `json:"page"`
+	HasNext  bool           `json:"has_next"`
+}
+
+// Suggestion is a typeahead entry of the search box
+type Suggestion struct {
+	Type  string `json:"type"`
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// SpamDoc is the text of a post or comment as the spam filter sees it, the
+// fields match the audit log snapshots
+type SpamDoc struct {
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	LinkURL string `json:"link_url"`
+}
+
+// SpamExample is a moderator decision the spam filter learns from:
+// approving held content makes it ham, deleting it as spam makes it spam
+type SpamExample struct {
+	ActionID   int64 // the audit log entry of the decision
+	TargetType string
+	TargetID   int64
+	Spam       bool
+	Doc        SpamDoc
+}
+
+// SpamModel is the size of the training set of the spam filter
+type SpamModel struct {
+	SpamDocs     int       `json:"spam_docs"`
+	HamDocs      int       `json:"ham_docs"`
+	LastActionID int64     `json:"last_action_id"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SpamTokenCount is in how many spam and ham examples a token was seen
+type SpamTokenCount struct {
+	Spam int
+	Ham  int
+}
+
+// SpamConfig tunes the spam filter
+type SpamConfig struct {
+	// Threshold is the score from which new content is held for review
+	Threshold float64
+	// MinExamples of each kind are needed before the filter holds anything
+	MinExamples int
+	// Interesting is how many of the most telling tokens make the score
+	Interesting int
+}
+
+func DefaultSpamConfig() SpamConfig {
+	return SpamConfig{Threshold: 0.9, MinExamples: 20, Interesting: 15}
+}
@@ -0,0 +1,108 @@
+package entity
+
+import "time"
+
+// Trust levels, each one includes the capabilities of the previous ones
+const (
+	TrustNew = iota
+	TrustBasic
+	TrustMember
+	TrustRegular
+	TrustLeader
+)
+
+// TrustLevelNames are the names of the trust levels by level
+var TrustLevelNames = []string{"new", "basic", "member", "regular", "leader"}
+
+// TrustLevelName is the name of a trust level, "new" for unknown ones
+func TrustLevelName(level int) string {
+	if level < 0 || level >= len(TrustLevelNames) {
+		return TrustLevelNames[TrustNew]
+	}
+	return TrustLevelNames[level]
+}
+
+// Capabilities gated by trust level
+const (
+	CapVote       = "vote"
+	CapImages     = "images" // images in posts
+	CapLinks      = "links"  // link posts and links in text
+	CapClubBoards = "club_boards"
+)
+
+// TrustStats are what the trust level of a user is computed from.
+// Contributions are published posts and comments, VotesReceived the likes
+// of others on them and FlagsReceived the reports upheld and the content
+// deleted by moderators within TrustConfig.FlagWindow.
+type TrustStats struct {
+	UserID         int64 `json:"user_id"`
+	Level          int   `json:"level"` // as stored, before recalculation
+	AccountAgeDays int   `json:"account_age_days"`
+	PostsRead      int   `json:"posts_read"`
+	Contributions  int   `json:"contributions"`
+	VotesReceived  int   `json:"votes_received"`
+	FlagsReceived  int   `json:"flags_received"`
+}
+
+// TrustRequirement is what a level takes, MaxFlags is the most flags
+// received a user of the level may have
+type TrustRequirement struct {
+	AccountAgeDays int `json:"account_age_days"`
+	PostsRead      int `json:"posts_read"`
+	Contributions  int `json:"contributions"`
+	VotesReceived  int `json:"votes_received"`
+	MaxFlags       int `json:"max_flags"`
+}
+
+// Met reports whether st meets the requirement
+func (r TrustRequirement) Met(st TrustStats) bool {
+	return st.AccountAgeDays >= r.AccountAgeDays && st.PostsRead >= r.PostsRead &&
+		st.Contributions >= r.Contributions && st.VotesReceived >= r.VotesReceived &&
+		st.FlagsReceived <= r.MaxFlags
+}
+
+// TrustConfig holds the requirements of the levels above TrustNew, by
+// level, and the level each capability takes
+type TrustConfig struct {
+	Requirements [TrustLeader + 1]TrustRequirement
+	Capabilities map[string]int
+	// FlagWindow is how long flags count against a user
+	FlagWindow time.Duration
+}
+
+func DefaultTrustConfig() TrustConfig {
+	return TrustConfig{
+		Requirements: [TrustLeader + 1]TrustRequirement{
+			TrustBasic:   {AccountAgeDays: 1, PostsRead: 5, MaxFlags: 3},
+			TrustMember:  {AccountAgeDays: 7, PostsRead: 30, Contributions: 5, VotesReceived: 3, MaxFlags: 2},
+			TrustRegular: {AccountAgeDays: 30, PostsRead: 100, Contributions: 30, VotesReceived: 20, MaxFlags: 1},
+			TrustLeader:  {AccountAgeDays: 180, PostsRead: 500, Contributions: 200, VotesReceived: 200},
+		},
+		Capabilities: map[string]int{
+			CapVote:       TrustBasic,
+			CapImages:     TrustBasic,
+			CapLinks:      TrustMember,
+			CapClubBoards: TrustMember,
+		},
+		FlagWindow: 180 * 24 * time.Hour,
+	}
+}
+
+// Level is the highest level whose requirements, and those of all the
+// levels below it, st meets
+func (c TrustConfig) Level(st TrustStats) int {
+	level := TrustNew
+	for l := TrustBasic; l <= TrustLeader && c.Requirements[l].Met(st); l++ {
+		level = l
+	}
+	return level
+}
+
+// TrustProgress is a user's trust level and what the next one takes
+type TrustProgress struct {
+	Level     int               `json:"level"`
+	LevelName string            `json:"level_name"`
+	Stats     TrustStats        `json:"stats"`
+	Next      *TrustRequirement `json:"next,omitempty"` // nil at the top level
+	NextName  string            `json:"next_name,omitempty"`
+}
@@ -0,0 +1,42 @@
+package entity
+
+import "testing"
+
+func TestTrustConfigLevel(t *testing.T) {
+	cfg := DefaultTrustConfig()
+	tests := []struct {
+		name  string
+		stats TrustStats
+		want  int
+	}{
+		{"new account", TrustStats{}, TrustNew},
+		{"old account that never read", TrustStats{AccountAgeDays: 400}, TrustNew},
+		{"basic", TrustStats{AccountAgeDays: 1, PostsRead: 5}, TrustBasic},
+		{"basic with flags", TrustStats{AccountAgeDays: 1, PostsRead: 5, FlagsReceived: 3}, TrustBasic},
+		{"too many flags for basic", TrustStats{AccountAgeDays: 1, PostsRead: 5, FlagsReceived: 4}, TrustNew},
+		{"member", TrustStats{AccountAgeDays: 7, PostsRead: 30, Contributions: 5, VotesReceived: 3}, TrustMember},
+		{"member short of votes", TrustStats{AccountAgeDays: 7, PostsRead: 30, Contributions: 5, VotesReceived: 2}, TrustBasic},
+		{"regular", TrustStats{AccountAgeDays: 30, PostsRead: 100, Contributions: 30, VotesReceived: 20, FlagsReceived: 1}, TrustRegular},
+		{"regular stats, member flags", TrustStats{AccountAgeDays: 30, PostsRead: 100, Contributions: 30, VotesReceived: 20, FlagsReceived: 2}, TrustMember},
+		{"leader", TrustStats{AccountAgeDays: 180, PostsRead: 500, Contributions: 200, VotesReceived: 200}, TrustLeader},
+		{"leader with a flag", TrustStats{AccountAgeDays: 180, PostsRead: 500, Contributions: 200, VotesReceived: 200, FlagsReceived: 1}, TrustRegular},
+		// levels are climbed in order, so a missed lower requirement caps
+		// the level however good the rest is
+		{"no level skipped", TrustStats{AccountAgeDays: 0, PostsRead: 500, Contributions: 200, VotesReceived: 200}, TrustNew},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.Level(tt.stats); got != tt.want {
+				t.Errorf("got level %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTrustLevelName(t *testing.T) {
+	for _, level := range []int{-1, TrustLeader + 1} {
+		if got := TrustLevelName(level); got != TrustLevelNames[TrustNew] {
+			t.Errorf("level %d named %q", level, got)
+		}
+	}
+}
@@ -2,11 +2,40 @@ package entity
 
 import "time"
 
+// User roles, each one includes the permissions of the previous ones
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRank = map[string]int{RoleUser: 0, RoleModerator: 1, RoleAdmin: 2}
+
 type User struct {
-	ID        int64     `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"-"`
+	Role     string `json:"role"`
+	// TrustLevel is one of the Trust* levels, see TrustConfig
+	TrustLevel int       `json:"trust_level"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// HasRole reports whether u has role or a higher one; nil is a guest
+func (u *User) HasRole(role string) bool {
+	if u == nil {
+		return false
+	}
+	return roleRank[u.Role] >= roleRank[role]
+}
+
+// HasTrust reports whether u has trust level level or a higher one;
+// moderators have every level, nil is a guest
+func (u *User) HasTrust(level int) bool {
+	if u == nil {
+		return false
+	}
+	return u.HasRole(RoleModerator) || u.TrustLevel >= level
 }
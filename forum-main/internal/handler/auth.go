@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Authenticate resolves the signed session cookie into the request
+// context, so that handlers and services can find the signed in user with
+// entity.UserFromContext, and the forms of the pages can find the CSRF token
+// of the session. Forged, expired and unknown sessions are treated as guests.
+func Authenticate(users repository.UserRepository, sessions *Sessions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if c, err := r.Cookie(sessionCookie); err == nil {
+				if id, ok := sessions.userID(c.Value); ok {
+					if u, err := users.GetUserByID(r.Context(), id); err == nil && u != nil {
+						ctx := entity.ContextWithUser(r.Context(), u)
+						r = r.WithContext(utils.ContextWithCSRFToken(ctx, sessions.csrfToken(c.Value)))
+					}
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// CheckCSRF goes after Authenticate. Requests other than GET, HEAD and
+// OPTIONS of a signed in user must carry the CSRF token of the session in
+// the csrf form field or the X-CSRF-Token header, so that other sites can't
+// make the browser act for the user.
+func CheckCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+		want := utils.CSRFTokenFromContext(r.Context())
+		if want == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got := r.Header.Get(CSRFHeader)
+		if got == "" {
+			got = r.FormValue(CSRFField)
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+			http.Error(w, "invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// EnforceSanctions goes after Authenticate. Suspended and banned users may
+// read but not write: their requests other than GET are refused with the
+// reason. Users with sanctions they haven't seen yet are sent to
+// /sanctions by the next page they open.
+func EnforceSanctions(sanctions service.SanctionService) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if currentUser(r) == nil || sanctionExempt(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			current, err := sanctions.Current(r.Context())
+			if err != nil {
+				// the write paths check sanctions themselves
+				fmt.Println("sanctions:", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			read := r.Method == http.MethodGet || r.Method == http.MethodHead
+			unseen := false
+			for _, sn := range current {
+				if !read && (sn.Kind == entity.SanctionBan || sn.Kind == entity.SanctionSuspension) && sn.Active(time.Now()) {
+					http.Error(w, (&service.SanctionError{Sanction: sn}).Error(), http.StatusForbidden)
+					return
+				}
+				unseen = unseen || sn.SeenAt == nil
+			}
+			if unseen && read && r.URL.Path != "/sanctions" && !strings.HasPrefix(r.URL.Path, "/api/") &&
+				strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, "/sanctions", http.StatusSeeOther)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// sanctionExempt are the paths sanctioned users may always use
+func sanctionExempt(path string) bool {
+	return path == "/api/login" || path == "/api/register" ||
+		strings.HasPrefix(path, "/static/") || strings.HasPrefix(path, "/swagger/")
+}
+
+// currentUser returns the signed in user or nil
+func currentUser(r *http.Request) *entity.User {
+	return entity.UserFromContext(r.Context())
+}
+
+// currentUserID returns the signed in user's id or 0 for guests
+func currentUserID(r *http.Request) int64 {
+	if u := currentUser(r); u != nil {
+		return u.ID
+	}
+	return 0
+}
@@ -0,0 +1,451 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// BoardHandler is the admin side of boards: create, edit, archive, reorder
+type BoardHandler struct {
+	boards service.BoardService
+	clubs  service.ClubService
+}
+
+func NewBoardHandler(b service.BoardService) *BoardHandler {
+	return &BoardHandler{boards: b}
+}
+
+// WithClubs lets the admin page link boards to clubs
+func (h *BoardHandler) WithClubs(c service.ClubService) *BoardHandler {
+	h.clubs = c
+	return h
+}
+
+// boardError maps service errors to a status code and message
+func boardError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: slug must be lowercase latin letters, digits and dashes, title is required, sub-boards are one level deep, post permission is everyone, members or moderators", http.StatusBadRequest)
+	case errors.Is(err, service.ErrSlugTaken):
+		http.Error(w, "slug is taken", http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "board not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func boardID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// GET /admin/boards
+func (h *BoardHandler) AdminBoardsPageHTML(w http.ResponseWriter, r *http.Request) {
+	u := currentUser(r)
+	if u == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !u.HasRole(entity.RoleAdmin) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	boards, err := h.boards.List(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	categories, err := h.boards.Categories(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{"Boards": boards, "Categories": categories}
+	if h.clubs != nil {
+		if data["Clubs"], err = h.clubs.List(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	utils.RenderTemplate(w, r, "admin_boards_page.html", data)
+}
+
+// boardForm reads the board fields of the admin forms
+func boardForm(r *http.Request) *entity.Board {
+	categoryID, _ := strconv.ParseInt(r.FormValue("category_id"), 10, 64)
+	parentID, _ := strconv.ParseInt(r.FormValue("parent_id"), 10, 64)
+	clubID, _ := strconv.ParseInt(r.FormValue("club_id"), 10, 64)
+	return &entity.Board{
+		Slug: r.FormValue("slug"), Title: r.FormValue("title"), Description: r.FormValue("description"),
+		CategoryID: categoryID, ParentID: parentID, ClubID: clubID,
+	}
+}
+
+// POST /admin/boards (form: slug, title, description, category_id, parent_id, club_id)
+func (h *BoardHandler) CreateBoardForm(w http.ResponseWriter, r *http.Request) {
+	b := boardForm(r)
+	if _, err := h.boards.Create(r.Context(), b); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/boards/{id} (form: slug, title, description, category_id, parent_id, club_id)
+func (h *BoardHandler) UpdateBoardForm(w http.ResponseWriter, r *http.Request) {
+	b := boardForm(r)
+	b.ID = boardID(r)
+	if err := h.boards.Update(r.Context(), b); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/boards/{id}/archive
+func (h *BoardHandler) ArchiveBoardForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.SetArchived(r.Context(), boardID(r), true); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/boards/{id}/unarchive
+func (h *BoardHandler) UnarchiveBoardForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.SetArchived(r.Context(), boardID(r), false); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/boards/{id}/move (form: dir=up|down)
+func (h *BoardHandler) MoveBoardForm(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.boards.List(r.Context())
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	ids := make([]int64, len(boards))
+	for i, b := range boards {
+		ids[i] = b.ID
+	}
+	if err := h.boards.Reorder(r.Context(), moveID(ids, boardID(r), r.FormValue("dir"))); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// GET /admin/boards/{id}/settings
+func (h *BoardHandler) AdminBoardSettingsPageHTML(w http.ResponseWriter, r *http.Request) {
+	u := currentUser(r)
+	if u == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !u.HasRole(entity.RoleAdmin) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	b, err := h.boards.GetByID(r.Context(), boardID(r))
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	utils.RenderTemplate(w, r, "admin_board_settings_page.html", map[string]interface{}{
+		"Board":       b,
+		"Permissions": []string{entity.PostEveryone, entity.PostMembers, entity.PostModerators},
+	})
+}
+
+// POST /admin/boards/{id}/settings (form: rules, post_permission, allow_images,
+// allow_links, min_account_age_days, post_template, public_mod_log,
+// premod_account_age_days, premod_min_approved)
+func (h *BoardHandler) UpdateSettingsForm(w http.ResponseWriter, r *http.Request) {
+	minAge, _ := strconv.Atoi(r.FormValue("min_account_age_days"))
+	premodAge, _ := strconv.Atoi(r.FormValue("premod_account_age_days"))
+	premodApproved, _ := strconv.Atoi(r.FormValue("premod_min_approved"))
+	st := entity.BoardSettings{
+		Rules:             r.FormValue("rules"),
+		PostPermission:    r.FormValue("post_permission"),
+		AllowImages:       r.FormValue("allow_images") != "",
+		AllowLinks:        r.FormValue("allow_links") != "",
+		MinAccountAgeDays: minAge,
+		PostTemplate:      r.FormValue("post_template"),
+		PublicModLog:      r.FormValue("public_mod_log") != "",
+
+		PremodAccountAgeDays: premodAge,
+		PremodMinApproved:    premodApproved,
+	}
+	if err := h.boards.UpdateSettings(r.Context(), boardID(r), st); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// GET /api/boards — all boards in display order, archived ones included
+func (h *BoardHandler) ListJSON(w http.ResponseWriter, r *http.Request) {
+	boards, err := h.boards.List(r.Context())
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	if boards == nil {
+		boards = []entity.Board{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(boards)
+}
+
+type boardInput struct {
+	Slug        string `json:"slug"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	CategoryID  int64  `json:"category_id"`
+	ParentID    int64  `json:"parent_id"`
+	ClubID      int64  `json:"club_id"`
+}
+
+func (in boardInput) board() *entity.Board {
+	return &entity.Board{Slug: in.Slug, Title: in.Title, Description: in.Description, CategoryID: in.CategoryID, ParentID: in.ParentID, ClubID: in.ClubID}
+}
+
+// POST /api/boards {"slug", "title", "description", "category_id", "parent_id", "club_id"}
+func (h *BoardHandler) CreateJSON(w http.ResponseWriter, r *http.Request) {
+	var in boardInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	b := in.board()
+	if _, err := h.boards.Create(r.Context(), b); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(b)
+}
+
+// PUT /api/boards/{id} {"slug", "title", "description", "category_id", "parent_id", "club_id"}
+func (h *BoardHandler) UpdateJSON(w http.ResponseWriter, r *http.Request) {
+	var in boardInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	b := in.board()
+	b.ID = boardID(r)
+	if err := h.boards.Update(r.Context(), b); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/boards/{id}/settings {"rules", "post_permission", "allow_images",
+// "allow_links", "min_account_age_days", "post_template", "public_mod_log",
+// "premod_account_age_days", "premod_min_approved"}
+func (h *BoardHandler) UpdateSettingsJSON(w http.ResponseWriter, r *http.Request) {
+	var st entity.BoardSettings
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.boards.UpdateSettings(r.Context(), boardID(r), st); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/boards/{id}/archive
+func (h *BoardHandler) ArchiveJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.SetArchived(r.Context(), boardID(r), true); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/boards/{id}/archive
+func (h *BoardHandler) UnarchiveJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.SetArchived(r.Context(), boardID(r), false); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/boards/order {"ids": [3, 1, 2]}
+func (h *BoardHandler) ReorderJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.boards.Reorder(r.Context(), in.IDs); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /admin/categories (form: title)
+func (h *BoardHandler) CreateCategoryForm(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.boards.CreateCategory(r.Context(), &entity.BoardCategory{Title: r.FormValue("title")}); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/categories/{id} (form: title)
+func (h *BoardHandler) UpdateCategoryForm(w http.ResponseWriter, r *http.Request) {
+	c := &entity.BoardCategory{ID: boardID(r), Title: r.FormValue("title")}
+	if err := h.boards.UpdateCategory(r.Context(), c); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/categories/{id}/delete
+func (h *BoardHandler) DeleteCategoryForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.DeleteCategory(r.Context(), boardID(r)); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// POST /admin/categories/{id}/move (form: dir=up|down)
+func (h *BoardHandler) MoveCategoryForm(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.boards.Categories(r.Context())
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	ids := make([]int64, len(categories))
+	for i, c := range categories {
+		ids[i] = c.ID
+	}
+	if err := h.boards.ReorderCategories(r.Context(), moveID(ids, boardID(r), r.FormValue("dir"))); err != nil {
+		boardError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/boards", http.StatusSeeOther)
+}
+
+// GET /api/board-categories
+func (h *BoardHandler) CategoriesJSON(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.boards.Categories(r.Context())
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	if categories == nil {
+		categories = []entity.BoardCategory{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(categories)
+}
+
+// POST /api/board-categories {"title"}
+func (h *BoardHandler) CreateCategoryJSON(w http.ResponseWriter, r *http.Request) {
+	var c entity.BoardCategory
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.boards.CreateCategory(r.Context(), &c); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
+
+// PUT /api/board-categories/{id} {"title"}
+func (h *BoardHandler) UpdateCategoryJSON(w http.ResponseWriter, r *http.Request) {
+	var c entity.BoardCategory
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	c.ID = boardID(r)
+	if err := h.boards.UpdateCategory(r.Context(), &c); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/board-categories/{id}
+func (h *BoardHandler) DeleteCategoryJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.boards.DeleteCategory(r.Context(), boardID(r)); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/board-categories/order {"ids": [2, 1]}
+func (h *BoardHandler) ReorderCategoriesJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.boards.ReorderCategories(r.Context(), in.IDs); err != nil {
+		boardError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/boards/directory — categories with boards, sub-boards and stats
+func (h *BoardHandler) DirectoryJSON(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.boards.Directory(r.Context())
+	if err != nil {
+		boardError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(categories)
+}
+
+// moveID swaps id with its neighbour in ids, dir is "up" or "down"
+func moveID(ids []int64, id int64, dir string) []int64 {
+	for i := range ids {
+		if ids[i] != id {
+			continue
+		}
+		if dir == "up" && i > 0 {
+			ids[i-1], ids[i] = ids[i], ids[i-1]
+		} else if dir == "down" && i < len(ids)-1 {
+			ids[i+1], ids[i] = ids[i], ids[i+1]
+		}
+		break
+	}
+	return ids
+}
@@ -1,14 +1,20 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"forum1/internal/entity"
 	"forum1/internal/service"
-	"html/template"
+	"forum1/utils"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
 )
 
+// ClubHandler is the JSON API of clubs
 type ClubHandler struct {
 	service service.ClubService
 }
@@ -17,87 +23,388 @@ func NewClubHandler(s service.ClubService) *ClubHandler {
 	return &ClubHandler{service: s}
 }
 
-// POST /clubs
+// clubError maps service errors to a status code and message
+func clubError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: club name is required, join policy is open, approval or invite, roles are officer or member, invited users must exist, boards need a slug and a title", http.StatusBadRequest)
+	case errors.Is(err, service.ErrAlreadyMember), errors.Is(err, service.ErrNotMember), errors.Is(err, service.ErrOwnerCannotLeave),
+		errors.Is(err, service.ErrSlugTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInviteOnly), errors.Is(err, service.ErrTrustLevel):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "club not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func clubID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// memberID is the {user_id} of member and request routes
+func memberID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["user_id"], 10, 64)
+	return id
+}
+
+// POST /api/clubs {"name", "topic", "description"}
 func (h *ClubHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var c entity.Club
 	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "bad json", http.StatusBadRequest)
 		return
 	}
+	if _, err := h.service.Create(r.Context(), &c); err != nil {
+		clubError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(c)
+}
 
-	id, err := h.service.Create(r.Context(), &c)
+// GET /api/clubs/{id} — the club with its boards
+func (h *ClubHandler) GetByID(w http.ResponseWriter, r *http.Request) {
+	c, err := h.service.GetByID(r.Context(), clubID(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		clubError(w, err)
 		return
 	}
-
-	c.ID = id
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(c)
+	_ = json.NewEncoder(w).Encode(c)
 }
 
-// GET /clubs/{id}
-func (h *ClubHandler) GetByID(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // зависит от роутера, можно chi mux / gorilla mux
-	id, err := strconv.ParseInt(idStr, 10, 64)
+// GET /api/clubs
+func (h *ClubHandler) List(w http.ResponseWriter, r *http.Request) {
+	clubs, err := h.service.List(r.Context())
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		clubError(w, err)
 		return
 	}
+	if clubs == nil {
+		clubs = []entity.Club{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(clubs)
+}
 
-	c, err := h.service.GetByID(r.Context(), id)
+// GET /api/clubs/{id}/members
+func (h *ClubHandler) MembersJSON(w http.ResponseWriter, r *http.Request) {
+	members, err := h.service.Members(r.Context(), clubID(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		clubError(w, err)
 		return
 	}
+	if members == nil {
+		members = []entity.ClubMember{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(members)
+}
 
+// POST /api/clubs/{id}/join — {"joined": false} means a join request was filed
+func (h *ClubHandler) JoinJSON(w http.ResponseWriter, r *http.Request) {
+	joined, err := h.service.Join(r.Context(), clubID(r))
+	if err != nil {
+		clubError(w, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(c)
+	_ = json.NewEncoder(w).Encode(map[string]bool{"joined": joined})
 }
 
-// GET /clubs
-func (h *ClubHandler) List(w http.ResponseWriter, r *http.Request) {
-	clubs, err := h.service.List(r.Context())
+// POST /api/clubs/{id}/leave — also withdraws a request or declines an invitation
+func (h *ClubHandler) LeaveJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Leave(r.Context(), clubID(r)); err != nil {
+		clubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/clubs/{id}/policy {"join_policy"}
+func (h *ClubHandler) SetPolicyJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		JoinPolicy string `json:"join_policy"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.SetJoinPolicy(r.Context(), clubID(r), in.JoinPolicy); err != nil {
+		clubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/clubs/{id}/privacy {"private"}
+func (h *ClubHandler) SetPrivacyJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Private bool `json:"private"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.SetPrivate(r.Context(), clubID(r), in.Private); err != nil {
+		clubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/clubs/{id}/requests — pending requests and invitations, officers only
+func (h *ClubHandler) RequestsJSON(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.service.Requests(r.Context(), clubID(r))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		clubError(w, err)
 		return
 	}
+	if requests == nil {
+		requests = []entity.ClubRequest{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(requests)
+}
+
+// POST /api/clubs/{id}/requests/{user_id}/approve
+func (h *ClubHandler) ApproveJSON(w http.ResponseWriter, r *http.Request) {
+	clubNoContent(w, h.service.Approve(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /api/clubs/{id}/requests/{user_id}/deny
+func (h *ClubHandler) DenyJSON(w http.ResponseWriter, r *http.Request) {
+	clubNoContent(w, h.service.Deny(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /api/clubs/{id}/invites {"username"}
+func (h *ClubHandler) InviteJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Username string `json:"username"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	clubNoContent(w, h.service.Invite(r.Context(), clubID(r), in.Username))
+}
+
+// PUT /api/clubs/{id}/members/{user_id} {"role"}
+func (h *ClubHandler) SetRoleJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	clubNoContent(w, h.service.SetMemberRole(r.Context(), clubID(r), memberID(r), in.Role))
+}
 
+// DELETE /api/clubs/{id}/members/{user_id}
+func (h *ClubHandler) KickJSON(w http.ResponseWriter, r *http.Request) {
+	clubNoContent(w, h.service.Kick(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /api/clubs/{id}/boards {"slug", "title", "description"} — officers only
+func (h *ClubHandler) CreateBoardJSON(w http.ResponseWriter, r *http.Request) {
+	var b entity.Board
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.service.CreateBoard(r.Context(), clubID(r), &b); err != nil {
+		clubError(w, err)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(clubs)
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(b)
 }
 
+func clubNoContent(w http.ResponseWriter, err error) {
+	if err != nil {
+		clubError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ClubPageHandler serves the HTML pages of clubs
 type ClubPageHandler struct {
-	service   service.ClubService
-	templates *template.Template
+	service service.ClubService
+	events  service.EventService
+}
+
+func NewClubPageHandler(s service.ClubService) *ClubPageHandler {
+	return &ClubPageHandler{service: s}
 }
 
-func NewClubPageHandler(s service.ClubService, tmpl *template.Template) *ClubPageHandler {
-	return &ClubPageHandler{service: s, templates: tmpl}
+// WithEvents shows the upcoming club events on the club page
+func (h *ClubPageHandler) WithEvents(e service.EventService) *ClubPageHandler {
+	h.events = e
+	return h
 }
 
-// GET /boards/club
+// GET /clubs
 func (h *ClubPageHandler) ListPage(w http.ResponseWriter, r *http.Request) {
 	clubs, err := h.service.List(r.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	h.templates.ExecuteTemplate(w, "clubs", clubs)
+	data := map[string]interface{}{"Clubs": clubs, "CanCreate": currentUser(r) != nil}
+	if data["Invitations"], err = h.service.Invitations(r.Context()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.RenderTemplate(w, r, "clubs.html", data)
 }
 
-// GET /boards/club/{id}
+// GET /clubs/{id}
 func (h *ClubPageHandler) DetailPage(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id") // зависит от роутера
-	id, err := strconv.Atoi(idStr)
+	club, err := h.service.GetByID(r.Context(), clubID(r))
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, service.ErrInvalidInput) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	role, err := h.service.Role(r.Context(), club.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	members, err := h.service.Members(r.Context(), club.ID)
+	if err != nil && !errors.Is(err, service.ErrForbidden) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{
+		"Club":      club,
+		"Role":      role,
+		"Members":   members,
+		"Hidden":    errors.Is(err, service.ErrForbidden),
+		"SignedIn":  currentUser(r) != nil,
+		"IsOfficer": entity.ClubRoleAtLeast(role, entity.ClubRoleOfficer),
+		"IsOwner":   role == entity.ClubRoleOwner,
+		"Policies":  []string{entity.JoinOpen, entity.JoinApproval, entity.JoinInvite},
+		"ViewerID":  currentUserID(r),
+	}
+	if data["Pending"], err = h.service.PendingRequest(r.Context(), club.ID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if h.events != nil {
+		now := time.Now()
+		events, err := h.events.Upcoming(r.Context(), entity.EventFilter{ClubID: club.ID}, now, now.AddDate(0, 0, service.UpcomingDays))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(events) > 5 {
+			events = events[:5]
+		}
+		data["Events"] = events
+		data["ShowEvents"] = true
+	}
+	if data["IsOfficer"] == true {
+		if data["Requests"], err = h.service.Requests(r.Context(), club.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	utils.RenderTemplate(w, r, "club_detail.html", data)
+}
+
+// POST /clubs (form: name, topic, description, join_policy, private)
+func (h *ClubPageHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	c := &entity.Club{Name: r.FormValue("name"), Topic: r.FormValue("topic"), Description: r.FormValue("description"), JoinPolicy: r.FormValue("join_policy"), Private: r.FormValue("private") != ""}
+	id, err := h.service.Create(r.Context(), c)
 	if err != nil {
-		http.Error(w, "invalid id", http.StatusBadRequest)
+		clubError(w, err)
 		return
 	}
-	club, err := h.service.GetByID(r.Context(), int64(id))
+	http.Redirect(w, r, "/clubs/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
+
+// backToClub returns to the club page unless the club action failed
+func backToClub(w http.ResponseWriter, r *http.Request, err error) {
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		clubError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/clubs/"+strconv.FormatInt(clubID(r), 10), http.StatusSeeOther)
+}
+
+// POST /clubs/{id}/join
+func (h *ClubPageHandler) JoinForm(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	_, err := h.service.Join(r.Context(), clubID(r))
+	backToClub(w, r, err)
+}
+
+// POST /clubs/{id}/leave
+func (h *ClubPageHandler) LeaveForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.Leave(r.Context(), clubID(r)))
+}
+
+// POST /clubs/{id}/policy (form: join_policy)
+func (h *ClubPageHandler) PolicyForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.SetJoinPolicy(r.Context(), clubID(r), r.FormValue("join_policy")))
+}
+
+// POST /clubs/{id}/privacy (form: private)
+func (h *ClubPageHandler) PrivacyForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.SetPrivate(r.Context(), clubID(r), r.FormValue("private") != ""))
+}
+
+// POST /clubs/{id}/invite (form: username)
+func (h *ClubPageHandler) InviteForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.Invite(r.Context(), clubID(r), r.FormValue("username")))
+}
+
+// POST /clubs/{id}/requests/{user_id}/approve
+func (h *ClubPageHandler) ApproveForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.Approve(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /clubs/{id}/requests/{user_id}/deny
+func (h *ClubPageHandler) DenyForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.Deny(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /clubs/{id}/members/{user_id}/role (form: role)
+func (h *ClubPageHandler) RoleForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.SetMemberRole(r.Context(), clubID(r), memberID(r), r.FormValue("role")))
+}
+
+// POST /clubs/{id}/members/{user_id}/kick
+func (h *ClubPageHandler) KickForm(w http.ResponseWriter, r *http.Request) {
+	backToClub(w, r, h.service.Kick(r.Context(), clubID(r), memberID(r)))
+}
+
+// POST /clubs/{id}/boards (form: slug, title, description)
+func (h *ClubPageHandler) CreateBoardForm(w http.ResponseWriter, r *http.Request) {
+	b := &entity.Board{Slug: r.FormValue("slug"), Title: r.FormValue("title"), Description: r.FormValue("description")}
+	if _, err := h.service.CreateBoard(r.Context(), clubID(r), b); err != nil {
+		clubError(w, err)
 		return
 	}
-	h.templates.ExecuteTemplate(w, "club_detail", club)
+	http.Redirect(w, r, "/board/"+b.Slug, http.StatusSeeOther)
 }
@@ -1,9 +1,10 @@
 package handler
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"forum1/internal/entity"
-	"forum1/internal/repository"
 	"forum1/internal/service"
 	"net/http"
 	"strconv"
@@ -11,24 +12,17 @@ import (
 
 type CommentHandler struct {
 	svc   service.CommentService
-	users repository.UserRepository
 	posts service.PostService
 }
 
-func NewCommentHandler(svc service.CommentService, users repository.UserRepository) *CommentHandler {
-	return &CommentHandler{svc: svc, users: users}
+func NewCommentHandler(svc service.CommentService) *CommentHandler {
+	return &CommentHandler{svc: svc}
 }
 
 // CreateComment accepts either JSON or form (multipart/urlencoded)
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
-	// Resolve author from cookie
-	c, errCookie := r.Cookie("user")
-	if errCookie != nil || c.Value == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-	u, err := h.users.GetUserByName(r.Context(), c.Value)
-	if err != nil || u == nil {
+	u := currentUser(r)
+	if u == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -46,11 +40,11 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 		cmt := &entity.Comment{PostID: in.PostID, AuthorID: u.ID, Content: in.Content}
 		id, err := h.svc.CreateComment(r.Context(), cmt)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			createCommentError(w, err)
 			return
 		}
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "pending": cmt.Pending})
 		return
 	}
 
@@ -64,33 +58,43 @@ func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	cmt := &entity.Comment{PostID: postID, AuthorID: u.ID, Content: content}
 	id, err := h.svc.CreateComment(r.Context(), cmt)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		createCommentError(w, err)
 		return
 	}
 	// If client expects JSON (AJAX), return created info
 	if r.Header.Get("Accept") != "" && (r.Header.Get("Accept") == "application/json" || r.Header.Get("Accept")[:16] == "application/json") {
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "pending": cmt.Pending})
 		return
 	}
 	// Redirect back to the post page
 	http.Redirect(w, r, "/post/"+strconv.FormatInt(postID, 10), http.StatusSeeOther)
 }
 
+// createCommentError reports a rejected comment, closed threads,
+// sanctioned authors and links the author may not post are 403, posts the
+// author can't see 404
+func createCommentError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "post not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, service.ErrBoardArchived) || errors.Is(err, service.ErrPostLocked) || errors.Is(err, service.ErrSanctioned) ||
+		errors.Is(err, service.ErrContentBlocked) || errors.Is(err, service.ErrLinksDisabled) || errors.Is(err, service.ErrTrustLevel) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
 // DeleteComment allows delete by comment author or by post author
 func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
 		http.Error(w, "bad form", http.StatusBadRequest)
 		return
 	}
-	// Auth
-	c, errCookie := r.Cookie("user")
-	if errCookie != nil || c.Value == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-	u, err := h.users.GetUserByName(r.Context(), c.Value)
-	if err != nil || u == nil {
+	u := currentUser(r)
+	if u == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ContentRuleHandler lets admins manage the content rules and try them on
+// sample text
+type ContentRuleHandler struct {
+	rules service.ContentRuleService
+}
+
+func NewContentRuleHandler(rules service.ContentRuleService) *ContentRuleHandler {
+	return &ContentRuleHandler{rules: rules}
+}
+
+// contentRuleError maps service errors to a status code and message
+func contentRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: kind is block, censor, moderate, allow_domain or deny_domain, the pattern is required and at most 200 characters, domain rules take a domain like example.com ("+err.Error()+")", http.StatusBadRequest)
+	case errors.Is(err, service.ErrRuleExists):
+		http.Error(w, "the rule already exists", http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "rule not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func ruleID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// renderPage shows the rules, with the result of a test when there is one
+func (h *ContentRuleHandler) renderPage(w http.ResponseWriter, r *http.Request, data map[string]interface{}) {
+	u := currentUser(r)
+	if u == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if !u.HasRole(entity.RoleAdmin) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	rules, err := h.rules.List(r.Context())
+	if err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	data["Rules"] = rules
+	data["Kinds"] = entity.ContentRuleKinds
+	utils.RenderTemplate(w, r, "admin_rules_page.html", data)
+}
+
+// GET /admin/rules
+func (h *ContentRuleHandler) PageHTML(w http.ResponseWriter, r *http.Request) {
+	h.renderPage(w, r, map[string]interface{}{})
+}
+
+// ruleForm reads the rule fields of the admin form
+func ruleForm(r *http.Request) *entity.ContentRule {
+	return &entity.ContentRule{
+		Kind: r.FormValue("kind"), Pattern: r.FormValue("pattern"), Regex: r.FormValue("regex") != "",
+		Replacement: r.FormValue("replacement"), Note: r.FormValue("note"),
+	}
+}
+
+// POST /admin/rules (form: kind, pattern, regex, replacement, note)
+func (h *ContentRuleHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.rules.Create(r.Context(), ruleForm(r)); err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/rules", http.StatusSeeOther)
+}
+
+// POST /admin/rules/{id}/delete
+func (h *ContentRuleHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.rules.Delete(r.Context(), ruleID(r)); err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/admin/rules", http.StatusSeeOther)
+}
+
+// POST /admin/rules/test (form: title, content, link_url) shows what the
+// rules make of the sample
+func (h *ContentRuleHandler) TestForm(w http.ResponseWriter, r *http.Request) {
+	sample := map[string]string{"Title": r.FormValue("title"), "Content": r.FormValue("content"), "LinkURL": r.FormValue("link_url")}
+	res, err := h.rules.Test(r.Context(), sample["Title"], sample["Content"], sample["LinkURL"])
+	if err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	h.renderPage(w, r, map[string]interface{}{"Sample": sample, "Result": res})
+}
+
+// GET /api/admin/rules
+func (h *ContentRuleHandler) ListJSON(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.rules.List(r.Context())
+	if err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	if rules == nil {
+		rules = []entity.ContentRule{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rules)
+}
+
+// POST /api/admin/rules {"kind": "censor", "pattern": "word", "regex": false, "replacement": "***", "note": ""}
+func (h *ContentRuleHandler) CreateJSON(w http.ResponseWriter, r *http.Request) {
+	var rule entity.ContentRule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.rules.Create(r.Context(), &rule); err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rule)
+}
+
+// DELETE /api/admin/rules/{id}
+func (h *ContentRuleHandler) DeleteJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.rules.Delete(r.Context(), ruleID(r)); err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/admin/rules/test {"title": "", "content": "", "link_url": ""}
+func (h *ContentRuleHandler) TestJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+		LinkURL string `json:"link_url"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	res, err := h.rules.Test(r.Context(), in.Title, in.Content, in.LinkURL)
+	if err != nil {
+		contentRuleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
@@ -0,0 +1,408 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// eventTimeLayout is the wall clock format of event times in forms and
+// JSON, as sent by <input type="datetime-local">
+const eventTimeLayout = "2006-01-02T15:04"
+
+// defaultEventTimezone is preselected in the event form
+const defaultEventTimezone = "Europe/Moscow"
+
+// eventInput is an event as entered: times are wall clock times in Timezone
+type eventInput struct {
+	ClubID      int64  `json:"club_id"`
+	BoardID     int64  `json:"board_id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	StartsAt    string `json:"starts_at"`
+	EndsAt      string `json:"ends_at"`
+	Timezone    string `json:"timezone"`
+	Location    string `json:"location"`
+	RRule       string `json:"rrule"`
+}
+
+// event parses the times, an empty end means an hour long event
+func (in eventInput) event() (*entity.Event, error) {
+	if in.Timezone == "" {
+		in.Timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(in.Timezone)
+	if err != nil {
+		return nil, service.ErrInvalidInput
+	}
+	start, err := time.ParseInLocation(eventTimeLayout, in.StartsAt, loc)
+	if err != nil {
+		return nil, service.ErrInvalidInput
+	}
+	end := start.Add(time.Hour)
+	if in.EndsAt != "" {
+		if end, err = time.ParseInLocation(eventTimeLayout, in.EndsAt, loc); err != nil {
+			return nil, service.ErrInvalidInput
+		}
+	}
+	return &entity.Event{
+		ClubID: in.ClubID, BoardID: in.BoardID, Title: in.Title, Description: in.Description,
+		StartsAt: start, EndsAt: end, Timezone: in.Timezone, Location: in.Location, RRule: in.RRule,
+	}, nil
+}
+
+// eventError maps service errors to a status code and message
+func eventError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: title is required, times are YYYY-MM-DDTHH:MM in an IANA timezone, the end is after the start, the event belongs to one club or board, rrule is FREQ=DAILY|WEEKLY|MONTHLY|YEARLY with INTERVAL, COUNT or UNTIL and BYDAY for weekly rules, rsvp is going, maybe or no", http.StatusBadRequest)
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrBoardArchived), errors.Is(err, service.ErrMembersOnly), errors.Is(err, service.ErrAccountTooNew):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "event not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func eventID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// upcomingWindow is the period of the upcoming events view, ?days= sets
+// its length
+func upcomingWindow(r *http.Request) (time.Time, time.Time) {
+	days := service.UpcomingDays
+	if n, err := strconv.Atoi(r.URL.Query().Get("days")); err == nil && n > 0 && n <= 366 {
+		days = n
+	}
+	from := time.Now()
+	return from, from.AddDate(0, 0, days)
+}
+
+// EventHandler is the JSON API of events
+type EventHandler struct {
+	service service.EventService
+}
+
+func NewEventHandler(s service.EventService) *EventHandler {
+	return &EventHandler{service: s}
+}
+
+// GET /api/events?club=&board=&days= — upcoming occurrences
+func (h *EventHandler) ListJSON(w http.ResponseWriter, r *http.Request) {
+	var f entity.EventFilter
+	f.ClubID, _ = strconv.ParseInt(r.URL.Query().Get("club"), 10, 64)
+	f.BoardID, _ = strconv.ParseInt(r.URL.Query().Get("board"), 10, 64)
+	from, to := upcomingWindow(r)
+	list, err := h.service.Upcoming(r.Context(), f, from, to)
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	if list == nil {
+		list = []entity.EventOccurrence{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// POST /api/events {"club_id" | "board_id", "title", "description", "starts_at", "ends_at", "timezone", "location", "rrule"}
+func (h *EventHandler) CreateJSON(w http.ResponseWriter, r *http.Request) {
+	var in eventInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	e, err := in.event()
+	if err == nil {
+		_, err = h.service.Create(r.Context(), e)
+	}
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(e)
+}
+
+// GET /api/events/{id}
+func (h *EventHandler) GetJSON(w http.ResponseWriter, r *http.Request) {
+	e, err := h.service.GetByID(r.Context(), eventID(r))
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(e)
+}
+
+// DELETE /api/events/{id}
+func (h *EventHandler) DeleteJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.Delete(r.Context(), eventID(r)); err != nil {
+		eventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PUT /api/events/{id}/rsvp {"status"}
+func (h *EventHandler) RSVPJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.service.RSVP(r.Context(), eventID(r), in.Status); err != nil {
+		eventError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/events/{id}/rsvps
+func (h *EventHandler) RSVPsJSON(w http.ResponseWriter, r *http.Request) {
+	list, err := h.service.RSVPs(r.Context(), eventID(r))
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	if list == nil {
+		list = []entity.EventRSVP{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// EventPageHandler serves the event pages, forms and calendar feeds
+type EventPageHandler struct {
+	service service.EventService
+	boards  service.BoardService
+	clubs   service.ClubService
+}
+
+func NewEventPageHandler(s service.EventService, boards service.BoardService, clubs service.ClubService) *EventPageHandler {
+	return &EventPageHandler{service: s, boards: boards, clubs: clubs}
+}
+
+// GET /events?club=&board=&mine=1 — upcoming events
+func (h *EventPageHandler) ListPage(w http.ResponseWriter, r *http.Request) {
+	var f entity.EventFilter
+	data := map[string]interface{}{"Days": service.UpcomingDays, "SignedIn": currentUser(r) != nil}
+	q := r.URL.Query()
+	if id, _ := strconv.ParseInt(q.Get("club"), 10, 64); id > 0 {
+		club, err := h.clubs.GetByID(r.Context(), id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.ClubID = club.ID
+		data["Club"] = club
+	}
+	if slug := q.Get("board"); slug != "" {
+		b, err := h.boards.GetBySlug(r.Context(), slug)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		f.BoardID = b.ID
+		data["Board"] = b
+	}
+	if q.Get("mine") != "" && currentUser(r) != nil {
+		f.UserID = currentUserID(r)
+		data["Mine"] = true
+	}
+	from, to := upcomingWindow(r)
+	list, err := h.service.Upcoming(r.Context(), f, from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data["Occurrences"] = list
+	utils.RenderTemplate(w, r, "events_page.html", data)
+}
+
+// GET /events/{id}
+func (h *EventPageHandler) DetailPage(w http.ResponseWriter, r *http.Request) {
+	e, err := h.service.GetByID(r.Context(), eventID(r))
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, service.ErrInvalidInput) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rsvps, err := h.service.RSVPs(r.Context(), e.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	canManage, err := h.service.CanManage(r.Context(), e)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	now := time.Now()
+	dates := h.service.Occurrences(*e, now, now.AddDate(1, 0, 0))
+	if len(dates) > 10 {
+		dates = dates[:10]
+	}
+	utils.RenderTemplate(w, r, "event_page.html", map[string]interface{}{
+		"Event":     e,
+		"Dates":     dates,
+		"RSVPs":     rsvps,
+		"SignedIn":  currentUser(r) != nil,
+		"CanManage": canManage,
+	})
+}
+
+// GET /events/new?club= | ?board= — the event form
+func (h *EventPageHandler) NewPage(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	data := map[string]interface{}{"Timezone": defaultEventTimezone}
+	q := r.URL.Query()
+	if id, _ := strconv.ParseInt(q.Get("club"), 10, 64); id > 0 {
+		club, err := h.clubs.GetByID(r.Context(), id)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		data["Club"] = club
+	} else if b, err := h.boards.GetBySlug(r.Context(), q.Get("board")); err == nil {
+		data["Board"] = b
+	} else {
+		http.Error(w, "choose a club or a board for the event", http.StatusBadRequest)
+		return
+	}
+	utils.RenderTemplate(w, r, "event_form_page.html", data)
+}
+
+// POST /events (form: club_id | board_id, title, description, starts_at, ends_at, timezone, location, rrule)
+func (h *EventPageHandler) CreateForm(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	in := eventInput{
+		Title: r.FormValue("title"), Description: r.FormValue("description"),
+		StartsAt: r.FormValue("starts_at"), EndsAt: r.FormValue("ends_at"),
+		Timezone: r.FormValue("timezone"), Location: r.FormValue("location"), RRule: r.FormValue("rrule"),
+	}
+	in.ClubID, _ = strconv.ParseInt(r.FormValue("club_id"), 10, 64)
+	in.BoardID, _ = strconv.ParseInt(r.FormValue("board_id"), 10, 64)
+	e, err := in.event()
+	if err == nil {
+		_, err = h.service.Create(r.Context(), e)
+	}
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/events/"+strconv.FormatInt(e.ID, 10), http.StatusSeeOther)
+}
+
+// POST /events/{id}/rsvp (form: status)
+func (h *EventPageHandler) RSVPForm(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if err := h.service.RSVP(r.Context(), eventID(r), r.FormValue("status")); err != nil {
+		eventError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/events/"+strconv.FormatInt(eventID(r), 10), http.StatusSeeOther)
+}
+
+// POST /events/{id}/delete
+func (h *EventPageHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
+	e, err := h.service.GetByID(r.Context(), eventID(r))
+	if err == nil {
+		err = h.service.Delete(r.Context(), e.ID)
+	}
+	if err != nil {
+		eventError(w, err)
+		return
+	}
+	back := "/events"
+	if e.ClubID != 0 {
+		back = "/clubs/" + strconv.FormatInt(e.ClubID, 10)
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
+// feedRequest signs the request in as the owner of ?token=, so feeds of
+// private clubs work in calendar apps
+func (h *EventPageHandler) feedRequest(r *http.Request, token string) (*http.Request, error) {
+	if token == "" {
+		return r, nil
+	}
+	u, err := h.service.CalendarUser(r.Context(), token)
+	if err != nil {
+		return nil, err
+	}
+	return r.WithContext(entity.ContextWithUser(r.Context(), u)), nil
+}
+
+// GET /clubs/{id}/events.ics?token=
+func (h *EventPageHandler) ClubFeed(w http.ResponseWriter, r *http.Request) {
+	fr, err := h.feedRequest(r, r.URL.Query().Get("token"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	r = fr
+	club, err := h.clubs.GetByID(r.Context(), clubID(r))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	events, err := h.service.Feed(r.Context(), entity.EventFilter{ClubID: club.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeICS(w, r, club.Name, events)
+}
+
+// GET /calendar/{token}.ics — events of the user's clubs and their RSVPs
+func (h *EventPageHandler) UserFeed(w http.ResponseWriter, r *http.Request) {
+	fr, err := h.feedRequest(r, mux.Vars(r)["token"])
+	if err != nil || currentUser(fr) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	r = fr
+	events, err := h.service.Feed(r.Context(), entity.EventFilter{UserID: currentUserID(r)})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeICS(w, r, "Форум: мои события", events)
+}
+
+// POST /settings/calendar-token — replaces the feed token
+func (h *EventPageHandler) ResetTokenForm(w http.ResponseWriter, r *http.Request) {
+	if _, err := h.service.ResetCalendarToken(r.Context()); err != nil {
+		eventError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
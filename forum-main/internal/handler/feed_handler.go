@@ -0,0 +1,136 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type FeedHandler struct {
+	feed   service.FeedService
+	boards service.BoardService
+}
+
+func NewFeedHandler(feed service.FeedService, boards service.BoardService) *FeedHandler {
+	return &FeedHandler{feed: feed, boards: boards}
+}
+
+// GET /api/feed?sort=&period=&cursor=&limit= — front page feed, hot by default
+func (h *FeedHandler) FeedJSON(w http.ResponseWriter, r *http.Request) {
+	opts := listOptions(r)
+	if r.URL.Query().Get("sort") == "" {
+		opts.Sort = entity.SortHot
+	}
+	page, err := h.feed.Front(r.Context(), currentUserID(r), opts)
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "bad listing parameters", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "failed to fetch feed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
+}
+
+// GET /api/feed/exclusions — ids of boards hidden from the user's feed
+func (h *FeedHandler) ExclusionsJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	ids, err := h.feed.ExcludedBoards(r.Context(), uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ids == nil {
+		ids = []int64{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"board_ids": ids})
+}
+
+// POST /api/feed/exclusions {"board_id": 1}
+func (h *FeedHandler) AddExclusion(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var in struct {
+		BoardID int64 `json:"board_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	switch err := h.feed.ExcludeBoard(r.Context(), uid, in.BoardID); {
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "board_id required", http.StatusBadRequest)
+		return
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "board not found", http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/feed/exclusions/{board_id}
+func (h *FeedHandler) RemoveExclusion(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	boardID, _ := strconv.ParseInt(mux.Vars(r)["board_id"], 10, 64)
+	if err := h.feed.IncludeBoard(r.Context(), uid, boardID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /board/{slug}/hide — hide the board from the front page (form)
+func (h *FeedHandler) HideBoard(w http.ResponseWriter, r *http.Request) {
+	h.setBoardHidden(w, r, true)
+}
+
+// POST /board/{slug}/show — show the board on the front page again (form)
+func (h *FeedHandler) ShowBoard(w http.ResponseWriter, r *http.Request) {
+	h.setBoardHidden(w, r, false)
+}
+
+func (h *FeedHandler) setBoardHidden(w http.ResponseWriter, r *http.Request, hidden bool) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	slug := mux.Vars(r)["slug"]
+	b, err := h.boards.GetBySlug(r.Context(), slug)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if hidden {
+		err = h.feed.ExcludeBoard(r.Context(), uid, b.ID)
+	} else {
+		err = h.feed.IncludeBoard(r.Context(), uid, b.ID)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/board/"+slug, http.StatusSeeOther)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"forum1/internal/entity"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsEscape escapes an iCalendar TEXT value
+var icsEscape = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// icsLine writes a content line folded at 75 octets, as RFC 5545 requires
+func icsLine(buf *bytes.Buffer, line string) {
+	// continuation lines start with a space, which counts too
+	for limit := 75; len(line) > limit; limit = 74 {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	buf.WriteString(line + "\r\n")
+}
+
+// writeICS serves events as an iCalendar feed. Times are written in the
+// event's zone by its IANA name, which calendar apps resolve themselves.
+func writeICS(w http.ResponseWriter, r *http.Request, name string, events []entity.Event) {
+	var buf bytes.Buffer
+	icsLine(&buf, "BEGIN:VCALENDAR")
+	icsLine(&buf, "VERSION:2.0")
+	icsLine(&buf, "PRODID:-//forum1//events//RU")
+	icsLine(&buf, "CALSCALE:GREGORIAN")
+	icsLine(&buf, "X-WR-CALNAME:"+icsEscape.Replace(name))
+	stamp := time.Now().UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		loc, err := time.LoadLocation(e.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		icsLine(&buf, "BEGIN:VEVENT")
+		icsLine(&buf, fmt.Sprintf("UID:event-%d@%s", e.ID, r.Host))
+		icsLine(&buf, "DTSTAMP:"+stamp)
+		icsLine(&buf, "CREATED:"+e.CreatedAt.UTC().Format("20060102T150405Z"))
+		if loc == time.UTC {
+			icsLine(&buf, "DTSTART:"+e.StartsAt.UTC().Format("20060102T150405Z"))
+			icsLine(&buf, "DTEND:"+e.EndsAt.UTC().Format("20060102T150405Z"))
+		} else {
+			icsLine(&buf, "DTSTART;TZID="+e.Timezone+":"+e.StartsAt.In(loc).Format("20060102T150405"))
+			icsLine(&buf, "DTEND;TZID="+e.Timezone+":"+e.EndsAt.In(loc).Format("20060102T150405"))
+		}
+		if e.RRule != "" {
+			icsLine(&buf, "RRULE:"+e.RRule)
+		}
+		icsLine(&buf, "SUMMARY:"+icsEscape.Replace(e.Title))
+		if e.Description != "" {
+			icsLine(&buf, "DESCRIPTION:"+icsEscape.Replace(e.Description))
+		}
+		if e.Location != "" {
+			icsLine(&buf, "LOCATION:"+icsEscape.Replace(e.Location))
+		}
+		icsLine(&buf, fmt.Sprintf("URL:%s://%s/events/%d", requestScheme(r), r.Host, e.ID))
+		icsLine(&buf, "END:VEVENT")
+	}
+	icsLine(&buf, "END:VCALENDAR")
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(buf.Bytes())
+}
+
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"forum1/internal/entity"
+	"net/http"
+	"strconv"
+)
+
+type listOption struct {
+	Value string
+	Label string
+}
+
+var postSorts = []listOption{
+	{entity.SortNew, "Новые"},
+	{entity.SortOld, "Старые"},
+	{entity.SortTop, "Лучшие"},
+	{entity.SortComments, "Обсуждаемые"},
+	{entity.SortActive, "Активные"},
+}
+
+// the front page additionally offers the hot ranking, its default
+var frontSorts = append([]listOption{{entity.SortHot, "Горячие"}}, postSorts...)
+
+var topPeriods = []listOption{
+	{entity.PeriodDay, "за день"},
+	{entity.PeriodWeek, "за неделю"},
+	{entity.PeriodMonth, "за месяц"},
+	{entity.PeriodAll, "за всё время"},
+}
+
+// listOptions reads ?sort=&period=&cursor=&limit= of a post listing
+func listOptions(r *http.Request) entity.PostListOptions {
+	q := r.URL.Query()
+	opts := entity.PostListOptions{
+		Sort:   q.Get("sort"),
+		Period: q.Get("period"),
+		Cursor: q.Get("cursor"),
+	}
+	if opts.Sort == "" {
+		opts.Sort = entity.SortNew
+	}
+	if opts.Period == "" {
+		opts.Period = entity.PeriodAll
+	}
+	opts.Limit, _ = strconv.Atoi(q.Get("limit"))
+	return opts
+}
+
+// listingData is the template data shared by paginated post listings
+func listingData(opts entity.PostListOptions, page *entity.PostPage) map[string]interface{} {
+	return map[string]interface{}{
+		"Posts":      page.Posts,
+		"NextCursor": page.NextCursor,
+		"Sort":       opts.Sort,
+		"Period":     opts.Period,
+		"Sorts":      postSorts,
+		"Periods":    topPeriods,
+	}
+}
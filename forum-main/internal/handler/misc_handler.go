@@ -1,16 +1,22 @@
 package handler
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"forum1/db"
 	"forum1/internal/entity"
 	"forum1/internal/models"
 	"forum1/internal/service"
 	"forum1/utils"
+	"html/template"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -19,6 +25,12 @@ type PageHandler struct {
 	posts    service.PostService
 	boards   service.BoardService
 	comments service.CommentService
+	previews service.LinkPreviewService
+	feed     service.FeedService
+	saved    service.SavedSearchService
+	mod      service.ModerationService
+	events   service.EventService
+	trust    service.TrustService
 }
 
 // WithComments allows injecting CommentService fluently after construction
@@ -27,24 +39,91 @@ func (h *PageHandler) WithComments(c service.CommentService) *PageHandler {
 	return h
 }
 
+// WithFeed makes the home page show the hot-ranked front page feed
+func (h *PageHandler) WithFeed(f service.FeedService) *PageHandler {
+	h.feed = f
+	return h
+}
+
+// WithSavedSearches lists the user's saved searches on the settings page
+func (h *PageHandler) WithSavedSearches(s service.SavedSearchService) *PageHandler {
+	h.saved = s
+	return h
+}
+
+// WithModeration enables moderator tools on post pages and redirects from
+// merged posts
+func (h *PageHandler) WithModeration(m service.ModerationService) *PageHandler {
+	h.mod = m
+	return h
+}
+
+// WithEvents shows the calendar feed address on the settings page
+func (h *PageHandler) WithEvents(e service.EventService) *PageHandler {
+	h.events = e
+	return h
+}
+
+// WithTrust counts post views towards trust levels and hides the image
+// and link fields from users whose level doesn't allow them
+func (h *PageHandler) WithTrust(t service.TrustService) *PageHandler {
+	h.trust = t
+	return h
+}
+
+// trusted reports whether the user of r has the trust capability
+func (h *PageHandler) trusted(r *http.Request, capability string) bool {
+	return h.trust == nil || h.trust.Require(r.Context(), capability) == nil
+}
+
+// WithPreviews enables link preview cards on the post page
+func (h *PageHandler) WithPreviews(p service.LinkPreviewService) *PageHandler {
+	h.previews = p
+	return h
+}
+
 func NewPageHandler(p service.PostService, b service.BoardService) *PageHandler {
 	// Backwards-compatible constructor; comments can be injected later if needed
 	return &PageHandler{posts: p, boards: b}
 }
 
 func (h *PageHandler) HomePageHTML(w http.ResponseWriter, r *http.Request) {
+	opts := listOptions(r)
+	var page *entity.PostPage
+	var err error
+	if h.feed != nil {
+		if r.URL.Query().Get("sort") == "" {
+			opts.Sort = entity.SortHot
+		}
+		page, err = h.feed.Front(r.Context(), currentUserID(r), opts)
+	} else {
+		page, err = h.posts.ListPosts(r.Context(), opts)
+	}
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "bad listing parameters", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 	// Load boards for sidebar/home
 	boards, _ := h.boards.List(r.Context())
-	data := map[string]interface{}{
-		"Boards": boards,
+	data := listingData(opts, page)
+	data["Boards"] = boards
+	if h.feed != nil {
+		data["Sorts"] = frontSorts
 	}
-	utils.RenderTemplate(w, "home_page.html", data)
+	utils.RenderTemplate(w, r, "home_page.html", data)
 }
 
 func (h *PageHandler) BoardsListPage(w http.ResponseWriter, r *http.Request) {
-	boards, _ := h.boards.List(r.Context())
-	data := map[string]interface{}{"Boards": boards}
-	utils.RenderTemplate(w, "boards_list_page.html", data)
+	categories, err := h.boards.Directory(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data := map[string]interface{}{"Categories": categories}
+	utils.RenderTemplate(w, r, "boards_list_page.html", data)
 }
 
 func (h *PageHandler) BoardPage(w http.ResponseWriter, r *http.Request) {
@@ -55,16 +134,52 @@ func (h *PageHandler) BoardPage(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	posts, _ := h.posts.GetPostsByBoard(r.Context(), int64(b.ID))
-	data := map[string]interface{}{"Board": b, "Posts": posts}
-	utils.RenderTemplate(w, "board_page.html", data)
+	opts := listOptions(r)
+	opts.BoardID = b.ID
+	opts.SkipPinned = true
+	page, err := h.posts.ListPosts(r.Context(), opts)
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "bad listing parameters", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if opts.Cursor == "" {
+		// pinned posts head the first page whatever the sort
+		pinned, err := h.posts.ListPosts(r.Context(), entity.PostListOptions{BoardID: b.ID, OnlyPinned: true, Limit: service.MaxPageSize})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		page.Posts = append(pinned.Posts, page.Posts...)
+	}
+	data := listingData(opts, page)
+	data["Board"] = b
+	data["Breadcrumbs"], _ = h.boards.Breadcrumbs(r.Context(), b)
+	if uid := currentUserID(r); h.feed != nil && uid != 0 {
+		excluded, _ := h.feed.ExcludedBoards(r.Context(), uid)
+		data["CanHide"] = true
+		data["HiddenFromFeed"] = slices.Contains(excluded, b.ID)
+	}
+	utils.RenderTemplate(w, r, "board_page.html", data)
 }
 
 func (h *PageHandler) PostPageHTML(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
 	id, _ := strconv.ParseInt(idStr, 10, 64)
-	post, _ := h.posts.GetPostByID(r.Context(), id)
+	post, err := h.posts.GetPostByID(r.Context(), id)
+	if err != nil || post == nil {
+		if h.mod != nil {
+			if to, err := h.mod.Redirect(r.Context(), id); err == nil {
+				http.Redirect(w, r, "/post/"+strconv.FormatInt(to, 10), http.StatusMovedPermanently)
+				return
+			}
+		}
+		http.NotFound(w, r)
+		return
+	}
 
 	// Load comments with like/dislike counters
 	var comments []entity.Comment
@@ -75,84 +190,184 @@ func (h *PageHandler) PostPageHTML(w http.ResponseWriter, r *http.Request) {
 		// fallback to models
 		comments, _ = models.GetCommentsByPost(int(id))
 	}
-	if post != nil {
-		post.Comments = comments
-		// Load like/dislike counters for the post via service
-		if likes, dislikes, err := h.posts.GetPostVotes(r.Context(), id); err == nil {
-			post.Likes, post.Dislikes = likes, dislikes
+	post.Comments = comments
+	// Load like/dislike counters for the post via service
+	if likes, dislikes, err := h.posts.GetPostVotes(r.Context(), id); err == nil {
+		post.Likes, post.Dislikes = likes, dislikes
+	}
+	if h.trust != nil {
+		if err := h.trust.RecordView(r.Context(), id); err != nil {
+			fmt.Println("post view:", err)
 		}
 	}
+	if h.previews != nil && post.LinkURL != "" {
+		// don't hold the page for long if the preview isn't cached yet
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		post.LinkPreview, _ = h.previews.Get(ctx, post.LinkURL)
+		cancel()
+	}
 
-	// Pass the post as root context as expected by the template
-	utils.RenderTemplate(w, "post_page.html", post)
+	data := map[string]interface{}{"Post": post, "CanModerate": currentUser(r).HasRole(entity.RoleModerator),
+		"SignedIn": currentUser(r) != nil, "Reported": r.URL.Query().Get("reported") != ""}
+	if h.mod != nil && data["CanModerate"] == true {
+		data["MoveBoards"], _ = h.boards.List(r.Context())
+	}
+	if b, err := h.boards.GetByID(r.Context(), int64(post.BoardID)); err == nil {
+		data["Board"] = b
+		data["Breadcrumbs"], _ = h.boards.Breadcrumbs(r.Context(), b)
+	}
+	utils.RenderTemplate(w, r, "post_page.html", data)
 }
 
 func (h *PageHandler) ProfilePageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "profile_page.html", map[string]interface{}{})
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	utils.RenderTemplate(w, r, "profile_page.html", map[string]interface{}{
+		"ProfileID": id,
+		"CanReport": currentUser(r) != nil && currentUserID(r) != id,
+		"Reported":  r.URL.Query().Get("reported") != "",
+	})
 }
 
 func (h *PageHandler) LoginPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "login_page.html", map[string]interface{}{})
+	utils.RenderTemplate(w, r, "login_page.html", map[string]interface{}{})
 }
 
 func (h *PageHandler) RegisterPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "register_page.html", map[string]interface{}{})
+	utils.RenderTemplate(w, r, "register_page.html", map[string]interface{}{})
 }
 
+// CreatePostPageHTML lets the user pick a board, then shows its rules and
+// the post form prefilled with the board's template: /create-post?board=slug
 func (h *PageHandler) CreatePostPageHTML(w http.ResponseWriter, r *http.Request) {
-	boards, _ := h.boards.List(r.Context())
-	// Template expects to range over root (.)
-	utils.RenderTemplate(w, "create_post_page.html", boards)
-}
-
-func (h *PageHandler) BoardsSearchPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "boards_search_page.html", map[string]interface{}{})
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	data := map[string]interface{}{}
+	if slug := r.URL.Query().Get("board"); slug != "" {
+		b, err := h.boards.GetBySlug(r.Context(), slug)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		data["Board"] = b
+		if err := h.boards.CheckPosting(r.Context(), b); err != nil {
+			data["Denied"] = postingDeniedText(err)
+		}
+		if b.Settings.Rules != "" {
+			data["Rules"] = template.HTML(utils.RenderMarkdown(b.Settings.Rules))
+		}
+		// moderators are not bound by the settings
+		mod := currentUser(r).HasRole(entity.RoleModerator)
+		data["AllowImages"] = mod || b.Settings.AllowImages && h.trusted(r, entity.CapImages)
+		data["AllowLinks"] = mod || b.Settings.AllowLinks && h.trusted(r, entity.CapLinks)
+		utils.RenderTemplate(w, r, "create_post_page.html", data)
+		return
+	}
+	all, _ := h.boards.List(r.Context())
+	boards := make([]entity.Board, 0, len(all))
+	for _, b := range all {
+		if h.boards.CheckPosting(r.Context(), &b) == nil {
+			boards = append(boards, b)
+		}
+	}
+	data["Boards"] = boards
+	utils.RenderTemplate(w, r, "create_post_page.html", data)
 }
 
-func (h *PageHandler) SearchPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "search_page.html", map[string]interface{}{})
+// postingDeniedText explains a CheckPosting refusal to the user
+func postingDeniedText(err error) string {
+	switch {
+	case errors.Is(err, service.ErrBoardArchived):
+		return "Доска в архиве, новые посты не принимаются."
+	case errors.Is(err, service.ErrForbidden):
+		return "В этой доске публикуют только модераторы."
+	case errors.Is(err, service.ErrMembersOnly):
+		return "В этой доске публикуют только участники клуба."
+	case errors.Is(err, service.ErrAccountTooNew):
+		return "Ваш аккаунт слишком новый для публикации в этой доске."
+	default:
+		return "Публикация в этой доске недоступна."
+	}
 }
 
 func (h *PageHandler) SettingsPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "settings_page.html", map[string]interface{}{})
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	data := map[string]interface{}{}
+	if h.saved != nil {
+		searches, err := h.saved.List(r.Context(), uid)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data["SavedSearches"] = searches
+	}
+	if h.events != nil {
+		token, err := h.events.CalendarToken(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		data["CalendarURL"] = "webcal://" + r.Host + "/calendar/" + token + ".ics"
+	}
+	utils.RenderTemplate(w, r, "settings_page.html", data)
 }
 
 func (h *PageHandler) MessagesPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "messages_page.html", map[string]interface{}{})
+	utils.RenderTemplate(w, r, "messages_page.html", map[string]interface{}{})
 }
 
-func (h *PageHandler) NotificationsPageHTML(w http.ResponseWriter, r *http.Request) {
-	utils.RenderTemplate(w, "notifications_page.html", map[string]interface{}{})
-}
-
-// Serve post image as /post/{id}/image
+// Serve post image as /post/{id}/image, ?w= selects a resized variant
 func (h *PageHandler) PostImage(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
 		http.Error(w, "bad id", http.StatusBadRequest)
 		return
 	}
-	p, err := models.GetPostByID(id)
-	if err == sql.ErrNoRows {
+	width := 0
+	if ws := r.URL.Query().Get("w"); ws != "" {
+		width, err = strconv.Atoi(ws)
+		if err != nil || width < 0 {
+			http.Error(w, "bad width", http.StatusBadRequest)
+			return
+		}
+	}
+	img, err := h.posts.GetPostImage(r.Context(), id, width)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, service.ErrNoImage) {
 		http.NotFound(w, r)
 		return
 	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if len(p.ImageData) == 0 {
-		http.NotFound(w, r)
-		return
+	w.Header().Set("Content-Type", img.ContentType)
+	// resized copies are made from the original alone, so its hash and the
+	// width tell them apart
+	if img.Hash != "" {
+		etag := img.Hash[:32]
+		if img.Width > 0 {
+			etag += "-" + strconv.Itoa(img.Width)
+		}
+		w.Header().Set("ETag", `"`+etag+`"`)
+	}
+	// signed in viewers may see images of private club boards, which
+	// shared caches must not keep
+	if currentUser(r) != nil {
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
 	}
-	ct := http.DetectContentType(p.ImageData)
-	w.Header().Set("Content-Type", ct)
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(p.ImageData)
+	// ServeContent sets Last-Modified and answers conditional requests
+	http.ServeContent(w, r, "", img.ModTime, bytes.NewReader(img.Data))
 }
 
-// Like/Dislike post via GET links
+// Like/Dislike post via POST forms
 func (h *PageHandler) LikePost(w http.ResponseWriter, r *http.Request) {
 	h.votePost(w, r, 1)
 }
@@ -161,7 +376,7 @@ func (h *PageHandler) DislikePost(w http.ResponseWriter, r *http.Request) {
 	h.votePost(w, r, -1)
 }
 
-// Like/Dislike comment via GET links (?post_id= for redirect)
+// Like/Dislike comment via POST forms (?post_id= for redirect)
 func (h *PageHandler) LikeComment(w http.ResponseWriter, r *http.Request) {
 	h.voteComment(w, r, 1)
 }
@@ -174,18 +389,25 @@ func (h *PageHandler) DislikeComment(w http.ResponseWriter, r *http.Request) {
 func (h *PageHandler) votePost(w http.ResponseWriter, r *http.Request, value int) {
 	vars := mux.Vars(r)
 	idStr := vars["id"]
-	cookie, err := r.Cookie("user")
-	if err != nil || cookie.Value == "" {
+	userID := currentUserID(r)
+	if userID == 0 {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-	var userID int
-	if err := db.DB.QueryRow("SELECT id FROM users WHERE username=$1", cookie.Value).Scan(&userID); err != nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
 	postID, _ := strconv.Atoi(idStr)
-	if err := h.posts.SetPostVote(r.Context(), int64(postID), int64(userID), value); err != nil {
+	if err := h.posts.SetPostVote(r.Context(), int64(postID), userID, value); errors.Is(err, service.ErrBoardArchived) {
+		http.Error(w, "board is archived", http.StatusForbidden)
+		return
+	} else if errors.Is(err, service.ErrPostLocked) {
+		http.Error(w, "post is locked", http.StatusForbidden)
+		return
+	} else if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return
+	} else if errors.Is(err, service.ErrSanctioned) || errors.Is(err, service.ErrTrustLevel) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
 		http.Error(w, "vote error", http.StatusInternalServerError)
 		return
 	}
@@ -204,19 +426,26 @@ func (h *PageHandler) voteComment(w http.ResponseWriter, r *http.Request, value
 	vars := mux.Vars(r)
 	commentIDStr := vars["id"]
 	postID := r.URL.Query().Get("post_id")
-	cookie, err := r.Cookie("user")
-	if err != nil || cookie.Value == "" {
+	userID := currentUserID(r)
+	if userID == 0 {
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
-	var userID int
-	if err := db.DB.QueryRow("SELECT id FROM users WHERE username=$1", cookie.Value).Scan(&userID); err != nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
 	cid, _ := strconv.Atoi(commentIDStr)
 	if h.comments != nil {
-		if err := h.comments.SetCommentVote(r.Context(), int64(cid), int64(userID), value); err != nil {
+		if err := h.comments.SetCommentVote(r.Context(), int64(cid), userID, value); errors.Is(err, service.ErrBoardArchived) {
+			http.Error(w, "board is archived", http.StatusForbidden)
+			return
+		} else if errors.Is(err, service.ErrPostLocked) {
+			http.Error(w, "post is locked", http.StatusForbidden)
+			return
+		} else if errors.Is(err, sql.ErrNoRows) {
+			http.NotFound(w, r)
+			return
+		} else if errors.Is(err, service.ErrSanctioned) || errors.Is(err, service.ErrTrustLevel) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		} else if err != nil {
 			http.Error(w, "vote error", http.StatusInternalServerError)
 			return
 		}
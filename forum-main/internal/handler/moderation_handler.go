@@ -0,0 +1,365 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// ModerationHandler serves the thread tools of moderators: move, merge,
+// split, deletion, the pre-moderation queue and the audit log, and the
+// public logs of boards
+type ModerationHandler struct {
+	mod    service.ModerationService
+	boards service.BoardService
+}
+
+func NewModerationHandler(m service.ModerationService) *ModerationHandler {
+	return &ModerationHandler{mod: m}
+}
+
+// WithBoards serves the public logs of boards by slug
+func (h *ModerationHandler) WithBoards(b service.BoardService) *ModerationHandler {
+	h.boards = b
+	return h
+}
+
+// moderationError maps service errors to a status code and message
+func moderationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: posts must differ, the comment range must be ordered and the title set, deletions need a reason", http.StatusBadRequest)
+	case errors.Is(err, service.ErrEmptyRange):
+		http.Error(w, "no comments of the post in range", http.StatusBadRequest)
+	case errors.Is(err, service.ErrBoardArchived):
+		http.Error(w, "board is archived", http.StatusForbidden)
+	case errors.Is(err, service.ErrPostLocked):
+		http.Error(w, "post is locked", http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func formID(r *http.Request, key string) int64 {
+	id, _ := strconv.ParseInt(r.FormValue(key), 10, 64)
+	return id
+}
+
+func redirectToPost(w http.ResponseWriter, r *http.Request, id int64) {
+	http.Redirect(w, r, "/post/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
+
+// POST /post/{id}/move (form: board_id)
+func (h *ModerationHandler) MoveForm(w http.ResponseWriter, r *http.Request) {
+	id := postID(r)
+	if err := h.mod.MovePost(r.Context(), id, formID(r, "board_id")); err != nil {
+		moderationError(w, err)
+		return
+	}
+	redirectToPost(w, r, id)
+}
+
+// POST /post/{id}/merge (form: into_id)
+func (h *ModerationHandler) MergeForm(w http.ResponseWriter, r *http.Request) {
+	into := formID(r, "into_id")
+	if err := h.mod.MergePosts(r.Context(), postID(r), into); err != nil {
+		moderationError(w, err)
+		return
+	}
+	redirectToPost(w, r, into)
+}
+
+// POST /post/{id}/split (form: from_comment_id, to_comment_id, title)
+func (h *ModerationHandler) SplitForm(w http.ResponseWriter, r *http.Request) {
+	newID, err := h.mod.SplitPost(r.Context(), postID(r), formID(r, "from_comment_id"), formID(r, "to_comment_id"), r.FormValue("title"))
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	redirectToPost(w, r, newID)
+}
+
+// POST /api/post/{id}/move {"board_id"}
+func (h *ModerationHandler) MoveJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		BoardID int64 `json:"board_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.mod.MovePost(r.Context(), postID(r), in.BoardID); err != nil {
+		moderationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/post/{id}/merge {"into_id"}
+func (h *ModerationHandler) MergeJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		IntoID int64 `json:"into_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := h.mod.MergePosts(r.Context(), postID(r), in.IntoID); err != nil {
+		moderationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/post/{id}/split {"from_comment_id", "to_comment_id", "title"} — returns {"id"} of the new post
+func (h *ModerationHandler) SplitJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		FromCommentID int64  `json:"from_comment_id"`
+		ToCommentID   int64  `json:"to_comment_id"`
+		Title         string `json:"title"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	id, err := h.mod.SplitPost(r.Context(), postID(r), in.FromCommentID, in.ToCommentID, in.Title)
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+}
+
+// POST /post/{id}/delete (form: reason, spam, back)
+func (h *ModerationHandler) DeletePostForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.mod.DeletePost(r.Context(), postID(r), r.FormValue("reason"), r.FormValue("spam") != ""); err != nil {
+		moderationError(w, err)
+		return
+	}
+	http.Redirect(w, r, localBack(r), http.StatusSeeOther)
+}
+
+// POST /comment/{id}/delete (form: reason, spam, back)
+func (h *ModerationHandler) DeleteCommentForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.mod.DeleteComment(r.Context(), commentID(r), r.FormValue("reason"), r.FormValue("spam") != ""); err != nil {
+		moderationError(w, err)
+		return
+	}
+	http.Redirect(w, r, localBack(r), http.StatusSeeOther)
+}
+
+func commentID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// deleteJSON runs a moderator deletion of id with the {"reason", "spam"}
+// of the body
+func deleteJSON(w http.ResponseWriter, r *http.Request, id int64, del func(context.Context, int64, string, bool) error) {
+	var in struct {
+		Reason string `json:"reason"`
+		Spam   bool   `json:"spam"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if err := del(r.Context(), id, in.Reason, in.Spam); err != nil {
+		moderationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/mod/posts/{id} {"reason", "spam"}
+func (h *ModerationHandler) DeletePostJSON(w http.ResponseWriter, r *http.Request) {
+	deleteJSON(w, r, postID(r), h.mod.DeletePost)
+}
+
+// DELETE /api/mod/comments/{id} {"reason", "spam"}
+func (h *ModerationHandler) DeleteCommentJSON(w http.ResponseWriter, r *http.Request) {
+	deleteJSON(w, r, commentID(r), h.mod.DeleteComment)
+}
+
+// GET /mod/queue?board=&page=
+func (h *ModerationHandler) QueuePageHTML(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	board, _ := strconv.ParseInt(r.URL.Query().Get("board"), 10, 64)
+	items, err := h.mod.Held(r.Context(), board, page)
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	var boards []entity.Board
+	if h.boards != nil {
+		boards, _ = h.boards.List(r.Context())
+	}
+	data := map[string]interface{}{"Items": items, "Boards": boards, "BoardID": board, "Page": page}
+	if page > 1 {
+		data["PrevPage"] = page - 1
+	}
+	if len(items) > 0 {
+		data["NextPage"] = page + 1
+	}
+	utils.RenderTemplate(w, r, "mod_queue_page.html", data)
+}
+
+// approveTarget approves the post or comment of the {type} and {id} vars
+func (h *ModerationHandler) approveTarget(r *http.Request) error {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if mux.Vars(r)["type"] == entity.TargetComment {
+		return h.mod.ApproveComment(r.Context(), id)
+	}
+	return h.mod.ApprovePost(r.Context(), id)
+}
+
+// POST /mod/queue/{type:post|comment}/{id}/approve (form: back)
+func (h *ModerationHandler) ApproveForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.approveTarget(r); err != nil {
+		moderationError(w, err)
+		return
+	}
+	http.Redirect(w, r, localBack(r), http.StatusSeeOther)
+}
+
+// GET /api/mod/queue?board_id=&page=
+func (h *ModerationHandler) QueueJSON(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	board, _ := strconv.ParseInt(r.URL.Query().Get("board_id"), 10, 64)
+	items, err := h.mod.Held(r.Context(), board, page)
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	if items == nil {
+		items = []entity.HeldItem{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(items)
+}
+
+// POST /api/mod/queue/{type:post|comment}/{id}/approve
+func (h *ModerationHandler) ApproveJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.approveTarget(r); err != nil {
+		moderationError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// modLogFilter reads ?action=&target_type=&moderator_id=&board_id=
+func modLogFilter(r *http.Request) entity.ModActionFilter {
+	q := r.URL.Query()
+	f := entity.ModActionFilter{Action: q.Get("action"), TargetType: q.Get("target_type")}
+	f.ModeratorID, _ = strconv.ParseInt(q.Get("moderator_id"), 10, 64)
+	f.BoardID, _ = strconv.ParseInt(q.Get("board_id"), 10, 64)
+	return f
+}
+
+// GET /mod/log?page=&action=&target_type=&moderator_id=&board_id=
+func (h *ModerationHandler) LogPageHTML(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	f := modLogFilter(r)
+	actions, err := h.mod.Log(r.Context(), f, page)
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	// the filter again, for the page links; Encode escapes it already
+	q := r.URL.Query()
+	q.Del("page")
+	data := map[string]interface{}{"Actions": actions, "Page": page, "Filter": f, "Query": template.URL(q.Encode()),
+		"ActionKinds": entity.ModActions, "TargetTypes": []string{entity.TargetPost, entity.TargetComment, entity.TargetUser, entity.TargetBoard, entity.TargetCategory}}
+	if page > 1 {
+		data["PrevPage"] = page - 1
+	}
+	if len(actions) > 0 {
+		data["NextPage"] = page + 1
+	}
+	utils.RenderTemplate(w, r, "mod_log_page.html", data)
+}
+
+// GET /api/mod/actions?page=&action=&target_type=&moderator_id=&board_id=
+func (h *ModerationHandler) LogJSON(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	actions, err := h.mod.Log(r.Context(), modLogFilter(r), page)
+	if err != nil {
+		moderationError(w, err)
+		return
+	}
+	if actions == nil {
+		actions = []entity.ModAction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(actions)
+}
+
+// GET /board/{slug}/modlog?page=
+func (h *ModerationHandler) BoardLogPageHTML(w http.ResponseWriter, r *http.Request) {
+	b, err := h.boards.GetBySlug(r.Context(), mux.Vars(r)["slug"])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	actions, err := h.mod.BoardLog(r.Context(), b.ID, page)
+	if errors.Is(err, service.ErrForbidden) {
+		http.Error(w, "this board does not publish its moderation log", http.StatusNotFound)
+		return
+	} else if err != nil {
+		moderationError(w, err)
+		return
+	}
+	data := map[string]interface{}{"Board": b, "Actions": actions, "Page": page}
+	if page > 1 {
+		data["PrevPage"] = page - 1
+	}
+	if len(actions) > 0 {
+		data["NextPage"] = page + 1
+	}
+	utils.RenderTemplate(w, r, "board_mod_log_page.html", data)
+}
+
+// GET /api/boards/{id}/modlog?page=
+func (h *ModerationHandler) BoardLogJSON(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	actions, err := h.mod.BoardLog(r.Context(), boardID(r), page)
+	if errors.Is(err, service.ErrForbidden) {
+		http.Error(w, "this board does not publish its moderation log", http.StatusNotFound)
+		return
+	} else if err != nil {
+		moderationError(w, err)
+		return
+	}
+	if actions == nil {
+		actions = []entity.ModAction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(actions)
+}
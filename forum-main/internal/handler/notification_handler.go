@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+)
+
+type NotificationHandler struct {
+	notifications service.NotificationService
+}
+
+func NewNotificationHandler(n service.NotificationService) *NotificationHandler {
+	return &NotificationHandler{notifications: n}
+}
+
+// GET /notifications — lists the notifications and marks them read
+func (h *NotificationHandler) NotificationsPageHTML(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	list, err := h.notifications.List(r.Context(), uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := h.notifications.MarkAllRead(r.Context(), uid); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	utils.RenderTemplate(w, r, "notifications_page.html", map[string]interface{}{"Notifications": list})
+}
+
+// GET /api/notifications — latest notifications and the unread count
+func (h *NotificationHandler) NotificationsJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	list, err := h.notifications.List(r.Context(), uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	unread, err := h.notifications.UnreadCount(r.Context(), uid)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []entity.Notification{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{"unread": unread, "notifications": list})
+}
+
+// POST /api/notifications/read
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err := h.notifications.MarkAllRead(r.Context(), uid); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
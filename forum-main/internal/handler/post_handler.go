@@ -1,22 +1,73 @@
 package handler
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"forum1/internal/entity"
-	"forum1/internal/repository"
 	"forum1/internal/service"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
 )
 
+// maxPreviewWarmers bounds the link previews fetched in the background at
+// once; links over the limit are left for the post page to fetch
+const maxPreviewWarmers = 4
+
 type PostHandler struct {
-	svc   service.PostService
-	users repository.UserRepository
+	svc      service.PostService
+	previews service.LinkPreviewService
+
+	warmSlots chan struct{}
+	warmMu    sync.Mutex
+	warming   map[string]bool // links being fetched
+}
+
+func NewPostHandler(svc service.PostService) *PostHandler {
+	return &PostHandler{svc: svc}
 }
 
-func NewPostHandler(svc service.PostService, users repository.UserRepository) *PostHandler {
-	return &PostHandler{svc: svc, users: users}
+// WithPreviews enables unfurling post links right after creation
+func (h *PostHandler) WithPreviews(p service.LinkPreviewService) *PostHandler {
+	h.previews = p
+	h.warmSlots = make(chan struct{}, maxPreviewWarmers)
+	h.warming = make(map[string]bool)
+	return h
+}
+
+// warmPreview fetches the link preview in the background so the post page
+// usually finds it cached
+func (h *PostHandler) warmPreview(p *entity.Post) {
+	if h.previews == nil || p.LinkURL == "" {
+		return
+	}
+	link := p.LinkURL
+	h.warmMu.Lock()
+	defer h.warmMu.Unlock()
+	if h.warming[link] {
+		return
+	}
+	select {
+	case h.warmSlots <- struct{}{}:
+	default:
+		return
+	}
+	h.warming[link] = true
+	go func() {
+		defer func() {
+			h.warmMu.Lock()
+			delete(h.warming, link)
+			h.warmMu.Unlock()
+			<-h.warmSlots
+		}()
+		_, _ = h.previews.Get(context.Background(), link)
+	}()
 }
 
 func (h *PostHandler) HomePage(w http.ResponseWriter, r *http.Request) {
@@ -30,19 +81,27 @@ func (h *PostHandler) GetPostPage(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
-	if ct := r.Header.Get("Content-Type"); ct != "" && (ct == "application/json" || ct[:16] == "application/json") {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		u := currentUser(r)
+		if u == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
 		var p entity.Post
 		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
 			http.Error(w, "bad json", http.StatusBadRequest)
 			return
 		}
+		// the author is whoever is signed in, never what the body claims
+		p.AuthorID, p.Pending, p.HoldReason = int(u.ID), false, ""
 		id, err := h.svc.CreatePost(r.Context(), &p)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			createPostError(w, err)
 			return
 		}
+		h.warmPreview(&p)
 		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"id": id})
+		_ = json.NewEncoder(w).Encode(map[string]any{"id": id, "pending": p.Pending})
 		return
 	}
 	if err := r.ParseMultipartForm(10 << 20); err != nil {
@@ -52,32 +111,45 @@ func (h *PostHandler) CreatePost(w http.ResponseWriter, r *http.Request) {
 	boardID, _ := strconv.ParseInt(r.FormValue("board_id"), 10, 64)
 	title := r.FormValue("title")
 	content := r.FormValue("content")
+	linkURL := r.FormValue("link_url")
 	var imageData []byte
 	file, _, err := r.FormFile("image")
 	if err == nil && file != nil {
 		defer file.Close()
 		imageData, _ = io.ReadAll(file)
 	}
-	// Resolve author from cookie
-	c, errCookie := r.Cookie("user")
-	if errCookie != nil || c.Value == "" {
+	u := currentUser(r)
+	if u == nil {
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
-	u, err := h.users.GetUserByName(r.Context(), c.Value)
-	if err != nil || u == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
-	p := &entity.Post{BoardID: int(boardID), Title: title, Content: content, AuthorID: int(u.ID), ImageData: imageData}
+	p := &entity.Post{BoardID: int(boardID), Title: title, Content: content, AuthorID: int(u.ID), ImageData: imageData, LinkURL: linkURL}
 	id, err := h.svc.CreatePost(r.Context(), p)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		createPostError(w, err)
 		return
 	}
+	h.warmPreview(p)
 	http.Redirect(w, r, "/post/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
 }
 
+// createPostError reports a rejected post, board settings violations are 403
+func createPostError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "only moderators may post in this board", http.StatusForbidden)
+	case errors.Is(err, service.ErrBoardArchived), errors.Is(err, service.ErrMembersOnly),
+		errors.Is(err, service.ErrAccountTooNew), errors.Is(err, service.ErrImagesDisabled),
+		errors.Is(err, service.ErrLinksDisabled), errors.Is(err, service.ErrSanctioned),
+		errors.Is(err, service.ErrTrustLevel), errors.Is(err, service.ErrContentBlocked):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "board not found", http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
 func (h *PostHandler) UpdatePost(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
@@ -86,12 +158,93 @@ func (h *PostHandler) DeletePost(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// postFlagError maps errors of the moderator actions on a post
+func postFlagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid post id", http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "post not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func postID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// setFlagForm sets a moderator flag of the post and goes back to its page
+func setFlagForm(w http.ResponseWriter, r *http.Request, set func(context.Context, int64, bool) error, v bool) {
+	id := postID(r)
+	if err := set(r.Context(), id, v); err != nil {
+		postFlagError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/post/"+strconv.FormatInt(id, 10), http.StatusSeeOther)
+}
+
+// setFlagJSON sets a moderator flag of the post on POST, clears it on DELETE
+func setFlagJSON(w http.ResponseWriter, r *http.Request, set func(context.Context, int64, bool) error) {
+	if err := set(r.Context(), postID(r), r.Method != http.MethodDelete); err != nil {
+		postFlagError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /post/{id}/pin
+func (h *PostHandler) PinForm(w http.ResponseWriter, r *http.Request) {
+	setFlagForm(w, r, h.svc.SetPinned, true)
+}
+
+// POST /post/{id}/unpin
+func (h *PostHandler) UnpinForm(w http.ResponseWriter, r *http.Request) {
+	setFlagForm(w, r, h.svc.SetPinned, false)
+}
+
+// POST /post/{id}/lock
+func (h *PostHandler) LockForm(w http.ResponseWriter, r *http.Request) {
+	setFlagForm(w, r, h.svc.SetLocked, true)
+}
+
+// POST /post/{id}/unlock
+func (h *PostHandler) UnlockForm(w http.ResponseWriter, r *http.Request) {
+	setFlagForm(w, r, h.svc.SetLocked, false)
+}
+
+// POST|DELETE /api/post/{id}/pin
+func (h *PostHandler) PinJSON(w http.ResponseWriter, r *http.Request) {
+	setFlagJSON(w, r, h.svc.SetPinned)
+}
+
+// POST|DELETE /api/post/{id}/lock
+func (h *PostHandler) LockJSON(w http.ResponseWriter, r *http.Request) {
+	setFlagJSON(w, r, h.svc.SetLocked)
+}
+
+// GetPostsJSON lists posts page by page: ?board_id=&sort=&period=&cursor=&limit=
 func (h *PostHandler) GetPostsJSON(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	posts, err := h.svc.GetAllPosts(r.Context())
-	if err != nil {
+	opts := listOptions(r)
+	if b := r.URL.Query().Get("board_id"); b != "" {
+		id, err := strconv.ParseInt(b, 10, 64)
+		if err != nil {
+			http.Error(w, "bad board_id", http.StatusBadRequest)
+			return
+		}
+		opts.BoardID = id
+	}
+	page, err := h.svc.ListPosts(r.Context(), opts)
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "bad listing parameters", http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, "failed to fetch posts", http.StatusInternalServerError)
 		return
 	}
-	_ = json.NewEncoder(w).Encode(posts)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(page)
 }
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ReportHandler takes user reports and serves the moderation queue
+type ReportHandler struct {
+	reports service.ReportService
+}
+
+func NewReportHandler(s service.ReportService) *ReportHandler {
+	return &ReportHandler{reports: s}
+}
+
+// reportError maps service errors to a status code and message
+func reportError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: target must exist and not be yours, reason is spam, abuse, offtopic, illegal or other (with details), action is dismiss, delete, warn or ban, warnings and bans need a note and can't target yourself", http.StatusBadRequest)
+	case errors.Is(err, service.ErrAlreadyReported):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "no open reports", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// reportTarget is the {type}/{id} of queue routes
+func reportTarget(r *http.Request) (string, int64) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return mux.Vars(r)["type"], id
+}
+
+// localBack is the local page to return to after a form, "/" otherwise
+func localBack(r *http.Request) string {
+	back := r.FormValue("back")
+	if !strings.HasPrefix(back, "/") || strings.HasPrefix(back, "//") {
+		return "/"
+	}
+	return back
+}
+
+// POST /report (form: target_type, target_id, reason, details, back)
+func (h *ReportHandler) ReportForm(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	rep := &entity.Report{TargetType: r.FormValue("target_type"), TargetID: formID(r, "target_id"),
+		Reason: r.FormValue("reason"), Details: r.FormValue("details")}
+	if _, err := h.reports.Report(r.Context(), rep); err != nil && !errors.Is(err, service.ErrAlreadyReported) {
+		reportError(w, err)
+		return
+	}
+	back, _ := url.Parse(localBack(r))
+	q := back.Query()
+	q.Set("reported", "1")
+	back.RawQuery = q.Encode()
+	http.Redirect(w, r, back.String(), http.StatusSeeOther)
+}
+
+// POST /api/reports {"target_type", "target_id", "reason", "details"}
+func (h *ReportHandler) ReportJSON(w http.ResponseWriter, r *http.Request) {
+	var rep entity.Report
+	if err := json.NewDecoder(r.Body).Decode(&rep); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.reports.Report(r.Context(), &rep); err != nil {
+		reportError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+// GET /mod/reports
+func (h *ReportHandler) QueuePageHTML(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	groups, err := h.reports.Queue(r.Context())
+	if err != nil {
+		reportError(w, err)
+		return
+	}
+	utils.RenderTemplate(w, r, "mod_reports_page.html", map[string]interface{}{"Groups": groups})
+}
+
+// GET /api/mod/reports
+func (h *ReportHandler) QueueJSON(w http.ResponseWriter, r *http.Request) {
+	groups, err := h.reports.Queue(r.Context())
+	if err != nil {
+		reportError(w, err)
+		return
+	}
+	if groups == nil {
+		groups = []entity.ReportGroup{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(groups)
+}
+
+// POST /mod/reports/{type}/{id} (form: action, note)
+func (h *ReportHandler) ResolveForm(w http.ResponseWriter, r *http.Request) {
+	targetType, id := reportTarget(r)
+	if err := h.reports.Resolve(r.Context(), targetType, id, r.FormValue("action"), r.FormValue("note")); err != nil {
+		reportError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/mod/reports", http.StatusSeeOther)
+}
+
+// POST /api/mod/reports/{type}/{id} {"action", "note"}
+func (h *ReportHandler) ResolveJSON(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Action string `json:"action"`
+		Note   string `json:"note"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	targetType, id := reportTarget(r)
+	if err := h.reports.Resolve(r.Context(), targetType, id, in.Action, in.Note); err != nil {
+		reportError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
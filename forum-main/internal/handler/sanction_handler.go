@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// SanctionHandler serves warnings, suspensions and bans: the moderator
+// page and API, and the page where users see their own sanctions
+type SanctionHandler struct {
+	sanctions service.SanctionService
+	boards    service.BoardService
+}
+
+func NewSanctionHandler(s service.SanctionService) *SanctionHandler {
+	return &SanctionHandler{sanctions: s}
+}
+
+// WithBoards offers board bans on the moderator page
+func (h *SanctionHandler) WithBoards(b service.BoardService) *SanctionHandler {
+	h.boards = b
+	return h
+}
+
+// sanctionError maps service errors to a status code and message
+func sanctionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid input: kind is warning, suspension, ban or board_ban, the reason is required, suspensions need a future end and board bans a board; you can't sanction yourself", http.StatusBadRequest)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "sanction not found or already revoked", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GET /sanctions shows the signed in user their sanctions and marks them seen
+func (h *SanctionHandler) OwnPageHTML(w http.ResponseWriter, r *http.Request) {
+	u := currentUser(r)
+	if u == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	list, err := h.sanctions.ForUser(r.Context(), u.ID)
+	if err != nil {
+		sanctionError(w, err)
+		return
+	}
+	if err := h.sanctions.MarkSeen(r.Context()); err != nil {
+		sanctionError(w, err)
+		return
+	}
+	utils.RenderTemplate(w, r, "sanctions_page.html", map[string]interface{}{"Sanctions": list, "Now": time.Now()})
+}
+
+// GET /mod/sanctions?user={id}
+func (h *SanctionHandler) ModPageHTML(w http.ResponseWriter, r *http.Request) {
+	if currentUser(r) == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	userID := formID(r, "user")
+	if userID <= 0 {
+		http.Error(w, "user is required", http.StatusBadRequest)
+		return
+	}
+	list, err := h.sanctions.ForUser(r.Context(), userID)
+	if err != nil {
+		sanctionError(w, err)
+		return
+	}
+	var boards []entity.Board
+	if h.boards != nil {
+		boards, _ = h.boards.List(r.Context())
+	}
+	utils.RenderTemplate(w, r, "mod_sanctions_page.html", map[string]interface{}{
+		"UserID": userID, "Sanctions": list, "Boards": boards, "Now": time.Now(),
+	})
+}
+
+// POST /mod/sanctions (form: user_id, kind, reason, board_id, days; no
+// days is no end)
+func (h *SanctionHandler) IssueForm(w http.ResponseWriter, r *http.Request) {
+	sn := &entity.Sanction{UserID: formID(r, "user_id"), Kind: r.FormValue("kind"),
+		Reason: r.FormValue("reason"), BoardID: formID(r, "board_id")}
+	if days := formID(r, "days"); days > 0 {
+		end := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+		sn.EndsAt = &end
+	}
+	if sn.Kind != entity.SanctionBoardBan {
+		sn.BoardID = 0
+	}
+	if _, err := h.sanctions.Issue(r.Context(), sn); err != nil {
+		sanctionError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/mod/sanctions?user="+strconv.FormatInt(sn.UserID, 10), http.StatusSeeOther)
+}
+
+// POST /mod/sanctions/{id}/revoke (form: user_id)
+func (h *SanctionHandler) RevokeForm(w http.ResponseWriter, r *http.Request) {
+	if err := h.sanctions.Revoke(r.Context(), sanctionID(r)); err != nil {
+		sanctionError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/mod/sanctions?user="+strconv.FormatInt(formID(r, "user_id"), 10), http.StatusSeeOther)
+}
+
+func sanctionID(r *http.Request) int64 {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	return id
+}
+
+// GET /api/users/{id}/sanctions
+func (h *SanctionHandler) ListJSON(w http.ResponseWriter, r *http.Request) {
+	list, err := h.sanctions.ForUser(r.Context(), sanctionID(r))
+	if err != nil {
+		sanctionError(w, err)
+		return
+	}
+	if list == nil {
+		list = []entity.Sanction{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// POST /api/users/{id}/sanctions {"kind", "reason", "board_id", "ends_at"}
+func (h *SanctionHandler) IssueJSON(w http.ResponseWriter, r *http.Request) {
+	var sn entity.Sanction
+	if err := json.NewDecoder(r.Body).Decode(&sn); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	sn.UserID = sanctionID(r)
+	if _, err := h.sanctions.Issue(r.Context(), &sn); err != nil {
+		sanctionError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(sn)
+}
+
+// DELETE /api/sanctions/{id}
+func (h *SanctionHandler) RevokeJSON(w http.ResponseWriter, r *http.Request) {
+	if err := h.sanctions.Revoke(r.Context(), sanctionID(r)); err != nil {
+		sanctionError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
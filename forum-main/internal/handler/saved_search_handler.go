@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type SavedSearchHandler struct {
+	saved service.SavedSearchService
+}
+
+func NewSavedSearchHandler(s service.SavedSearchService) *SavedSearchHandler {
+	return &SavedSearchHandler{saved: s}
+}
+
+// savedSearchError maps service errors to a status code and message
+func savedSearchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "invalid saved search", http.StatusBadRequest)
+	case errors.Is(err, service.ErrSavedSearchLimit):
+		http.Error(w, "too many saved searches", http.StatusConflict)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "saved search not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /search/save (form: q, mode) — save the current search, back to /settings
+func (h *SavedSearchHandler) SaveSearch(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	if _, err := h.saved.Save(r.Context(), uid, r.FormValue("q"), r.FormValue("mode")); err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// POST /settings/searches/{id}/mode (form: mode)
+func (h *SavedSearchHandler) SetModeForm(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := h.saved.SetMode(r.Context(), uid, id, r.FormValue("mode")); err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// POST /settings/searches/{id}/delete
+func (h *SavedSearchHandler) DeleteForm(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := h.saved.Delete(r.Context(), uid, id); err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
+
+// GET /api/saved-searches
+func (h *SavedSearchHandler) ListJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	list, err := h.saved.List(r.Context(), uid)
+	if err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	if list == nil {
+		list = []entity.SavedSearch{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(list)
+}
+
+// POST /api/saved-searches {"query": "...", "mode": "instant|digest"}
+func (h *SavedSearchHandler) CreateJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var in struct {
+		Query string `json:"query"`
+		Mode  string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	ss, err := h.saved.Save(r.Context(), uid, in.Query, in.Mode)
+	if err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(ss)
+}
+
+// PUT /api/saved-searches/{id} {"mode": "instant|digest"}
+func (h *SavedSearchHandler) UpdateJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	var in struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := h.saved.SetMode(r.Context(), uid, id, in.Mode); err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DELETE /api/saved-searches/{id}
+func (h *SavedSearchHandler) DeleteJSON(w http.ResponseWriter, r *http.Request) {
+	uid := currentUserID(r)
+	if uid == 0 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err := h.saved.Delete(r.Context(), uid, id); err != nil {
+		savedSearchError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+)
+
+type SearchHandler struct {
+	search service.SearchService
+}
+
+func NewSearchHandler(s service.SearchService) *SearchHandler {
+	return &SearchHandler{search: s}
+}
+
+// searchQuery reads ?q=&type=&page= of a search request
+func searchQuery(r *http.Request) entity.SearchQuery {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	return entity.SearchQuery{Text: r.URL.Query().Get("q"), Type: r.URL.Query().Get("type"), Page: page}
+}
+
+// searchTabs are the result types of the search page, in display order
+var searchTabs = []struct{ Type, Title string }{
+	{entity.SearchPosts, "Посты"},
+	{entity.SearchComments, "Комментарии"},
+	{entity.SearchBoards, "Доски"},
+	{entity.SearchUsers, "Пользователи"},
+}
+
+// GET /search?q=&type=&page=
+func (h *SearchHandler) SearchPageHTML(w http.ResponseWriter, r *http.Request) {
+	res, err := h.search.Search(r.Context(), searchQuery(r))
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "Неверный запрос", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	counts := map[string]int{
+		entity.SearchPosts:    res.Counts.Posts,
+		entity.SearchComments: res.Counts.Comments,
+		entity.SearchBoards:   res.Counts.Boards,
+		entity.SearchUsers:    res.Counts.Users,
+	}
+	tabs := make([]map[string]interface{}, len(searchTabs))
+	for i, t := range searchTabs {
+		tabs[i] = map[string]interface{}{"Type": t.Type, "Title": t.Title, "Count": counts[t.Type], "Active": t.Type == res.Type}
+	}
+	data := map[string]interface{}{"Results": res, "Query": res.Query, "Type": res.Type, "Tabs": tabs, "CanSave": currentUserID(r) != 0}
+	if res.Page > 1 {
+		data["PrevPage"] = res.Page - 1
+	}
+	if res.HasNext {
+		data["NextPage"] = res.Page + 1
+	}
+	utils.RenderTemplate(w, r, "search_page.html", data)
+}
+
+// GET /api/search?q=&type=&page=
+func (h *SearchHandler) SearchJSON(w http.ResponseWriter, r *http.Request) {
+	res, err := h.search.Search(r.Context(), searchQuery(r))
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "invalid query", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "search failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// GET /api/search/suggest?q= — typeahead of the search box
+func (h *SearchHandler) SuggestJSON(w http.ResponseWriter, r *http.Request) {
+	res, err := h.search.Suggest(r.Context(), r.URL.Query().Get("q"))
+	if errors.Is(err, service.ErrInvalidInput) {
+		http.Error(w, "invalid query", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		http.Error(w, "suggest failed", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// GET /boards/search — boards are part of the regular search results now
+func (h *SearchHandler) BoardsSearchPageHTML(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/search?"+r.URL.RawQuery, http.StatusMovedPermanently)
+}
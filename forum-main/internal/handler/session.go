@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"forum1/internal/entity"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSessionTTL is how long a sign in lasts
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+const sessionCookie = "session"
+
+// CSRFField is the form field and CSRFHeader the header that carry the
+// CSRF token of a request that changes something
+const (
+	CSRFField  = "csrf"
+	CSRFHeader = "X-CSRF-Token"
+)
+
+// Sessions signs the session cookie of signed in users and derives the
+// CSRF token of each session from it. The cookie holds the user id and the
+// expiry with their HMAC, so it can't be forged or extended without the
+// key.
+type Sessions struct {
+	key []byte
+	ttl time.Duration
+}
+
+func NewSessions(key []byte, ttl time.Duration) *Sessions {
+	return &Sessions{key: key, ttl: ttl}
+}
+
+// Start signs u in and returns the CSRF token of the new session
+func (s *Sessions) Start(w http.ResponseWriter, u *entity.User) string {
+	payload := strconv.FormatInt(u.ID, 10) + "." + strconv.FormatInt(time.Now().Add(s.ttl).Unix(), 10)
+	value := payload + "." + s.mac("session|"+payload)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: value, Path: "/", MaxAge: int(s.ttl.Seconds()),
+		HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	return s.csrfToken(value)
+}
+
+// userID returns the user of a session cookie value, false when the value
+// is forged or expired
+func (s *Sessions) userID(value string) (int64, bool) {
+	i := strings.LastIndexByte(value, '.')
+	if i < 0 {
+		return 0, false
+	}
+	payload, sig := value[:i], value[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(s.mac("session|"+payload))) {
+		return 0, false
+	}
+	idStr, expStr, _ := strings.Cut(payload, ".")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil || time.Now().Unix() >= exp {
+		return 0, false
+	}
+	return id, true
+}
+
+func (s *Sessions) csrfToken(session string) string {
+	return s.mac("csrf|" + session)
+}
+
+func (s *Sessions) mac(msg string) string {
+	m := hmac.New(sha256.New, s.key)
+	m.Write([]byte(msg))
+	return base64.RawURLEncoding.EncodeToString(m.Sum(nil))
+}
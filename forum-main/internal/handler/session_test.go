@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"forum1/internal/entity"
+	"forum1/utils"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startSession(t *testing.T, s *Sessions, userID int64) (cookie, csrf string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	csrf = s.Start(w, &entity.User{ID: userID})
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sessionCookie || !cookies[0].HttpOnly {
+		t.Fatalf("got cookies %+v", cookies)
+	}
+	return cookies[0].Value, csrf
+}
+
+func TestSessionCookie(t *testing.T) {
+	s := NewSessions([]byte("key"), time.Hour)
+	value, _ := startSession(t, s, 42)
+	if id, ok := s.userID(value); !ok || id != 42 {
+		t.Fatalf("got user %d (ok %v), want 42", id, ok)
+	}
+
+	payload := value[:strings.LastIndexByte(value, '.')]
+	sig := value[len(payload):]
+	expired, _ := startSession(t, NewSessions([]byte("key"), -time.Minute), 42)
+	for name, v := range map[string]string{
+		"empty":          "",
+		"plain username": "admin",
+		"other user":     "1" + strings.TrimPrefix(payload, "42") + sig,
+		"longer expiry":  "42.9999999999" + sig,
+		"no signature":   payload,
+		"other key":      func() string { v, _ := startSession(t, NewSessions([]byte("other"), time.Hour), 42); return v }(),
+		"expired":        expired,
+	} {
+		if id, ok := s.userID(v); ok {
+			t.Errorf("%s: accepted as user %d", name, id)
+		}
+	}
+}
+
+func TestCheckCSRF(t *testing.T) {
+	s := NewSessions([]byte("key"), time.Hour)
+	value, token := startSession(t, s, 1)
+	if token != s.csrfToken(value) {
+		t.Fatal("Start returned a token other than the session's")
+	}
+	_, other := startSession(t, s, 2)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	tests := []struct {
+		name     string
+		method   string
+		signedIn bool
+		form     string
+		header   string
+		want     int
+	}{
+		{"read", http.MethodGet, true, "", "", http.StatusNoContent},
+		{"guest", http.MethodPost, false, "", "", http.StatusNoContent},
+		{"no token", http.MethodPost, true, "", "", http.StatusForbidden},
+		{"form token", http.MethodPost, true, token, "", http.StatusNoContent},
+		{"header token", http.MethodDelete, true, "", token, http.StatusNoContent},
+		{"token of another session", http.MethodPost, true, other, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", strings.NewReader(url.Values{CSRFField: {tt.form}}.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.header != "" {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			if tt.signedIn {
+				r = r.WithContext(utils.ContextWithCSRFToken(r.Context(), token))
+			}
+			w := httptest.NewRecorder()
+			CheckCSRF(next).ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("got status %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
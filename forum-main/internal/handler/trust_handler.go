@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"forum1/internal/service"
+	"forum1/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// TrustHandler shows users their trust level and what the next one takes
+type TrustHandler struct {
+	trust service.TrustService
+}
+
+func NewTrustHandler(t service.TrustService) *TrustHandler {
+	return &TrustHandler{trust: t}
+}
+
+// trustError maps service errors to a status code and message
+func trustError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrForbidden):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	case errors.Is(err, service.ErrInvalidInput):
+		http.Error(w, "user not found", http.StatusNotFound)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GET /trust, moderators may add ?user={id}
+func (h *TrustHandler) PageHTML(w http.ResponseWriter, r *http.Request) {
+	u := currentUser(r)
+	if u == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	userID := u.ID
+	if id := formID(r, "user"); id > 0 {
+		userID = id
+	}
+	p, err := h.trust.Progress(r.Context(), userID)
+	if err != nil {
+		trustError(w, err)
+		return
+	}
+	utils.RenderTemplate(w, r, "trust_page.html", map[string]interface{}{"Progress": p, "Own": userID == u.ID})
+}
+
+// GET /api/users/{id}/trust — the user or a moderator
+func (h *TrustHandler) ProgressJSON(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	p, err := h.trust.Progress(r.Context(), id)
+	if err != nil {
+		trustError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(p)
+}
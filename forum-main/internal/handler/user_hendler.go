@@ -7,11 +7,12 @@ import (
 )
 
 type UserHandler struct {
-	service service.UserService
+	service  service.UserService
+	sessions *Sessions
 }
 
-func NewUserHandler(s service.UserService) *UserHandler {
-	return &UserHandler{service: s}
+func NewUserHandler(s service.UserService, sessions *Sessions) *UserHandler {
+	return &UserHandler{service: s, sessions: sessions}
 }
 
 func (h *UserHandler) RegisterPage(w http.ResponseWriter, r *http.Request) {
@@ -42,9 +43,10 @@ func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Неверные данные", http.StatusUnauthorized)
 		return
 	}
-	http.SetCookie(w, &http.Cookie{Name: "user", Value: u.Username, Path: "/", HttpOnly: true})
+	csrf := h.sessions.Start(w, u)
 	if r.Header.Get("Accept") == "application/json" {
-		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+		// API clients send the token back in the X-CSRF-Token header
+		_ = json.NewEncoder(w).Encode(map[string]string{"status": "ok", "csrf": csrf})
 		return
 	}
 	http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -1,40 +0,0 @@
-package handlers
-
-import (
-	"encoding/json"
-	"forum1/internal/models"
-	"net/http"
-	"strconv"
-)
-
-func GetAllPostsAPI(w http.ResponseWriter, r *http.Request) {
-	posts, err := models.GetAllPosts()
-	if err != nil {
-		http.Error(w, "Ошибка при получении постов", http.StatusInternalServerError)
-		return
-	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
-}
-
-func GetPostByIDAPI(w http.ResponseWriter, r *http.Request) {
-	idStr := r.URL.Query().Get("id")
-	if idStr == "" {
-		http.Error(w, "Не передан id", http.StatusBadRequest)
-		return
-	}
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Некорректный id", http.StatusBadRequest)
-		return
-	}
-
-	post, err := models.GetPostByID(id)
-	if err != nil {
-		http.Error(w, "Пост не найден", http.StatusNotFound)
-		return
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(post)
-}
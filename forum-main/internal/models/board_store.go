@@ -0,0 +1,39 @@
+package models
+
+import (
+	"forum1/db"
+	"forum1/internal/entity"
+)
+
+func GetBoardBySlug(slug string) (*entity.Board, error) {
+	b := &entity.Board{}
+	err := db.DB.QueryRow(`
+		SELECT id, slug, title, COALESCE(description, ''), position, archived_at IS NOT NULL,
+		       COALESCE(category_id, 0), COALESCE(parent_id, 0), COALESCE(club_id, 0)
+		FROM boards WHERE slug=$1
+	`, slug).Scan(&b.ID, &b.Slug, &b.Title, &b.Description, &b.Position, &b.Archived, &b.CategoryID, &b.ParentID, &b.ClubID)
+	return b, err
+}
+
+func GetAllBoards() ([]entity.Board, error) {
+	rows, err := db.DB.Query(`
+		SELECT id, slug, title, COALESCE(description, ''), position, archived_at IS NOT NULL,
+		       COALESCE(category_id, 0), COALESCE(parent_id, 0), COALESCE(club_id, 0)
+		FROM boards
+		ORDER BY position, title
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var boards []entity.Board
+	for rows.Next() {
+		var b entity.Board
+		if err := rows.Scan(&b.ID, &b.Slug, &b.Title, &b.Description, &b.Position, &b.Archived, &b.CategoryID, &b.ParentID, &b.ClubID); err != nil {
+			return nil, err
+		}
+		boards = append(boards, b)
+	}
+	return boards, nil
+}
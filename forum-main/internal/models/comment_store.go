@@ -28,7 +28,7 @@ func GetCommentsByPost(postID int) ([]entity.Comment, error) {
 			COALESCE(SUM(CASE WHEN cv.value=-1 THEN 1 ELSE 0 END),0) AS dislikes
 		FROM comments c
 		LEFT JOIN comment_votes cv ON cv.comment_id = c.id
-		WHERE c.post_id = $1
+		WHERE c.post_id = $1 AND NOT c.pending
 		GROUP BY c.id
 		ORDER BY c.created_at ASC`, postID)
 	if err != nil {
@@ -3,12 +3,126 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"forum1/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrBoardArchived is returned for writes to an archived board
+	ErrBoardArchived = errors.New("board is archived")
+	// ErrPostLocked is returned for comments and votes on a locked post
+	ErrPostLocked = errors.New("post is locked")
+	// ErrSlugTaken is returned when a board slug is already in use
+	ErrSlugTaken = errors.New("slug is taken")
+	// ErrUnknownReference is returned when a board is saved with a category,
+	// parent or club that doesn't exist
+	ErrUnknownReference = errors.New("category, parent board or club not found")
+)
+
+// boardOpen, postOpen and commentOpen are SQL conditions that hold when
+// the board (post, comment) with id param takes new content: the board is
+// not archived and the post is not locked. Writes guard themselves with
+// these, so the check and the write can't race.
+func boardOpen(param string) string {
+	return `EXISTS (SELECT 1 FROM boards WHERE id = ` + param + ` AND archived_at IS NULL)`
+}
+
+func postOpen(param string) string {
+	return `EXISTS (SELECT 1 FROM posts p JOIN boards b ON b.id = p.board_id
+        WHERE p.id = ` + param + ` AND b.archived_at IS NULL AND NOT p.locked)`
+}
+
+func commentOpen(param string) string {
+	return `EXISTS (SELECT 1 FROM comments c JOIN posts p ON p.id = c.post_id JOIN boards b ON b.id = p.board_id
+        WHERE c.id = ` + param + ` AND b.archived_at IS NULL AND NOT p.locked)`
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// isForeignKeyViolation reports whether err is a PostgreSQL
+// foreign_key_violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+// guardedRow maps the empty result of a guarded INSERT ... SELECT
+func guardedRow(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrBoardArchived
+	}
+	return err
+}
+
+// closedReason tells why a postOpen or commentOpen guard refused a write:
+// sql.ErrNoRows when the target is gone or hidden from viewer, else
+// ErrPostLocked or ErrBoardArchived. closedQuery selects the locked flag of
+// the post by $1 and whether $2 may see it.
+func closedReason(ctx context.Context, db *sql.DB, closedQuery string, id, viewer int64, err error) error {
+	if !errors.Is(err, ErrBoardArchived) {
+		return err
+	}
+	var locked, visible bool
+	switch qerr := db.QueryRowContext(ctx, closedQuery, id, viewer).Scan(&locked, &visible); {
+	case errors.Is(qerr, sql.ErrNoRows), qerr == nil && !visible:
+		return sql.ErrNoRows
+	case qerr == nil && locked:
+		return ErrPostLocked
+	}
+	return err
+}
+
+var (
+	postClosedQuery    = `SELECT locked, ` + postVisible("$1", "$2") + ` FROM posts WHERE id=$1`
+	commentClosedQuery = `SELECT p.locked, ` + postVisible("c.post_id", "$2") + ` AND ` + heldVisible("c", "$2") + `
+        FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id=$1`
 )
 
+// guardedExec maps a guarded statement that changed nothing
+func guardedExec(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrBoardArchived
+	}
+	return nil
+}
+
 type BoardRepository interface {
 	GetBySlug(ctx context.Context, slug string) (*entity.Board, error)
+	GetByID(ctx context.Context, id int64) (*entity.Board, error)
 	List(ctx context.Context) ([]entity.Board, error)
+	ListByClub(ctx context.Context, clubID int64) ([]entity.Board, error)
+	// The writes below record a, when not nil, in the audit log in the
+	// same transaction; Create fills in its target
+	Create(ctx context.Context, b *entity.Board, a *entity.ModAction) (int64, error)
+	Update(ctx context.Context, b *entity.Board, a *entity.ModAction) error
+	SetArchived(ctx context.Context, id int64, archived bool, a *entity.ModAction) error
+	// Reorder gives the boards positions in the order of ids
+	Reorder(ctx context.Context, ids []int64, a *entity.ModAction) error
+	UpdateSettings(ctx context.Context, id int64, st entity.BoardSettings, a *entity.ModAction) error
+	// IsMember reports whether the user is a member of the board's club
+	IsMember(ctx context.Context, boardID, userID int64) (bool, error)
+	// ApprovedContributions counts the published posts and comments of the
+	// user, for pre-moderation
+	ApprovedContributions(ctx context.Context, userID int64) (int, error)
+	// Stats returns the activity of every board by board id
+	Stats(ctx context.Context) (map[int64]entity.BoardStats, error)
+
+	ListCategories(ctx context.Context) ([]entity.BoardCategory, error)
+	GetCategory(ctx context.Context, id int64) (*entity.BoardCategory, error)
+	CreateCategory(ctx context.Context, c *entity.BoardCategory, a *entity.ModAction) (int64, error)
+	UpdateCategory(ctx context.Context, c *entity.BoardCategory, a *entity.ModAction) error
+	// DeleteCategory leaves its boards without a category
+	DeleteCategory(ctx context.Context, id int64, a *entity.ModAction) error
+	ReorderCategories(ctx context.Context, ids []int64, a *entity.ModAction) error
 }
 
 func NewBoardRepository(db *sql.DB) BoardRepository {
@@ -17,16 +131,48 @@ func NewBoardRepository(db *sql.DB) BoardRepository {
 
 type boardRepository struct{ db *sql.DB }
 
+const boardColumns = `id, slug, title, COALESCE(description, ''), position, archived_at IS NOT NULL,
+        COALESCE(category_id, 0), COALESCE(parent_id, 0), COALESCE(club_id, 0),
+        rules, post_permission, allow_images, allow_links, min_account_age_days, post_template, public_mod_log,
+        premod_account_age_days, premod_min_approved`
+
+func scanBoard(row interface{ Scan(...any) error }, b *entity.Board) error {
+	st := &b.Settings
+	return row.Scan(&b.ID, &b.Slug, &b.Title, &b.Description, &b.Position, &b.Archived, &b.CategoryID, &b.ParentID, &b.ClubID,
+		&st.Rules, &st.PostPermission, &st.AllowImages, &st.AllowLinks, &st.MinAccountAgeDays, &st.PostTemplate, &st.PublicModLog,
+		&st.PremodAccountAgeDays, &st.PremodMinApproved)
+}
+
 func (r *boardRepository) GetBySlug(ctx context.Context, slug string) (*entity.Board, error) {
-	row := r.db.QueryRowContext(ctx, `SELECT id, slug, title, description FROM boards WHERE slug=$1`, slug)
+	row := r.db.QueryRowContext(ctx, `SELECT `+boardColumns+` FROM boards WHERE slug=$1 AND `+boardVisible("id", "$2"), slug, viewerID(ctx))
 	var b entity.Board
-	if err := row.Scan(&b.ID, &b.Slug, &b.Title, &b.Description); err != nil {
+	if err := scanBoard(row, &b); err != nil {
 		return nil, err
 	}
 	return &b, nil
 }
+
+func (r *boardRepository) GetByID(ctx context.Context, id int64) (*entity.Board, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+boardColumns+` FROM boards WHERE id=$1 AND `+boardVisible("id", "$2"), id, viewerID(ctx))
+	var b entity.Board
+	if err := scanBoard(row, &b); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
 func (r *boardRepository) List(ctx context.Context) ([]entity.Board, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, slug, title, description FROM boards ORDER BY title`)
+	return r.list(ctx, `SELECT `+boardColumns+` FROM boards WHERE `+boardVisible("id", "$1")+`
+        ORDER BY position, title`, viewerID(ctx))
+}
+
+func (r *boardRepository) ListByClub(ctx context.Context, clubID int64) ([]entity.Board, error) {
+	return r.list(ctx, `SELECT `+boardColumns+` FROM boards WHERE club_id=$1 AND `+boardVisible("id", "$2")+`
+        ORDER BY position, title`, clubID, viewerID(ctx))
+}
+
+func (r *boardRepository) list(ctx context.Context, query string, args ...any) ([]entity.Board, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -34,10 +180,200 @@ func (r *boardRepository) List(ctx context.Context) ([]entity.Board, error) {
 	var res []entity.Board
 	for rows.Next() {
 		var b entity.Board
-		if err := rows.Scan(&b.ID, &b.Slug, &b.Title, &b.Description); err != nil {
+		if err := scanBoard(rows, &b); err != nil {
 			return nil, err
 		}
 		res = append(res, b)
 	}
 	return res, nil
 }
+
+// Create appends the board at the end of the list
+func (r *boardRepository) Create(ctx context.Context, b *entity.Board, a *entity.ModAction) (int64, error) {
+	err := audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO boards (slug, title, description, category_id, parent_id, club_id, position)
+            VALUES ($1, $2, $3, NULLIF($4, 0), NULLIF($5, 0), NULLIF($6, 0), (SELECT COALESCE(MAX(position), 0) + 1 FROM boards))
+            RETURNING id, position`, b.Slug, b.Title, b.Description, b.CategoryID, b.ParentID, b.ClubID).Scan(&b.ID, &b.Position)
+		if err == nil && a != nil {
+			a.TargetID, a.BoardID = b.ID, b.ID
+		}
+		return err
+	})
+	if isUniqueViolation(err) {
+		return 0, ErrSlugTaken
+	}
+	if isForeignKeyViolation(err) {
+		return 0, ErrUnknownReference
+	}
+	if err != nil {
+		return 0, err
+	}
+	return b.ID, nil
+}
+
+func (r *boardRepository) Update(ctx context.Context, b *entity.Board, a *entity.ModAction) error {
+	err := audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `
+            UPDATE boards SET slug=$2, title=$3, description=$4, category_id=NULLIF($5, 0), parent_id=NULLIF($6, 0),
+                   club_id=NULLIF($7, 0), updated_at=now()
+            WHERE id=$1`, b.ID, b.Slug, b.Title, b.Description, b.CategoryID, b.ParentID, b.ClubID)
+	})
+	if isUniqueViolation(err) {
+		return ErrSlugTaken
+	}
+	if isForeignKeyViolation(err) {
+		return ErrUnknownReference
+	}
+	return err
+}
+
+func (r *boardRepository) SetArchived(ctx context.Context, id int64, archived bool, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `
+            UPDATE boards SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, now()) END, updated_at=now()
+            WHERE id=$1`, id, archived)
+	})
+}
+
+func (r *boardRepository) Reorder(ctx context.Context, ids []int64, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		for i, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE boards SET position=$2 WHERE id=$1`, id, i+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *boardRepository) UpdateSettings(ctx context.Context, id int64, st entity.BoardSettings, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `
+            UPDATE boards SET rules=$2, post_permission=$3, allow_images=$4, allow_links=$5,
+                   min_account_age_days=$6, post_template=$7, public_mod_log=$8,
+                   premod_account_age_days=$9, premod_min_approved=$10, updated_at=now()
+            WHERE id=$1`, id, st.Rules, st.PostPermission, st.AllowImages, st.AllowLinks, st.MinAccountAgeDays, st.PostTemplate, st.PublicModLog,
+			st.PremodAccountAgeDays, st.PremodMinApproved)
+	})
+}
+
+func (r *boardRepository) IsMember(ctx context.Context, boardID, userID int64) (bool, error) {
+	var ok bool
+	err := r.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM boards b JOIN club_members m ON m.club_id = b.club_id
+                       WHERE b.id=$1 AND m.user_id=$2)`, boardID, userID).Scan(&ok)
+	return ok, err
+}
+
+func (r *boardRepository) ApprovedContributions(ctx context.Context, userID int64) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+        SELECT (SELECT COUNT(*) FROM posts WHERE author_id=$1 AND NOT pending)
+             + (SELECT COUNT(*) FROM comments WHERE author_id=$1 AND NOT pending)`, userID).Scan(&n)
+	return n, err
+}
+
+// Stats aggregates all boards in one query; held content doesn't count
+func (r *boardRepository) Stats(ctx context.Context) (map[int64]entity.BoardStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH post_stats AS (
+            SELECT board_id, COUNT(*) AS posts, MAX(created_at) AS last_post_at
+            FROM posts WHERE NOT pending GROUP BY board_id
+        ), comment_stats AS (
+            SELECT p.board_id, MAX(c.created_at) AS last_comment_at
+            FROM comments c JOIN posts p ON p.id = c.post_id WHERE NOT c.pending AND NOT p.pending GROUP BY p.board_id
+        ), last_posts AS (
+            SELECT DISTINCT ON (board_id) board_id, id, title
+            FROM posts WHERE NOT pending ORDER BY board_id, created_at DESC, id DESC
+        )
+        SELECT b.id, COALESCE(ps.posts, 0), COALESCE(lp.id, 0), COALESCE(lp.title, ''),
+               ps.last_post_at, GREATEST(ps.last_post_at, cs.last_comment_at)
+        FROM boards b
+        LEFT JOIN post_stats ps ON ps.board_id = b.id
+        LEFT JOIN comment_stats cs ON cs.board_id = b.id
+        LEFT JOIN last_posts lp ON lp.board_id = b.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := make(map[int64]entity.BoardStats)
+	for rows.Next() {
+		var id int64
+		var st entity.BoardStats
+		var lastPost, lastActivity sql.NullTime
+		if err := rows.Scan(&id, &st.Posts, &st.LastPostID, &st.LastPostTitle, &lastPost, &lastActivity); err != nil {
+			return nil, err
+		}
+		st.LastPostAt, st.LastActivityAt = lastPost.Time, lastActivity.Time
+		res[id] = st
+	}
+	return res, rows.Err()
+}
+
+func (r *boardRepository) ListCategories(ctx context.Context) ([]entity.BoardCategory, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, title, position FROM board_categories ORDER BY position, title`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.BoardCategory
+	for rows.Next() {
+		var c entity.BoardCategory
+		if err := rows.Scan(&c.ID, &c.Title, &c.Position); err != nil {
+			return nil, err
+		}
+		res = append(res, c)
+	}
+	return res, rows.Err()
+}
+
+func (r *boardRepository) GetCategory(ctx context.Context, id int64) (*entity.BoardCategory, error) {
+	var c entity.BoardCategory
+	err := r.db.QueryRowContext(ctx, `SELECT id, title, position FROM board_categories WHERE id=$1`, id).
+		Scan(&c.ID, &c.Title, &c.Position)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *boardRepository) CreateCategory(ctx context.Context, c *entity.BoardCategory, a *entity.ModAction) (int64, error) {
+	err := audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO board_categories (title, position)
+            VALUES ($1, (SELECT COALESCE(MAX(position), 0) + 1 FROM board_categories))
+            RETURNING id, position`, c.Title).Scan(&c.ID, &c.Position)
+		if err == nil && a != nil {
+			a.TargetID = c.ID
+		}
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return c.ID, nil
+}
+
+func (r *boardRepository) UpdateCategory(ctx context.Context, c *entity.BoardCategory, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `UPDATE board_categories SET title=$2 WHERE id=$1`, c.ID, c.Title)
+	})
+}
+
+func (r *boardRepository) DeleteCategory(ctx context.Context, id int64, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `DELETE FROM board_categories WHERE id=$1`, id)
+	})
+}
+
+func (r *boardRepository) ReorderCategories(ctx context.Context, ids []int64, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		for i, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE board_categories SET position=$2 WHERE id=$1`, id, i+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -3,13 +3,34 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"forum1/internal/entity"
 )
 
 type ClubRepository interface {
-	Create(ctx context.Context, club *entity.Club) (int64, error)
+	// Create adds the club with ownerID as its owner
+	Create(ctx context.Context, club *entity.Club, ownerID int64) (int64, error)
 	GetByID(ctx context.Context, id int64) (*entity.Club, error)
 	List(ctx context.Context) ([]entity.Club, error)
+	SetJoinPolicy(ctx context.Context, id int64, policy string) error
+	SetPrivate(ctx context.Context, id int64, private bool) error
+
+	// Role returns the user's role in the club, "" for non members
+	Role(ctx context.Context, clubID, userID int64) (string, error)
+	Members(ctx context.Context, clubID int64) ([]entity.ClubMember, error)
+	// AddMember also drops the user's pending request or invitation
+	AddMember(ctx context.Context, clubID, userID int64, role string) error
+	RemoveMember(ctx context.Context, clubID, userID int64) error
+	SetMemberRole(ctx context.Context, clubID, userID int64, role string) error
+
+	// CreateRequest adds a request or invitation, replacing a pending one
+	CreateRequest(ctx context.Context, clubID, userID int64, kind string) error
+	// Request returns the kind of the user's pending request, sql.ErrNoRows if none
+	Request(ctx context.Context, clubID, userID int64) (string, error)
+	DeleteRequest(ctx context.Context, clubID, userID int64) error
+	Requests(ctx context.Context, clubID int64) ([]entity.ClubRequest, error)
+	// Invitations lists the clubs the user is invited to
+	Invitations(ctx context.Context, userID int64) ([]entity.ClubRequest, error)
 }
 
 func NewClubRepository(db *sql.DB) ClubRepository {
@@ -20,28 +41,42 @@ type clubRepository struct {
 	db *sql.DB
 }
 
-func (r *clubRepository) Create(ctx context.Context, club *entity.Club) (int64, error) {
-	query := `INSERT INTO clubs (name, topic, description) VALUES ($1, $2, $3) RETURNING id`
-	err := r.db.QueryRowContext(ctx, query, club.Name, club.Topic, club.Description).Scan(&club.ID)
+const clubColumns = `c.id, c.name, c.topic, COALESCE(c.description, ''), c.join_policy, c.private,
+        (SELECT COUNT(*) FROM club_members m WHERE m.club_id = c.id)`
+
+func scanClub(row interface{ Scan(...any) error }, c *entity.Club) error {
+	return row.Scan(&c.ID, &c.Name, &c.Topic, &c.Description, &c.JoinPolicy, &c.Private, &c.MemberCount)
+}
+
+func (r *clubRepository) Create(ctx context.Context, club *entity.Club, ownerID int64) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, `INSERT INTO clubs (name, topic, description, join_policy, private) VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		club.Name, club.Topic, club.Description, club.JoinPolicy, club.Private).Scan(&club.ID)
 	if err != nil {
 		return 0, err
 	}
-	return club.ID, nil
+	if _, err := tx.ExecContext(ctx, `INSERT INTO club_members (club_id, user_id, role) VALUES ($1, $2, $3)`,
+		club.ID, ownerID, entity.ClubRoleOwner); err != nil {
+		return 0, err
+	}
+	club.MemberCount = 1
+	return club.ID, tx.Commit()
 }
 
 func (r *clubRepository) GetByID(ctx context.Context, id int64) (*entity.Club, error) {
-	query := `SELECT id, name, topic, description FROM clubs WHERE id=$1`
-	row := r.db.QueryRowContext(ctx, query, id)
-
 	var c entity.Club
-	if err := row.Scan(&c.ID, &c.Name, &c.Topic, &c.Description); err != nil {
+	if err := scanClub(r.db.QueryRowContext(ctx, `SELECT `+clubColumns+` FROM clubs c WHERE c.id=$1`, id), &c); err != nil {
 		return nil, err
 	}
 	return &c, nil
 }
 
 func (r *clubRepository) List(ctx context.Context) ([]entity.Club, error) {
-	rows, err := r.db.QueryContext(ctx, `SELECT id, name, topic, description FROM clubs ORDER BY name`)
+	rows, err := r.db.QueryContext(ctx, `SELECT `+clubColumns+` FROM clubs c ORDER BY c.name`)
 	if err != nil {
 		return nil, err
 	}
@@ -50,10 +85,130 @@ func (r *clubRepository) List(ctx context.Context) ([]entity.Club, error) {
 	var res []entity.Club
 	for rows.Next() {
 		var c entity.Club
-		if err := rows.Scan(&c.ID, &c.Name, &c.Topic, &c.Description); err != nil {
+		if err := scanClub(rows, &c); err != nil {
 			return nil, err
 		}
 		res = append(res, c)
 	}
-	return res, nil
+	return res, rows.Err()
+}
+
+// execOne runs a statement that must change exactly one row
+func execOne(ctx context.Context, q execer, query string, args ...any) error {
+	res, err := q.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *clubRepository) SetJoinPolicy(ctx context.Context, id int64, policy string) error {
+	return execOne(ctx, r.db, `UPDATE clubs SET join_policy=$2 WHERE id=$1`, id, policy)
+}
+
+func (r *clubRepository) SetPrivate(ctx context.Context, id int64, private bool) error {
+	return execOne(ctx, r.db, `UPDATE clubs SET private=$2 WHERE id=$1`, id, private)
+}
+
+func (r *clubRepository) Role(ctx context.Context, clubID, userID int64) (string, error) {
+	var role string
+	err := r.db.QueryRowContext(ctx, `SELECT role FROM club_members WHERE club_id=$1 AND user_id=$2`, clubID, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return role, err
+}
+
+func (r *clubRepository) Members(ctx context.Context, clubID int64) ([]entity.ClubMember, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT m.user_id, u.username, m.role, m.joined_at
+        FROM club_members m JOIN users u ON u.id = m.user_id
+        WHERE m.club_id=$1
+        ORDER BY CASE m.role WHEN 'owner' THEN 0 WHEN 'officer' THEN 1 ELSE 2 END, m.joined_at`, clubID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.ClubMember
+	for rows.Next() {
+		var m entity.ClubMember
+		if err := rows.Scan(&m.UserID, &m.Username, &m.Role, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, rows.Err()
+}
+
+func (r *clubRepository) AddMember(ctx context.Context, clubID, userID int64, role string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO club_members (club_id, user_id, role) VALUES ($1, $2, $3)
+        ON CONFLICT (club_id, user_id) DO NOTHING`, clubID, userID, role); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM club_join_requests WHERE club_id=$1 AND user_id=$2`, clubID, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *clubRepository) RemoveMember(ctx context.Context, clubID, userID int64) error {
+	return execOne(ctx, r.db, `DELETE FROM club_members WHERE club_id=$1 AND user_id=$2`, clubID, userID)
+}
+
+func (r *clubRepository) SetMemberRole(ctx context.Context, clubID, userID int64, role string) error {
+	return execOne(ctx, r.db, `UPDATE club_members SET role=$3 WHERE club_id=$1 AND user_id=$2`, clubID, userID, role)
+}
+
+func (r *clubRepository) CreateRequest(ctx context.Context, clubID, userID int64, kind string) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO club_join_requests (club_id, user_id, kind) VALUES ($1, $2, $3)
+        ON CONFLICT (club_id, user_id) DO UPDATE SET kind=EXCLUDED.kind, created_at=now()`, clubID, userID, kind)
+	return err
+}
+
+func (r *clubRepository) Request(ctx context.Context, clubID, userID int64) (string, error) {
+	var kind string
+	err := r.db.QueryRowContext(ctx, `SELECT kind FROM club_join_requests WHERE club_id=$1 AND user_id=$2`, clubID, userID).Scan(&kind)
+	return kind, err
+}
+
+func (r *clubRepository) DeleteRequest(ctx context.Context, clubID, userID int64) error {
+	return execOne(ctx, r.db, `DELETE FROM club_join_requests WHERE club_id=$1 AND user_id=$2`, clubID, userID)
+}
+
+func (r *clubRepository) Requests(ctx context.Context, clubID int64) ([]entity.ClubRequest, error) {
+	return r.requests(ctx, `WHERE q.club_id=$1`, clubID)
+}
+
+func (r *clubRepository) Invitations(ctx context.Context, userID int64) ([]entity.ClubRequest, error) {
+	return r.requests(ctx, `WHERE q.user_id=$1 AND q.kind='invite'`, userID)
+}
+
+func (r *clubRepository) requests(ctx context.Context, where string, arg int64) ([]entity.ClubRequest, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT q.club_id, c.name, q.user_id, u.username, q.kind, q.created_at
+        FROM club_join_requests q JOIN users u ON u.id = q.user_id JOIN clubs c ON c.id = q.club_id
+        `+where+` ORDER BY q.created_at`, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.ClubRequest
+	for rows.Next() {
+		var q entity.ClubRequest
+		if err := rows.Scan(&q.ClubID, &q.ClubName, &q.UserID, &q.Username, &q.Kind, &q.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+	}
+	return res, rows.Err()
 }
@@ -11,7 +11,6 @@ type CommentRepository interface {
 	GetCommentsByPost(ctx context.Context, postID int64) ([]entity.Comment, error)
 	GetCommentByID(ctx context.Context, id int64) (*entity.Comment, error)
 	DeleteComment(ctx context.Context, id int64) error
-	ForceDeleteComment(ctx context.Context, id int64) error
 	SetCommentVote(ctx context.Context, commentID int64, userID int64, value int) error
 	GetCommentVotes(ctx context.Context, commentID int64) (likes int, dislikes int, err error)
 }
@@ -25,22 +24,24 @@ type commentRepository struct{ db *sql.DB }
 func (r *commentRepository) CreateComment(ctx context.Context, c *entity.Comment) (int64, error) {
 	var id int64
 	err := r.db.QueryRowContext(ctx, `
-        INSERT INTO comments (post_id, author_id, content)
-        VALUES ($1,$2,$3)
-        RETURNING id`, c.PostID, c.AuthorID, c.Content,
+        INSERT INTO comments (post_id, author_id, content, pending, hold_reason)
+        SELECT $1,$2,$3,$4,$5 WHERE `+postOpen("$1")+` AND `+postVisible("$1", "$2")+`
+          AND `+notSanctioned("$2", "(SELECT board_id FROM posts WHERE id = $1)")+`
+        RETURNING id`, c.PostID, c.AuthorID, c.Content, c.Pending, c.HoldReason,
 	).Scan(&id)
-	return id, err
+	err = sanctionReason(ctx, r.db, c.AuthorID, postBoard, c.PostID, guardedRow(err))
+	return id, closedReason(ctx, r.db, postClosedQuery, c.PostID, c.AuthorID, err)
 }
 func (r *commentRepository) GetCommentsByPost(ctx context.Context, postID int64) ([]entity.Comment, error) {
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT c.id, c.post_id, c.author_id, c.content, c.created_at, c.updated_at,
+        SELECT c.id, c.post_id, c.author_id, c.content, c.created_at, c.updated_at, c.pending, c.hold_reason,
                COALESCE(SUM(CASE WHEN cv.value=1 THEN 1 ELSE 0 END),0) AS likes,
                COALESCE(SUM(CASE WHEN cv.value=-1 THEN 1 ELSE 0 END),0) AS dislikes
         FROM comments c
         LEFT JOIN comment_votes cv ON cv.comment_id = c.id
-        WHERE c.post_id = $1
+        WHERE c.post_id = $1 AND `+postVisible("c.post_id", "$2")+` AND `+heldVisible("c", "$2")+`
         GROUP BY c.id
-        ORDER BY c.created_at ASC`, postID)
+        ORDER BY c.created_at ASC`, postID, viewerID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +49,7 @@ func (r *commentRepository) GetCommentsByPost(ctx context.Context, postID int64)
 	var out []entity.Comment
 	for rows.Next() {
 		var c entity.Comment
-		if err := rows.Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Likes, &c.Dislikes); err != nil {
+		if err := rows.Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Pending, &c.HoldReason, &c.Likes, &c.Dislikes); err != nil {
 			return nil, err
 		}
 		out = append(out, c)
@@ -58,9 +59,9 @@ func (r *commentRepository) GetCommentsByPost(ctx context.Context, postID int64)
 func (r *commentRepository) GetCommentByID(ctx context.Context, id int64) (*entity.Comment, error) {
 	var c entity.Comment
 	err := r.db.QueryRowContext(ctx, `
-        SELECT id, post_id, author_id, content, created_at, updated_at
-        FROM comments WHERE id=$1`, id,
-	).Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt)
+        SELECT id, post_id, author_id, content, created_at, updated_at, pending, hold_reason
+        FROM comments WHERE id=$1 AND `+postVisible("post_id", "$2")+` AND `+heldVisible("comments", "$2"), id, viewerID(ctx),
+	).Scan(&c.ID, &c.PostID, &c.AuthorID, &c.Content, &c.CreatedAt, &c.UpdatedAt, &c.Pending, &c.HoldReason)
 	if err != nil {
 		return nil, err
 	}
@@ -70,17 +71,15 @@ func (r *commentRepository) DeleteComment(ctx context.Context, id int64) error {
 	_, err := r.db.ExecContext(ctx, `DELETE FROM comments WHERE id=$1`, id)
 	return err
 }
-func (r *commentRepository) ForceDeleteComment(ctx context.Context, id int64) error {
-	_, err := r.db.ExecContext(ctx, `DELETE FROM comments WHERE id=$1`, id)
-	return err
-}
 
 func (r *commentRepository) SetCommentVote(ctx context.Context, commentID int64, userID int64, value int) error {
-	_, err := r.db.ExecContext(ctx, `
+	res, err := r.db.ExecContext(ctx, `
         INSERT INTO comment_votes (comment_id, user_id, value)
-        VALUES ($1,$2,$3)
+        SELECT $1,$2,$3 WHERE `+commentOpen("$1")+`
+          AND `+notSanctioned("$2", "(SELECT p.board_id FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = $1)")+`
         ON CONFLICT (comment_id,user_id) DO UPDATE SET value=EXCLUDED.value`, commentID, userID, value)
-	return err
+	err = sanctionReason(ctx, r.db, userID, commentBoard, commentID, guardedExec(res, err))
+	return closedReason(ctx, r.db, commentClosedQuery, commentID, userID, err)
 }
 
 func (r *commentRepository) GetCommentVotes(ctx context.Context, commentID int64) (likes int, dislikes int, err error) {
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"forum1/internal/entity"
+)
+
+// ErrRuleExists is returned when a rule of the same kind and pattern exists
+var ErrRuleExists = errors.New("rule already exists")
+
+// ContentRuleRepository stores the content rules
+type ContentRuleRepository interface {
+	List(ctx context.Context) ([]entity.ContentRule, error)
+	GetByID(ctx context.Context, id int64) (*entity.ContentRule, error)
+	// Create and Delete record a, when not nil, in the audit log in the
+	// same transaction; Create fills in its target
+	Create(ctx context.Context, rule *entity.ContentRule, a *entity.ModAction) (int64, error)
+	Delete(ctx context.Context, id int64, a *entity.ModAction) error
+}
+
+func NewContentRuleRepository(db *sql.DB) ContentRuleRepository {
+	return &contentRuleRepository{db: db}
+}
+
+type contentRuleRepository struct{ db *sql.DB }
+
+const contentRuleColumns = `id, kind, pattern, regex, replacement, note, COALESCE(created_by, 0), created_at`
+
+func scanContentRule(row interface{ Scan(...any) error }, rule *entity.ContentRule) error {
+	return row.Scan(&rule.ID, &rule.Kind, &rule.Pattern, &rule.Regex, &rule.Replacement, &rule.Note, &rule.CreatedBy, &rule.CreatedAt)
+}
+
+func (r *contentRuleRepository) List(ctx context.Context) ([]entity.ContentRule, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+contentRuleColumns+` FROM content_rules ORDER BY kind, pattern`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rules []entity.ContentRule
+	for rows.Next() {
+		var rule entity.ContentRule
+		if err := scanContentRule(rows, &rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *contentRuleRepository) GetByID(ctx context.Context, id int64) (*entity.ContentRule, error) {
+	var rule entity.ContentRule
+	if err := scanContentRule(r.db.QueryRowContext(ctx, `SELECT `+contentRuleColumns+` FROM content_rules WHERE id=$1`, id), &rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *contentRuleRepository) Create(ctx context.Context, rule *entity.ContentRule, a *entity.ModAction) (int64, error) {
+	err := audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+            INSERT INTO content_rules (kind, pattern, regex, replacement, note, created_by)
+            VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0))
+            RETURNING id, created_at`, rule.Kind, rule.Pattern, rule.Regex, rule.Replacement, rule.Note, rule.CreatedBy).
+			Scan(&rule.ID, &rule.CreatedAt)
+		if err == nil && a != nil {
+			a.TargetID = rule.ID
+		}
+		return err
+	})
+	if isUniqueViolation(err) {
+		return 0, ErrRuleExists
+	}
+	if err != nil {
+		return 0, err
+	}
+	return rule.ID, nil
+}
+
+func (r *contentRuleRepository) Delete(ctx context.Context, id int64, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, `DELETE FROM content_rules WHERE id=$1`, id)
+	})
+}
@@ -0,0 +1,179 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+	"strconv"
+	"strings"
+)
+
+type EventRepository interface {
+	Create(ctx context.Context, e *entity.Event) (int64, error)
+	// GetByID returns the event with the RSVP counts and the answer of the
+	// user in ctx
+	GetByID(ctx context.Context, id int64) (*entity.Event, error)
+	Delete(ctx context.Context, id int64) error
+	// List returns the events matching f, ordered by start
+	List(ctx context.Context, f entity.EventFilter) ([]entity.Event, error)
+
+	// SetRSVP answers for the user, sql.ErrNoRows if the event is not visible to them
+	SetRSVP(ctx context.Context, eventID, userID int64, status string) error
+	RSVPs(ctx context.Context, eventID int64) ([]entity.EventRSVP, error)
+
+	// CalendarToken returns the user's feed token, "" if none
+	CalendarToken(ctx context.Context, userID int64) (string, error)
+	SetCalendarToken(ctx context.Context, userID int64, token string) error
+	// UserByCalendarToken returns sql.ErrNoRows for unknown tokens
+	UserByCalendarToken(ctx context.Context, token string) (int64, error)
+}
+
+func NewEventRepository(db *sql.DB) EventRepository {
+	return &eventRepository{db: db}
+}
+
+type eventRepository struct {
+	db *sql.DB
+}
+
+// eventVisible is an SQL condition that holds when the event e is visible
+// to the user with id param viewer
+func eventVisible(viewer string) string {
+	return clubVisible("e.club_id", viewer) + ` AND ` + boardVisible("e.board_id", viewer)
+}
+
+// eventColumns are read by scanEvent, $1 is the viewer
+const eventColumns = `e.id, COALESCE(e.club_id, 0), COALESCE(e.board_id, 0), e.author_id, e.title, e.description,
+        e.starts_at, e.ends_at, e.timezone, e.location, e.rrule, e.created_at,
+        COALESCE(c.name, ''), COALESCE(b.slug, ''), COALESCE(b.title, ''),
+        (SELECT COUNT(*) FROM event_rsvps r WHERE r.event_id = e.id AND r.status = 'going'),
+        (SELECT COUNT(*) FROM event_rsvps r WHERE r.event_id = e.id AND r.status = 'maybe'),
+        COALESCE((SELECT r.status FROM event_rsvps r WHERE r.event_id = e.id AND r.user_id = $1), '')`
+
+const eventTables = `events e
+        LEFT JOIN clubs c ON c.id = e.club_id
+        LEFT JOIN boards b ON b.id = e.board_id`
+
+func scanEvent(row interface{ Scan(...any) error }, e *entity.Event) error {
+	return row.Scan(&e.ID, &e.ClubID, &e.BoardID, &e.AuthorID, &e.Title, &e.Description,
+		&e.StartsAt, &e.EndsAt, &e.Timezone, &e.Location, &e.RRule, &e.CreatedAt,
+		&e.ClubName, &e.BoardSlug, &e.BoardTitle, &e.Going, &e.Maybe, &e.MyRSVP)
+}
+
+func (r *eventRepository) Create(ctx context.Context, e *entity.Event) (int64, error) {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO events (club_id, board_id, author_id, title, description, starts_at, ends_at, timezone, location, rrule)
+        VALUES (NULLIF($1, 0), NULLIF($2, 0), $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at`,
+		e.ClubID, e.BoardID, e.AuthorID, e.Title, e.Description, e.StartsAt, e.EndsAt, e.Timezone, e.Location, e.RRule,
+	).Scan(&e.ID, &e.CreatedAt)
+	return e.ID, err
+}
+
+func (r *eventRepository) GetByID(ctx context.Context, id int64) (*entity.Event, error) {
+	var e entity.Event
+	row := r.db.QueryRowContext(ctx, `SELECT `+eventColumns+` FROM `+eventTables+`
+        WHERE e.id = $2 AND `+eventVisible("$1"), viewerID(ctx), id)
+	if err := scanEvent(row, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (r *eventRepository) Delete(ctx context.Context, id int64) error {
+	return execOne(ctx, r.db, `DELETE FROM events WHERE id=$1`, id)
+}
+
+func (r *eventRepository) List(ctx context.Context, f entity.EventFilter) ([]entity.Event, error) {
+	args := []any{viewerID(ctx)}
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	where := []string{eventVisible("$1")}
+	if f.ClubID != 0 {
+		where = append(where, "e.club_id = "+arg(f.ClubID))
+	}
+	if f.BoardID != 0 {
+		where = append(where, "e.board_id = "+arg(f.BoardID))
+	}
+	if f.UserID != 0 {
+		p := arg(f.UserID)
+		where = append(where, `(e.club_id IN (SELECT club_id FROM club_members WHERE user_id = `+p+`)
+            OR e.id IN (SELECT event_id FROM event_rsvps WHERE user_id = `+p+` AND status IN ('going', 'maybe')))`)
+	}
+	if !f.Since.IsZero() {
+		// recurring events may still have occurrences ahead
+		where = append(where, "(e.rrule <> '' OR e.ends_at >= "+arg(f.Since)+")")
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "e.starts_at < "+arg(f.Until))
+	}
+	rows, err := r.db.QueryContext(ctx, `SELECT `+eventColumns+` FROM `+eventTables+`
+        WHERE `+strings.Join(where, " AND ")+`
+        ORDER BY e.starts_at, e.id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []entity.Event
+	for rows.Next() {
+		var e entity.Event
+		if err := scanEvent(rows, &e); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (r *eventRepository) SetRSVP(ctx context.Context, eventID, userID int64, status string) error {
+	return execOne(ctx, r.db, `
+        INSERT INTO event_rsvps (event_id, user_id, status)
+        SELECT e.id, $2, $3 FROM events e WHERE e.id = $1 AND `+eventVisible("$2")+`
+        ON CONFLICT (event_id, user_id) DO UPDATE SET status = EXCLUDED.status, updated_at = NOW()`,
+		eventID, userID, status)
+}
+
+func (r *eventRepository) RSVPs(ctx context.Context, eventID int64) ([]entity.EventRSVP, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT r.user_id, u.username, r.status, r.updated_at
+        FROM event_rsvps r JOIN users u ON u.id = r.user_id
+        WHERE r.event_id = $1
+        ORDER BY r.status, lower(u.username)`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []entity.EventRSVP
+	for rows.Next() {
+		var v entity.EventRSVP
+		if err := rows.Scan(&v.UserID, &v.Username, &v.Status, &v.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+func (r *eventRepository) CalendarToken(ctx context.Context, userID int64) (string, error) {
+	var token string
+	err := r.db.QueryRowContext(ctx, `SELECT token FROM calendar_tokens WHERE user_id=$1`, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return token, err
+}
+
+func (r *eventRepository) SetCalendarToken(ctx context.Context, userID int64, token string) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO calendar_tokens (user_id, token) VALUES ($1, $2)
+        ON CONFLICT (user_id) DO UPDATE SET token = EXCLUDED.token`, userID, token)
+	return err
+}
+
+func (r *eventRepository) UserByCalendarToken(ctx context.Context, token string) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `SELECT user_id FROM calendar_tokens WHERE token=$1`, token).Scan(&id)
+	return id, err
+}
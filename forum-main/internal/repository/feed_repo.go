@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+)
+
+type FeedRepository interface {
+	ListExcludedBoards(ctx context.Context, userID int64) ([]int64, error)
+	// ExcludeBoard returns sql.ErrNoRows for a board that doesn't exist
+	ExcludeBoard(ctx context.Context, userID, boardID int64) error
+	IncludeBoard(ctx context.Context, userID, boardID int64) error
+}
+
+func NewFeedRepository(db *sql.DB) FeedRepository {
+	return &feedRepository{db: db}
+}
+
+type feedRepository struct{ db *sql.DB }
+
+func (r *feedRepository) ListExcludedBoards(ctx context.Context, userID int64) ([]int64, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT board_id FROM feed_board_exclusions WHERE user_id=$1 ORDER BY board_id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		res = append(res, id)
+	}
+	return res, nil
+}
+
+func (r *feedRepository) ExcludeBoard(ctx context.Context, userID, boardID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO feed_board_exclusions (user_id, board_id) VALUES ($1,$2)
+        ON CONFLICT (user_id,board_id) DO NOTHING`, userID, boardID)
+	if isForeignKeyViolation(err) {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+func (r *feedRepository) IncludeBoard(ctx context.Context, userID, boardID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM feed_board_exclusions WHERE user_id=$1 AND board_id=$2`, userID, boardID)
+	return err
+}
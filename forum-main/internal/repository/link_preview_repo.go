@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+)
+
+type LinkPreviewRepository interface {
+	Get(ctx context.Context, url string) (*entity.LinkPreview, error)
+	Save(ctx context.Context, p *entity.LinkPreview) error
+}
+
+func NewLinkPreviewRepository(db *sql.DB) LinkPreviewRepository {
+	return &linkPreviewRepository{db: db}
+}
+
+type linkPreviewRepository struct{ db *sql.DB }
+
+func (r *linkPreviewRepository) Get(ctx context.Context, url string) (*entity.LinkPreview, error) {
+	var p entity.LinkPreview
+	err := r.db.QueryRowContext(ctx, `
+        SELECT url, title, description, image_url, site_name, failed, fetched_at
+        FROM link_previews WHERE url=$1`, url,
+	).Scan(&p.URL, &p.Title, &p.Description, &p.ImageURL, &p.SiteName, &p.Failed, &p.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (r *linkPreviewRepository) Save(ctx context.Context, p *entity.LinkPreview) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO link_previews (url, title, description, image_url, site_name, failed, fetched_at)
+        VALUES ($1,$2,$3,$4,$5,$6,$7)
+        ON CONFLICT (url) DO UPDATE SET
+            title=EXCLUDED.title, description=EXCLUDED.description, image_url=EXCLUDED.image_url,
+            site_name=EXCLUDED.site_name, failed=EXCLUDED.failed, fetched_at=EXCLUDED.fetched_at`,
+		p.URL, p.Title, p.Description, p.ImageURL, p.SiteName, p.Failed, p.FetchedAt)
+	return err
+}
@@ -0,0 +1,396 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+)
+
+// ErrEmptyRange is returned when a split selects no comments of the post
+var ErrEmptyRange = errors.New("no comments in range")
+
+// ModerationRepository moves comments between threads. Every operation is
+// one transaction together with its audit log entry.
+type ModerationRepository interface {
+	MovePost(ctx context.Context, moderatorID, postID, boardID int64) error
+	// MergePosts turns the source post into a comment of the target, moves
+	// its comments there and redirects its URL
+	MergePosts(ctx context.Context, moderatorID, fromID, intoID int64) error
+	// SplitPost makes the first comment of the id range a new post in the
+	// same board and moves the rest of the range under it
+	SplitPost(ctx context.Context, moderatorID, postID, fromCommentID, toCommentID int64, title string) (int64, error)
+	// Redirect returns the post a merged post now lives in
+	Redirect(ctx context.Context, postID int64) (int64, error)
+	// DeletePost and DeleteComment delete the target of a and record a with
+	// the target's snapshot and board; sql.ErrNoRows if the target is gone
+	DeletePost(ctx context.Context, a *entity.ModAction) error
+	DeleteComment(ctx context.Context, a *entity.ModAction) error
+	// ListHeld lists the posts and comments waiting in pre-moderation,
+	// oldest first; boardID 0 lists all boards
+	ListHeld(ctx context.Context, boardID int64, limit, offset int) ([]entity.HeldItem, error)
+	// Approve publishes the held post or comment of a and records a with
+	// the hold reason; sql.ErrNoRows if the target is gone or not held
+	Approve(ctx context.Context, a *entity.ModAction) error
+	// ListActions lists the matching log entries, newest first
+	ListActions(ctx context.Context, f entity.ModActionFilter) ([]entity.ModAction, error)
+}
+
+func NewModerationRepository(db *sql.DB) ModerationRepository {
+	return &moderationRepository{db: db}
+}
+
+type moderationRepository struct{ db *sql.DB }
+
+// execer is a *sql.DB or a *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// recordAction appends a to the audit log, in the transaction of the action
+// when q is one. Actions on a post without a board are filed under the
+// board of the post.
+func recordAction(ctx context.Context, q execer, a *entity.ModAction) error {
+	details := []byte(a.Details)
+	if len(details) == 0 {
+		details = []byte("{}")
+	}
+	var snapshot any
+	if len(a.Snapshot) > 0 {
+		snapshot = string(a.Snapshot)
+	}
+	_, err := q.ExecContext(ctx, `
+        INSERT INTO mod_actions (moderator_id, moderator_name, action, target_type, post_id, target_id, board_id, reason, snapshot, details)
+        VALUES ($1, COALESCE((SELECT username FROM users WHERE id = $1), ''), $2, $3, NULLIF($4::int, 0), NULLIF($5::int, 0),
+                COALESCE(NULLIF($6::int, 0), (SELECT board_id FROM posts WHERE id = $4)), $7, $8::jsonb, $9::jsonb)`,
+		a.ModeratorID, a.Action, a.TargetType, a.PostID, a.TargetID, a.BoardID, a.Reason, snapshot, string(details))
+	return err
+}
+
+// audited runs write in a transaction and appends a to the audit log in the
+// same transaction, so the change is never kept without its entry. A nil a
+// records nothing.
+func audited(ctx context.Context, db *sql.DB, a *entity.ModAction, write func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := write(tx); err != nil {
+		return err
+	}
+	if a != nil {
+		if err := recordAction(ctx, tx, a); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// postSnapshot and commentSnapshot select the audit log snapshot of a row
+// of posts (comments), without image data
+const (
+	postSnapshot = `jsonb_build_object('title', title, 'content', content, 'author_id', author_id, 'board_id', board_id,
+        'link_url', link_url, 'image_url', image_url, 'pinned', pinned, 'locked', locked, 'created_at', created_at)`
+	commentSnapshot = `jsonb_build_object('post_id', post_id, 'author_id', author_id, 'content', content, 'created_at', created_at)`
+)
+
+// snapshotTarget locks a post or comment and returns its board, its post
+// and its audit log snapshot
+func snapshotTarget(ctx context.Context, tx *sql.Tx, targetType string, id int64) (boardID, postID int64, snapshot []byte, err error) {
+	query := `SELECT board_id, id, ` + postSnapshot + ` FROM posts WHERE id=$1 FOR UPDATE`
+	if targetType == entity.TargetComment {
+		query = `SELECT (SELECT board_id FROM posts WHERE id = c.post_id), post_id, ` + commentSnapshot + `
+            FROM comments c WHERE id=$1 FOR UPDATE`
+	}
+	err = tx.QueryRowContext(ctx, query, id).Scan(&boardID, &postID, &snapshot)
+	return
+}
+
+// logAction is recordAction for details given as a map
+func logAction(ctx context.Context, q execer, a *entity.ModAction, details map[string]any) error {
+	b, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	a.Details = b
+	return recordAction(ctx, q, a)
+}
+
+func (r *moderationRepository) MovePost(ctx context.Context, moderatorID, postID, boardID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var fromBoard int64
+	if err := tx.QueryRowContext(ctx, `SELECT board_id FROM posts WHERE id=$1 FOR UPDATE`, postID).Scan(&fromBoard); err != nil {
+		return err
+	}
+	if fromBoard == boardID {
+		return nil
+	}
+	res, err := tx.ExecContext(ctx, `UPDATE posts SET board_id=$2 WHERE id=$1 AND `+boardOpen("$2"), postID, boardID)
+	if err := guardedExec(res, err); err != nil {
+		var exists bool
+		if tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM boards WHERE id=$1)`, boardID).Scan(&exists) == nil && !exists {
+			return sql.ErrNoRows
+		}
+		return err
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: moderatorID, Action: entity.ModMovePost,
+		TargetType: entity.TargetPost, PostID: postID, TargetID: boardID, BoardID: fromBoard},
+		map[string]any{"from_board_id": fromBoard, "to_board_id": boardID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *moderationRepository) MergePosts(ctx context.Context, moderatorID, fromID, intoID int64) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRowContext(ctx, `
+        SELECT COUNT(*) FROM (SELECT id FROM posts WHERE id IN ($1, $2) ORDER BY id FOR UPDATE) p`,
+		fromID, intoID).Scan(&n); err != nil {
+		return err
+	}
+	if n != 2 {
+		return sql.ErrNoRows
+	}
+	if err := postTakesContent(ctx, tx, intoID); err != nil {
+		return err
+	}
+	var fromBoard int64
+	var snapshot []byte
+	if err := tx.QueryRowContext(ctx, `SELECT board_id, `+postSnapshot+` FROM posts WHERE id=$1`, fromID).Scan(&fromBoard, &snapshot); err != nil {
+		return err
+	}
+	// a held post stays held as comments, and so do the comments that were
+	// hidden along with it
+	var moved int64
+	err = tx.QueryRowContext(ctx, `
+        WITH src AS (
+            SELECT author_id, title, content, created_at, pending, hold_reason FROM posts WHERE id=$1
+        ), opening AS (
+            INSERT INTO comments (post_id, author_id, content, created_at, pending, hold_reason)
+            SELECT $2, author_id, title || E'\n\n' || COALESCE(content, ''), created_at, pending, hold_reason FROM src
+        ), moved AS (
+            UPDATE comments c SET post_id=$2, pending = c.pending OR src.pending,
+                hold_reason = CASE WHEN NOT c.pending AND src.pending THEN src.hold_reason ELSE c.hold_reason END
+            FROM src WHERE c.post_id=$1 RETURNING 1
+        )
+        SELECT COUNT(*) FROM moved`, fromID, intoID).Scan(&moved)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE post_redirects SET to_post_id=$2 WHERE to_post_id=$1`, fromID, intoID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO post_redirects (from_post_id, to_post_id) VALUES ($1,$2)`, fromID, intoID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM posts WHERE id=$1`, fromID); err != nil {
+		return err
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: moderatorID, Action: entity.ModMergePosts,
+		TargetType: entity.TargetPost, PostID: fromID, TargetID: intoID, BoardID: fromBoard, Snapshot: snapshot},
+		map[string]any{"comments_moved": moved}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// postTakesContent returns ErrPostLocked or ErrBoardArchived when the
+// post can't take moved content or have it split off
+func postTakesContent(ctx context.Context, tx *sql.Tx, postID int64) error {
+	var locked, archived bool
+	if err := tx.QueryRowContext(ctx, `
+        SELECT p.locked, b.archived_at IS NOT NULL FROM posts p JOIN boards b ON b.id = p.board_id
+        WHERE p.id=$1`, postID).Scan(&locked, &archived); err != nil {
+		return err
+	}
+	switch {
+	case archived:
+		return ErrBoardArchived
+	case locked:
+		return ErrPostLocked
+	}
+	return nil
+}
+
+func (r *moderationRepository) SplitPost(ctx context.Context, moderatorID, postID, fromCommentID, toCommentID int64, title string) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var boardID int64
+	var held bool
+	var holdReason string
+	if err := tx.QueryRowContext(ctx, `SELECT board_id, pending, hold_reason FROM posts WHERE id=$1 FOR UPDATE`, postID).
+		Scan(&boardID, &held, &holdReason); err != nil {
+		return 0, err
+	}
+	if err := postTakesContent(ctx, tx, postID); err != nil {
+		return 0, err
+	}
+	var first entity.Comment
+	err = tx.QueryRowContext(ctx, `
+        SELECT id, author_id, content, created_at, pending, hold_reason FROM comments
+        WHERE post_id=$1 AND id BETWEEN $2 AND $3 ORDER BY id LIMIT 1`, postID, fromCommentID, toCommentID,
+	).Scan(&first.ID, &first.AuthorID, &first.Content, &first.CreatedAt, &first.Pending, &first.HoldReason)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrEmptyRange
+	} else if err != nil {
+		return 0, err
+	}
+	// the new post is held if its opening comment was, or if the comments
+	// were hidden with a held post
+	if !held && first.Pending {
+		held, holdReason = true, first.HoldReason
+	}
+	var newID int64
+	if err := tx.QueryRowContext(ctx, `
+        INSERT INTO posts (board_id, title, content, author_id, created_at, pending, hold_reason)
+        VALUES ($1,$2,$3,$4,$5,$6,$7) RETURNING id`, boardID, title, first.Content, first.AuthorID, first.CreatedAt, held, holdReason,
+	).Scan(&newID); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `
+        UPDATE comments SET post_id=$4 WHERE post_id=$1 AND id > $2 AND id <= $3`, postID, first.ID, toCommentID, newID)
+	if err != nil {
+		return 0, err
+	}
+	moved, _ := res.RowsAffected()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id=$1`, first.ID); err != nil {
+		return 0, err
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: moderatorID, Action: entity.ModSplitPost,
+		TargetType: entity.TargetPost, PostID: postID, TargetID: newID, BoardID: boardID}, map[string]any{
+		"from_comment_id": first.ID, "to_comment_id": toCommentID, "comments_moved": moved, "title": title,
+	}); err != nil {
+		return 0, err
+	}
+	return newID, tx.Commit()
+}
+
+func (r *moderationRepository) Redirect(ctx context.Context, postID int64) (int64, error) {
+	var to int64
+	err := r.db.QueryRowContext(ctx, `SELECT to_post_id FROM post_redirects WHERE from_post_id=$1`, postID).Scan(&to)
+	return to, err
+}
+
+func (r *moderationRepository) DeletePost(ctx context.Context, a *entity.ModAction) error {
+	return r.deleteTarget(ctx, a, `DELETE FROM posts WHERE id=$1`)
+}
+
+func (r *moderationRepository) DeleteComment(ctx context.Context, a *entity.ModAction) error {
+	return r.deleteTarget(ctx, a, `DELETE FROM comments WHERE id=$1`)
+}
+
+func (r *moderationRepository) deleteTarget(ctx context.Context, a *entity.ModAction, query string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if a.BoardID, a.PostID, a.Snapshot, err = snapshotTarget(ctx, tx, a.TargetType, a.TargetID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query, a.TargetID); err != nil {
+		return err
+	}
+	if err := recordAction(ctx, tx, a); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *moderationRepository) ListHeld(ctx context.Context, boardID int64, limit, offset int) ([]entity.HeldItem, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT 'post', p.id AS id, p.id, p.title, p.board_id, b.title, p.author_id, u.username,
+               COALESCE(p.content, ''), p.hold_reason, p.created_at AS created_at
+        FROM posts p JOIN boards b ON b.id = p.board_id JOIN users u ON u.id = p.author_id
+        WHERE p.pending AND ($1 = 0 OR p.board_id = $1)
+        UNION ALL
+        SELECT 'comment', c.id, c.post_id, p.title, p.board_id, b.title, c.author_id, u.username,
+               c.content, c.hold_reason, c.created_at
+        FROM comments c JOIN posts p ON p.id = c.post_id JOIN boards b ON b.id = p.board_id JOIN users u ON u.id = c.author_id
+        WHERE c.pending AND ($1 = 0 OR p.board_id = $1)
+        ORDER BY created_at, id
+        LIMIT $2 OFFSET $3`, boardID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []entity.HeldItem
+	for rows.Next() {
+		var it entity.HeldItem
+		if err := rows.Scan(&it.TargetType, &it.ID, &it.PostID, &it.PostTitle, &it.BoardID, &it.BoardTitle,
+			&it.AuthorID, &it.AuthorName, &it.Content, &it.HoldReason, &it.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, it)
+	}
+	return items, rows.Err()
+}
+
+func (r *moderationRepository) Approve(ctx context.Context, a *entity.ModAction) error {
+	table := "posts"
+	if a.TargetType == entity.TargetComment {
+		table = "comments"
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// the snapshot is what the spam filter learns the approval from
+	if a.BoardID, a.PostID, a.Snapshot, err = snapshotTarget(ctx, tx, a.TargetType, a.TargetID); err != nil {
+		return err
+	}
+	var reason string
+	if err := tx.QueryRowContext(ctx, `
+        UPDATE `+table+` t SET pending=false, hold_reason=''
+        FROM (SELECT hold_reason FROM `+table+` WHERE id=$1) old
+        WHERE t.id=$1 AND t.pending
+        RETURNING old.hold_reason`, a.TargetID).Scan(&reason); err != nil {
+		return err
+	}
+	if err := logAction(ctx, tx, a, map[string]any{"hold_reason": reason}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *moderationRepository) ListActions(ctx context.Context, f entity.ModActionFilter) ([]entity.ModAction, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, COALESCE(moderator_id, 0), moderator_name, action, target_type,
+               COALESCE(post_id, 0), COALESCE(target_id, 0), COALESCE(board_id, 0), reason, snapshot, details, created_at
+        FROM mod_actions
+        WHERE ($1 = '' OR action = $1) AND ($2 = '' OR target_type = $2)
+          AND ($3 = 0 OR moderator_id = $3) AND ($4 = 0 OR board_id = $4)
+        ORDER BY created_at DESC, id DESC
+        LIMIT $5 OFFSET $6`, f.Action, f.TargetType, f.ModeratorID, f.BoardID, f.Limit, f.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var actions []entity.ModAction
+	for rows.Next() {
+		var a entity.ModAction
+		var snapshot, details []byte
+		if err := rows.Scan(&a.ID, &a.ModeratorID, &a.ModeratorName, &a.Action, &a.TargetType,
+			&a.PostID, &a.TargetID, &a.BoardID, &a.Reason, &snapshot, &details, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Snapshot, a.Details = snapshot, details
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
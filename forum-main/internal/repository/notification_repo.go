@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, n *entity.Notification) (int64, error)
+	ListByUser(ctx context.Context, userID int64, limit int) ([]entity.Notification, error)
+	CountUnread(ctx context.Context, userID int64) (int, error)
+	MarkAllRead(ctx context.Context, userID int64) error
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+type notificationRepository struct{ db *sql.DB }
+
+func (r *notificationRepository) Create(ctx context.Context, n *entity.Notification) (int64, error) {
+	var id int64
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO notifications (user_id, kind, title, url) VALUES ($1,$2,$3,$4)
+        RETURNING id, created_at`, n.UserID, n.Kind, n.Title, n.URL).Scan(&id, &n.CreatedAt)
+	n.ID = id
+	return id, err
+}
+
+func (r *notificationRepository) ListByUser(ctx context.Context, userID int64, limit int) ([]entity.Notification, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, user_id, kind, title, url, read_at, created_at
+        FROM notifications WHERE user_id=$1
+        ORDER BY created_at DESC, id DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.Notification
+	for rows.Next() {
+		var n entity.Notification
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Kind, &n.Title, &n.URL, &readAt, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		res = append(res, n)
+	}
+	return res, rows.Err()
+}
+
+func (r *notificationRepository) CountUnread(ctx context.Context, userID int64) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM notifications WHERE user_id=$1 AND read_at IS NULL`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *notificationRepository) MarkAllRead(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE notifications SET read_at=now() WHERE user_id=$1 AND read_at IS NULL`, userID)
+	return err
+}
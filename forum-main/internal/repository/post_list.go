@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// postSortKey is the ORDER BY expression of a sort mode; post id breaks ties
+// so that (key, id) is unique and can be used as a keyset cursor.
+type postSortKey struct {
+	expr string
+	cast string // type of the cursor value in SQL
+	asc  bool
+}
+
+var postSortKeys = map[string]postSortKey{
+	entity.SortNew:      {expr: "p.created_at", cast: "timestamptz"},
+	entity.SortOld:      {expr: "p.created_at", cast: "timestamptz", asc: true},
+	entity.SortTop:      {expr: "(v.likes - v.dislikes)", cast: "bigint"},
+	entity.SortComments: {expr: "c.cnt", cast: "bigint"},
+	entity.SortActive:   {expr: "GREATEST(p.created_at, c.last_at)", cast: "timestamptz"},
+	entity.SortHot:      {cast: "float8"}, // expression depends on HotConfig, see hotScoreExpr
+}
+
+var periodLengths = map[string]time.Duration{
+	entity.PeriodDay:   24 * time.Hour,
+	entity.PeriodWeek:  7 * 24 * time.Hour,
+	entity.PeriodMonth: 30 * 24 * time.Hour,
+}
+
+// postCursor is the position after the last post of a page: its sort key
+// (as text, exactly as Postgres printed it) and id. Hot scores and the top
+// of a period change with time, so their cursors also pin the moment the
+// first page was computed at.
+type postCursor struct {
+	Key  string    `json:"k"`
+	ID   int64     `json:"id"`
+	AsOf time.Time `json:"t,omitzero"`
+}
+
+func encodePostCursor(c postCursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodePostCursor(s string) (*postCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c postCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// ListPosts returns one page of posts with vote and comment counters. The
+// options are expected to be validated by the service.
+func (r *postRepository) ListPosts(ctx context.Context, opts entity.PostListOptions) (*entity.PostPage, error) {
+	key, ok := postSortKeys[opts.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", opts.Sort)
+	}
+	dir, cmp := "DESC", "<"
+	if key.asc {
+		dir, cmp = "ASC", ">"
+	}
+
+	var where []string
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var cursor *postCursor
+	if opts.Cursor != "" {
+		c, err := decodePostCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+	asOf := time.Now()
+	if cursor != nil && !cursor.AsOf.IsZero() {
+		asOf = cursor.AsOf
+	}
+	recentSince := arg(asOf.Add(-opts.Hot.VelocityWindow))
+	if opts.Sort == entity.SortHot {
+		key.expr = hotScoreExpr(opts.Hot, arg(asOf), arg)
+		// posts newer than asOf would have a negative age, and aren't part of
+		// the pages the cursor continues
+		where = append(where, "p.created_at >= "+arg(asOf.Add(-opts.Hot.MaxAge)), "p.created_at <= "+arg(asOf))
+	}
+
+	viewer := arg(viewerID(ctx))
+	where = append(where, boardVisible("p.board_id", viewer), heldVisible("p", viewer))
+	if opts.BoardID != 0 {
+		where = append(where, "p.board_id = "+arg(opts.BoardID))
+	}
+	if opts.OnlyPinned {
+		where = append(where, "p.pinned")
+	} else if opts.SkipPinned {
+		where = append(where, "NOT p.pinned")
+	}
+	if len(opts.ExcludeBoardIDs) > 0 {
+		where = append(where, "NOT (p.board_id = ANY("+arg(pq.Array(opts.ExcludeBoardIDs))+"))")
+	}
+	period, windowed := periodLengths[opts.Period]
+	windowed = windowed && opts.Sort == entity.SortTop
+	if windowed {
+		where = append(where, "p.created_at >= "+arg(asOf.Add(-period)))
+	}
+	if cursor != nil {
+		where = append(where, fmt.Sprintf("(%s, p.id) %s (%s::%s, %s)", key.expr, cmp, arg(cursor.Key), key.cast, arg(cursor.ID)))
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "WHERE " + strings.Join(where, " AND ")
+	}
+
+	query := fmt.Sprintf(`
+        SELECT p.id, p.board_id, p.title, p.content, p.author_id, COALESCE(p.image_url,''), COALESCE(p.link_url,''),
+               p.image_data IS NOT NULL AND length(p.image_data) > 0, COALESCE(p.image_width,0), COALESCE(p.image_height,0),
+               p.created_at, p.updated_at, v.likes, v.dislikes, c.cnt, GREATEST(p.created_at, c.last_at),
+               p.pinned, p.locked, p.pending, (%[1]s)::text
+        FROM posts p
+        LEFT JOIN LATERAL (
+            SELECT COUNT(*) FILTER (WHERE value=1) AS likes, COUNT(*) FILTER (WHERE value=-1) AS dislikes
+            FROM post_votes WHERE post_id = p.id
+        ) v ON true
+        LEFT JOIN LATERAL (
+            SELECT COUNT(*) AS cnt, MAX(created_at) AS last_at,
+                   COUNT(*) FILTER (WHERE created_at >= %[5]s) AS recent
+            FROM comments WHERE post_id = p.id AND NOT pending
+        ) c ON true
+        %[2]s
+        ORDER BY %[1]s %[3]s, p.id %[3]s
+        LIMIT %[4]s`, key.expr, cond, dir, arg(opts.Limit+1), recentSince)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	page := &entity.PostPage{Posts: []entity.Post{}}
+	var lastKey string
+	for rows.Next() {
+		var p entity.Post
+		var sortKey sql.NullString
+		if err := rows.Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &p.ImageURL, &p.LinkURL,
+			&p.HasImage, &p.ImageWidth, &p.ImageHeight, &p.CreatedAt, &p.UpdatedAt,
+			&p.Likes, &p.Dislikes, &p.CommentCount, &p.LastActivityAt, &p.Pinned, &p.Locked, &p.Pending, &sortKey); err != nil {
+			return nil, err
+		}
+		if len(page.Posts) == opts.Limit {
+			// there is one more row: the page is full and has a successor
+			last := page.Posts[len(page.Posts)-1]
+			next := postCursor{Key: lastKey, ID: int64(last.ID)}
+			if opts.Sort == entity.SortHot || windowed {
+				next.AsOf = asOf
+			}
+			page.NextCursor = encodePostCursor(next)
+			break
+		}
+		lastKey = sortKey.String
+		page.Posts = append(page.Posts, p)
+	}
+	return page, rows.Err()
+}
+
+// hotScoreExpr builds the SQL expression of entity.HotConfig's score, with
+// post age measured at asOf.
+func hotScoreExpr(cfg entity.HotConfig, asOf string, arg func(any) string) string {
+	return fmt.Sprintf(`((%s::float8 * (v.likes - v.dislikes) + %s::float8 * c.recent + 1)
+            / power(GREATEST(EXTRACT(EPOCH FROM (%s::timestamptz - p.created_at))::float8, 0) / 3600 + 2, %s::float8))`,
+		arg(cfg.VoteWeight), arg(cfg.CommentWeight), asOf, arg(cfg.Gravity))
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostCursorRoundTrip(t *testing.T) {
+	tests := []postCursor{
+		{Key: "2026-03-01 10:00:00.123456+00", ID: 42},
+		{Key: "-17", ID: 1},
+		{Key: "", ID: 7},
+		{Key: "3.14159", ID: 99, AsOf: time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{Key: `quote " and \ backslash, юникод`, ID: 1 << 40},
+	}
+	for _, c := range tests {
+		s := encodePostCursor(c)
+		if strings.ContainsAny(s, "+/=") {
+			t.Errorf("%q is not URL safe", s)
+		}
+		got, err := decodePostCursor(s)
+		if err != nil {
+			t.Fatalf("%+v: %v", c, err)
+		}
+		if got.Key != c.Key || got.ID != c.ID || !got.AsOf.Equal(c.AsOf) {
+			t.Errorf("got %+v, want %+v", *got, c)
+		}
+	}
+	// without a time the cursor stays short
+	if s := encodePostCursor(postCursor{Key: "1", ID: 1}); strings.Contains(mustDecode(t, s), `"t"`) {
+		t.Errorf("zero time encoded in %s", mustDecode(t, s))
+	}
+}
+
+func mustDecode(t *testing.T, s string) string {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestDecodePostCursorInvalid(t *testing.T) {
+	enc := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, s := range []string{
+		"",
+		"not base64!",
+		enc("not json"),
+		enc(`{"k":"1"}`),
+		enc(`{"k":"1","id":0}`),
+		enc(`{"k":"1","id":-5}`),
+		enc(`{"k":1,"id":5}`),
+		base64.StdEncoding.EncodeToString([]byte(`{"k":"1","id":5}`)),
+	} {
+		if _, err := decodePostCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%q: got %v, want ErrInvalidCursor", s, err)
+		}
+	}
+}
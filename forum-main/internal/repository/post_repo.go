@@ -10,11 +10,21 @@ type PostRepository interface {
 	GetAllPosts(ctx context.Context) ([]entity.Post, error)
 	GetPostByID(ctx context.Context, id int64) (*entity.Post, error)
 	GetPostsByBoard(ctx context.Context, boardID int64) ([]entity.Post, error)
+	ListPosts(ctx context.Context, opts entity.PostListOptions) (*entity.PostPage, error)
 	CreatePost(ctx context.Context, p *entity.Post) (int64, error)
+	// UpdatePost edits the post if p.AuthorID wrote it and may still write
+	// in its board, guarded like CreatePost
 	UpdatePost(ctx context.Context, p *entity.Post) error
 	DeletePost(ctx context.Context, id int64) error
 	SetPostVote(ctx context.Context, postID int64, userID int64, value int) error
+	// SetPinned and SetLocked record a, when not nil, in the audit log in
+	// the same transaction
+	SetPinned(ctx context.Context, id int64, pinned bool, a *entity.ModAction) error
+	SetLocked(ctx context.Context, id int64, locked bool, a *entity.ModAction) error
 	GetPostVotes(ctx context.Context, postID int64) (likes int, dislikes int, err error)
+	GetPostImage(ctx context.Context, postID int64) (*entity.ImageVariant, error)
+	GetImageVariant(ctx context.Context, postID int64, width int) (*entity.ImageVariant, error)
+	SaveImageVariant(ctx context.Context, v *entity.ImageVariant) error
 }
 
 func NewPostRepository(db *sql.DB) PostRepository {
@@ -27,9 +37,10 @@ type postRepository struct {
 
 func (r *postRepository) GetAllPosts(ctx context.Context) ([]entity.Post, error) {
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, board_id, title, content, author_id, image_url, image_data, link_url, created_at, updated_at
+        SELECT id, board_id, title, content, author_id, image_url, image_data IS NOT NULL AND length(image_data) > 0, COALESCE(image_width,0), COALESCE(image_height,0), link_url, created_at, updated_at, pinned, locked, pending, hold_reason
         FROM posts
-        ORDER BY created_at DESC`)
+        WHERE `+boardVisible("board_id", "$1")+` AND `+heldVisible("posts", "$1")+`
+        ORDER BY created_at DESC`, viewerID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -39,7 +50,7 @@ func (r *postRepository) GetAllPosts(ctx context.Context) ([]entity.Post, error)
 		var p entity.Post
 		var imageURL sql.NullString
 		var linkURL sql.NullString
-		if err := rows.Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.ImageData, &linkURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.HasImage, &p.ImageWidth, &p.ImageHeight, &linkURL, &p.CreatedAt, &p.UpdatedAt, &p.Pinned, &p.Locked, &p.Pending, &p.HoldReason); err != nil {
 			return nil, err
 		}
 		if imageURL.Valid {
@@ -62,9 +73,9 @@ func (r *postRepository) GetPostByID(ctx context.Context, id int64) (*entity.Pos
 	var imageURL sql.NullString
 	var linkURL sql.NullString
 	err := r.db.QueryRowContext(ctx, `
-        SELECT id, board_id, title, content, author_id, image_url, image_data, link_url, created_at, updated_at
-        FROM posts WHERE id = $1`, id,
-	).Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.ImageData, &linkURL, &p.CreatedAt, &p.UpdatedAt)
+        SELECT id, board_id, title, content, author_id, image_url, image_data, COALESCE(image_width,0), COALESCE(image_height,0), link_url, created_at, updated_at, pinned, locked, pending, hold_reason
+        FROM posts WHERE id = $1 AND `+boardVisible("board_id", "$2")+` AND `+heldVisible("posts", "$2"), id, viewerID(ctx),
+	).Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.ImageData, &p.ImageWidth, &p.ImageHeight, &linkURL, &p.CreatedAt, &p.UpdatedAt, &p.Pinned, &p.Locked, &p.Pending, &p.HoldReason)
 	if err != nil {
 		return nil, err
 	}
@@ -83,8 +94,9 @@ func (r *postRepository) GetPostByID(ctx context.Context, id int64) (*entity.Pos
 
 func (r *postRepository) GetPostsByBoard(ctx context.Context, boardID int64) ([]entity.Post, error) {
 	rows, err := r.db.QueryContext(ctx, `
-        SELECT id, board_id, title, content, author_id, image_url, image_data, link_url, created_at, updated_at
-        FROM posts WHERE board_id = $1 ORDER BY created_at DESC`, boardID)
+        SELECT id, board_id, title, content, author_id, image_url, image_data IS NOT NULL AND length(image_data) > 0, COALESCE(image_width,0), COALESCE(image_height,0), link_url, created_at, updated_at, pinned, locked, pending, hold_reason
+        FROM posts WHERE board_id = $1 AND `+boardVisible("board_id", "$2")+` AND `+heldVisible("posts", "$2")+`
+        ORDER BY pinned DESC, created_at DESC`, boardID, viewerID(ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -94,7 +106,7 @@ func (r *postRepository) GetPostsByBoard(ctx context.Context, boardID int64) ([]
 		var p entity.Post
 		var imageURL sql.NullString
 		var linkURL sql.NullString
-		if err := rows.Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.ImageData, &linkURL, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		if err := rows.Scan(&p.ID, &p.BoardID, &p.Title, &p.Content, &p.AuthorID, &imageURL, &p.HasImage, &p.ImageWidth, &p.ImageHeight, &linkURL, &p.CreatedAt, &p.UpdatedAt, &p.Pinned, &p.Locked, &p.Pending, &p.HoldReason); err != nil {
 			return nil, err
 		}
 		if imageURL.Valid {
@@ -115,24 +127,34 @@ func (r *postRepository) GetPostsByBoard(ctx context.Context, boardID int64) ([]
 func (r *postRepository) CreatePost(ctx context.Context, p *entity.Post) (int64, error) {
 	var id int64
 	err := r.db.QueryRowContext(ctx, `
-        INSERT INTO posts (board_id, title, content, author_id, image_url, image_data, link_url)
-        VALUES ($1,$2,$3,$4,$5,$6,$7)
+        INSERT INTO posts (board_id, title, content, author_id, image_url, image_data, image_hash, image_width, image_height, link_url, pending, hold_reason)
+        SELECT $1,$2,$3,$4,$5,$6,encode(sha256($6),'hex'),NULLIF($7,0),NULLIF($8,0),$9,$10,$11
+        WHERE `+boardOpen("$1")+` AND `+notSanctioned("$4", "$1")+`
         RETURNING id`,
-		p.BoardID, p.Title, p.Content, p.AuthorID, p.ImageURL, p.ImageData, p.LinkURL,
+		p.BoardID, p.Title, p.Content, p.AuthorID, p.ImageURL, p.ImageData, p.ImageWidth, p.ImageHeight, p.LinkURL, p.Pending, p.HoldReason,
 	).Scan(&id)
 	if err != nil {
-		return 0, err
+		return 0, sanctionReason(ctx, r.db, int64(p.AuthorID), `$2`, int64(p.BoardID), guardedRow(err))
 	}
 	return id, nil
 }
 
 func (r *postRepository) UpdatePost(ctx context.Context, p *entity.Post) error {
-	_, err := r.db.ExecContext(ctx, `
+	res, err := r.db.ExecContext(ctx, `
         UPDATE posts
-        SET board_id=$1, title=$2, content=$3, image_url=$4, image_data=$5, link_url=$6, updated_at=now()
-        WHERE id=$7`,
-		p.BoardID, p.Title, p.Content, p.ImageURL, p.ImageData, p.LinkURL, p.ID,
+        SET board_id=$1, title=$2, content=$3, image_url=$4, image_data=$5, image_hash=encode(sha256($5),'hex'), link_url=$6, updated_at=now(),
+            image_width=NULLIF($11,0), image_height=NULLIF($12,0),
+            pending = pending OR $8, hold_reason = CASE WHEN $8 THEN $9 ELSE hold_reason END
+        WHERE id=$7 AND author_id=$10 AND `+postOpen("$7")+` AND `+boardOpen("$1")+` AND `+notSanctioned("$10", "$1"),
+		p.BoardID, p.Title, p.Content, p.ImageURL, p.ImageData, p.LinkURL, p.ID, p.Pending, p.HoldReason, p.AuthorID,
+		p.ImageWidth, p.ImageHeight,
 	)
+	err = sanctionReason(ctx, r.db, int64(p.AuthorID), `$2`, int64(p.BoardID), guardedExec(res, err))
+	if err := closedReason(ctx, r.db, postClosedQuery, int64(p.ID), int64(p.AuthorID), err); err != nil {
+		return err
+	}
+	// the image may have changed, resized copies are regenerated on demand
+	_, err = r.db.ExecContext(ctx, `DELETE FROM post_image_variants WHERE post_id=$1`, p.ID)
 	return err
 }
 
@@ -142,11 +164,27 @@ func (r *postRepository) DeletePost(ctx context.Context, id int64) error {
 }
 
 func (r *postRepository) SetPostVote(ctx context.Context, postID int64, userID int64, value int) error {
-	_, err := r.db.ExecContext(ctx, `
+	res, err := r.db.ExecContext(ctx, `
         INSERT INTO post_votes (post_id, user_id, value)
-        VALUES ($1,$2,$3)
+        SELECT $1,$2,$3 WHERE `+postOpen("$1")+` AND `+postVisible("$1", "$2")+`
+          AND `+notSanctioned("$2", "(SELECT board_id FROM posts WHERE id = $1)")+`
         ON CONFLICT (post_id,user_id) DO UPDATE SET value=EXCLUDED.value`, postID, userID, value)
-	return err
+	err = sanctionReason(ctx, r.db, userID, postBoard, postID, guardedExec(res, err))
+	return closedReason(ctx, r.db, postClosedQuery, postID, userID, err)
+}
+
+func (r *postRepository) SetPinned(ctx context.Context, id int64, pinned bool, a *entity.ModAction) error {
+	return r.setFlag(ctx, `UPDATE posts SET pinned=$2 WHERE id=$1`, id, pinned, a)
+}
+
+func (r *postRepository) SetLocked(ctx context.Context, id int64, locked bool, a *entity.ModAction) error {
+	return r.setFlag(ctx, `UPDATE posts SET locked=$2 WHERE id=$1`, id, locked, a)
+}
+
+func (r *postRepository) setFlag(ctx context.Context, query string, id int64, v bool, a *entity.ModAction) error {
+	return audited(ctx, r.db, a, func(tx *sql.Tx) error {
+		return execOne(ctx, tx, query, id, v)
+	})
 }
 
 func (r *postRepository) GetPostVotes(ctx context.Context, postID int64) (likes int, dislikes int, err error) {
@@ -156,3 +194,36 @@ func (r *postRepository) GetPostVotes(ctx context.Context, postID int64) (likes
         FROM post_votes WHERE post_id=$1`, postID).Scan(&likes, &dislikes)
 	return
 }
+
+func (r *postRepository) GetPostImage(ctx context.Context, postID int64) (*entity.ImageVariant, error) {
+	v := entity.ImageVariant{PostID: postID}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT image_data, COALESCE(image_hash, ''), updated_at FROM posts WHERE id=$1 AND `+boardVisible("board_id", "$2")+` AND `+heldVisible("posts", "$2"), postID, viewerID(ctx),
+	).Scan(&v.Data, &v.Hash, &v.ModTime)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *postRepository) GetImageVariant(ctx context.Context, postID int64, width int) (*entity.ImageVariant, error) {
+	v := entity.ImageVariant{PostID: postID, Width: width}
+	err := r.db.QueryRowContext(ctx, `
+        SELECT v.content_type, v.data, COALESCE(p.image_hash, ''), p.updated_at
+        FROM post_image_variants v
+        JOIN posts p ON p.id = v.post_id
+        WHERE v.post_id=$1 AND v.width=$2 AND `+boardVisible("p.board_id", "$3")+` AND `+heldVisible("p", "$3"), postID, width, viewerID(ctx),
+	).Scan(&v.ContentType, &v.Data, &v.Hash, &v.ModTime)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func (r *postRepository) SaveImageVariant(ctx context.Context, v *entity.ImageVariant) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO post_image_variants (post_id, width, content_type, data)
+        VALUES ($1,$2,$3,$4)
+        ON CONFLICT (post_id,width) DO NOTHING`, v.PostID, v.Width, v.ContentType, v.Data)
+	return err
+}
@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+)
+
+// ErrAlreadyReported is returned for a second open report of the same
+// target by the same user
+var ErrAlreadyReported = errors.New("already reported")
+
+type ReportRepository interface {
+	Create(ctx context.Context, rep *entity.Report) (int64, error)
+	// Target describes the reported content as the user in ctx sees it,
+	// Deleted when it is gone or hidden from them
+	Target(ctx context.Context, targetType string, targetID int64) (*entity.ReportTarget, error)
+	// Queue lists the open reports grouped by target, oldest first, with the
+	// targets described as by Target
+	Queue(ctx context.Context) ([]entity.ReportGroup, error)
+	// Resolve applies the moderator's action to the target and closes its
+	// open reports, returning their reporters; sql.ErrNoRows if none are open
+	Resolve(ctx context.Context, moderatorID int64, target *entity.ReportTarget, action, note string) ([]int64, error)
+}
+
+func NewReportRepository(db *sql.DB) ReportRepository {
+	return &reportRepository{db: db}
+}
+
+type reportRepository struct {
+	db *sql.DB
+}
+
+func (r *reportRepository) Create(ctx context.Context, rep *entity.Report) (int64, error) {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO reports (reporter_id, target_type, target_id, reason, details)
+        VALUES ($1, $2, $3, $4, $5)
+        RETURNING id, status, created_at`,
+		rep.ReporterID, rep.TargetType, rep.TargetID, rep.Reason, rep.Details,
+	).Scan(&rep.ID, &rep.Status, &rep.CreatedAt)
+	if isUniqueViolation(err) {
+		return 0, ErrAlreadyReported
+	}
+	return rep.ID, err
+}
+
+// reportTargetJoins joins the reported post, comment and user to the
+// reports r, or to one (target_type, target_id) row. Posts and comments the
+// user with id param viewer can't see are left out, so they read as deleted
+// rather than giving away that they exist.
+func reportTargetJoins(viewer string) string {
+	return `
+        LEFT JOIN posts tp ON r.target_type = 'post' AND tp.id = r.target_id AND ` + postVisible("tp.id", viewer) + `
+        LEFT JOIN comments tc ON r.target_type = 'comment' AND tc.id = r.target_id
+             AND ` + heldVisible("tc", viewer) + ` AND ` + postVisible("tc.post_id", viewer) + `
+        LEFT JOIN users tu ON tu.id = CASE r.target_type
+             WHEN 'post' THEN tp.author_id WHEN 'comment' THEN tc.author_id ELSE r.target_id END`
+}
+
+// reportTargetColumns are scanned by scanReportTarget
+const reportTargetColumns = `r.target_type, r.target_id, tu.id IS NULL, COALESCE(tu.id, 0), COALESCE(tu.username, ''),
+        COALESCE(CASE r.target_type WHEN 'post' THEN tp.title WHEN 'comment' THEN left(tc.content, 200) ELSE tu.username END, ''),
+        COALESCE(tc.post_id, 0)`
+
+func scanReportTarget(row interface{ Scan(...any) error }, t *entity.ReportTarget, dest ...any) error {
+	var postID int64
+	if err := row.Scan(append(dest, &t.Type, &t.ID, &t.Deleted, &t.AuthorID, &t.AuthorName, &t.Excerpt, &postID)...); err != nil {
+		return err
+	}
+	switch {
+	case t.Deleted:
+		t.URL = ""
+	case t.Type == entity.ReportPost:
+		t.URL = fmt.Sprintf("/post/%d", t.ID)
+	case t.Type == entity.ReportComment:
+		t.URL = fmt.Sprintf("/post/%d#comment-%d", postID, t.ID)
+	default:
+		t.URL = fmt.Sprintf("/profile/%d", t.ID)
+	}
+	return nil
+}
+
+func (r *reportRepository) Target(ctx context.Context, targetType string, targetID int64) (*entity.ReportTarget, error) {
+	if targetType != entity.ReportPost && targetType != entity.ReportComment && targetType != entity.ReportUser {
+		return nil, sql.ErrNoRows
+	}
+	t := &entity.ReportTarget{}
+	row := r.db.QueryRowContext(ctx, `
+        SELECT `+reportTargetColumns+`
+        FROM (SELECT $1::text AS target_type, $2::bigint AS target_id) r`+reportTargetJoins("$3"),
+		targetType, targetID, viewerID(ctx))
+	if err := scanReportTarget(row, t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (r *reportRepository) Queue(ctx context.Context) ([]entity.ReportGroup, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT r.id, r.reporter_id, u.username, r.reason, r.details, r.status, r.created_at,
+               `+reportTargetColumns+`
+        FROM reports r JOIN users u ON u.id = r.reporter_id`+reportTargetJoins("$1")+`
+        WHERE r.status = 'open'
+        ORDER BY r.created_at, r.id`, viewerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var groups []entity.ReportGroup
+	index := make(map[string]int)
+	for rows.Next() {
+		var rep entity.Report
+		var t entity.ReportTarget
+		if err := scanReportTarget(rows, &t, &rep.ID, &rep.ReporterID, &rep.ReporterName,
+			&rep.Reason, &rep.Details, &rep.Status, &rep.CreatedAt); err != nil {
+			return nil, err
+		}
+		rep.TargetType, rep.TargetID = t.Type, t.ID
+		key := fmt.Sprintf("%s:%d", rep.TargetType, rep.TargetID)
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, entity.ReportGroup{Target: t, Reasons: make(map[string]int)})
+		}
+		groups[i].Reports = append(groups[i].Reports, rep)
+		groups[i].Reasons[rep.Reason]++
+	}
+	return groups, rows.Err()
+}
+
+func (r *reportRepository) Resolve(ctx context.Context, moderatorID int64, target *entity.ReportTarget, action, note string) ([]int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	status := entity.ReportResolved
+	if action == entity.ResolveDismiss {
+		status = entity.ReportDismissed
+	}
+	rows, err := tx.QueryContext(ctx, `
+        UPDATE reports SET status=$3, resolution=$4, resolved_by=$5, resolved_at=now()
+        WHERE target_type=$1 AND target_id=$2 AND status='open'
+        RETURNING reporter_id`, target.Type, target.ID, status, action, moderatorID)
+	if err != nil {
+		return nil, err
+	}
+	var reporters []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		reporters = append(reporters, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(reporters) == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	var boardID, postID int64
+	var snapshot []byte
+	if target.Type != entity.ReportUser && !target.Deleted {
+		boardID, postID, snapshot, err = snapshotTarget(ctx, tx, target.Type, target.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+		err = nil
+	}
+	switch action {
+	case entity.ResolveDelete:
+		if target.Type == entity.ReportPost {
+			_, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE id=$1`, target.ID)
+		} else {
+			_, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE id=$1`, target.ID)
+		}
+	case entity.ResolveWarn, entity.ResolveBan:
+		kind := entity.SanctionWarning
+		if action == entity.ResolveBan {
+			kind = entity.SanctionBan
+		}
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO user_sanctions (user_id, kind, reason, moderator_id) VALUES ($1, $2, $3, $4)`,
+			target.AuthorID, kind, note, moderatorID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: moderatorID, Action: entity.ModResolveReports,
+		TargetType: target.Type, PostID: postID, TargetID: target.ID, BoardID: boardID, Reason: note, Snapshot: snapshot}, map[string]any{
+		"action": action, "reports": len(reporters), "author_id": target.AuthorID,
+	}); err != nil {
+		return nil, err
+	}
+	return reporters, tx.Commit()
+}
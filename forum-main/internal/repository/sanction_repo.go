@@ -0,0 +1,206 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"forum1/internal/entity"
+)
+
+// ErrSanctioned is returned for writes by a suspended or banned user, the
+// error is a *SanctionError naming the sanction
+var ErrSanctioned = errors.New("sanctioned")
+
+// SanctionError is the sanction that refused a write
+type SanctionError struct {
+	Sanction entity.Sanction
+}
+
+func (e *SanctionError) Error() string {
+	s := e.Sanction
+	var msg string
+	switch s.Kind {
+	case entity.SanctionSuspension:
+		msg = "you are suspended"
+	case entity.SanctionBoardBan:
+		msg = "you are banned from this board"
+	default:
+		msg = "you are banned"
+	}
+	if s.EndsAt != nil {
+		msg += " until " + s.EndsAt.UTC().Format("2006-01-02 15:04 UTC")
+	}
+	if s.Reason != "" {
+		msg += ": " + s.Reason
+	}
+	return msg
+}
+
+func (e *SanctionError) Unwrap() error { return ErrSanctioned }
+
+// sanctionBlocks is an SQL condition on user_sanctions us that holds for
+// sanctions in force which keep their user from writing in the board with
+// id board: suspensions, bans and bans from that board.
+func sanctionBlocks(board string) string {
+	return `us.revoked_at IS NULL AND (us.ends_at IS NULL OR us.ends_at > now())
+        AND (us.kind IN ('suspension', 'ban') OR (us.kind = 'board_ban' AND us.board_id = ` + board + `))`
+}
+
+// notSanctioned holds when no sanction keeps the user with id param user
+// from writing in the board with id board. It goes next to the boardOpen
+// guards of writes.
+func notSanctioned(user, board string) string {
+	return `NOT EXISTS (SELECT 1 FROM user_sanctions us WHERE us.user_id = ` + user + ` AND ` + sanctionBlocks(board) + `)`
+}
+
+// Boards of a post and of a comment by $2, for notSanctioned and
+// sanctionReason
+const (
+	postBoard    = `(SELECT board_id FROM posts WHERE id = $2)`
+	commentBoard = `(SELECT p.board_id FROM comments c JOIN posts p ON p.id = c.post_id WHERE c.id = $2)`
+)
+
+// sanctionReason tells whether a sanction refused a guarded write, board
+// is an SQL expression of the board by $2 = id
+func sanctionReason(ctx context.Context, db *sql.DB, userID int64, board string, id int64, err error) error {
+	if !errors.Is(err, ErrBoardArchived) {
+		return err
+	}
+	var s entity.Sanction
+	row := db.QueryRowContext(ctx, sanctionSelect+`
+        WHERE us.user_id = $1 AND `+sanctionBlocks(board)+`
+        ORDER BY us.ends_at DESC NULLS FIRST LIMIT 1`, userID, id)
+	if scanSanction(row, &s) != nil {
+		return err
+	}
+	return &SanctionError{Sanction: s}
+}
+
+const sanctionSelect = `
+        SELECT us.id, us.user_id, us.kind, us.reason, COALESCE(us.moderator_id, 0), COALESCE(mu.username, ''),
+               COALESCE(us.board_id, 0), COALESCE(b.title, ''), us.ends_at, us.seen_at, us.revoked_at, us.created_at
+        FROM user_sanctions us
+        LEFT JOIN users mu ON mu.id = us.moderator_id
+        LEFT JOIN boards b ON b.id = us.board_id`
+
+func scanSanction(row interface{ Scan(...any) error }, s *entity.Sanction) error {
+	return row.Scan(&s.ID, &s.UserID, &s.Kind, &s.Reason, &s.ModeratorID, &s.ModeratorName,
+		&s.BoardID, &s.BoardTitle, &s.EndsAt, &s.SeenAt, &s.RevokedAt, &s.CreatedAt)
+}
+
+// SanctionRepository keeps warnings, suspensions and bans. Issuing and
+// revoking are one transaction together with their audit log entry.
+type SanctionRepository interface {
+	// Create issues s, sql.ErrNoRows if its board does not exist
+	Create(ctx context.Context, s *entity.Sanction) (int64, error)
+	GetByID(ctx context.Context, id int64) (*entity.Sanction, error)
+	// Revoke ends s early, sql.ErrNoRows if it is already revoked
+	Revoke(ctx context.Context, moderatorID int64, s *entity.Sanction) error
+	// ListByUser lists all sanctions of the user, newest first
+	ListByUser(ctx context.Context, userID int64) ([]entity.Sanction, error)
+	// Current lists the sanctions of the user that are in force or that
+	// the user has not seen yet, newest first
+	Current(ctx context.Context, userID int64) ([]entity.Sanction, error)
+	MarkSeen(ctx context.Context, userID int64) error
+}
+
+func NewSanctionRepository(db *sql.DB) SanctionRepository {
+	return &sanctionRepository{db: db}
+}
+
+type sanctionRepository struct{ db *sql.DB }
+
+func (r *sanctionRepository) Create(ctx context.Context, s *entity.Sanction) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO user_sanctions (user_id, kind, reason, moderator_id, board_id, ends_at)
+        SELECT $1, $2, $3, $4, NULLIF($5::int, 0), $6::timestamptz
+        WHERE $5::int = 0 OR EXISTS (SELECT 1 FROM boards WHERE id = $5)
+        RETURNING id, created_at`,
+		s.UserID, s.Kind, s.Reason, s.ModeratorID, s.BoardID, s.EndsAt,
+	).Scan(&s.ID, &s.CreatedAt)
+	if err != nil {
+		return 0, err
+	}
+	details := map[string]any{"sanction_id": s.ID, "kind": s.Kind}
+	if s.EndsAt != nil {
+		details["ends_at"] = s.EndsAt
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: s.ModeratorID, Action: entity.ModIssueSanction,
+		TargetType: entity.TargetUser, TargetID: s.UserID, BoardID: s.BoardID, Reason: s.Reason}, details); err != nil {
+		return 0, err
+	}
+	return s.ID, tx.Commit()
+}
+
+func (r *sanctionRepository) GetByID(ctx context.Context, id int64) (*entity.Sanction, error) {
+	var s entity.Sanction
+	if err := scanSanction(r.db.QueryRowContext(ctx, sanctionSelect+` WHERE us.id = $1`, id), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *sanctionRepository) Revoke(ctx context.Context, moderatorID int64, s *entity.Sanction) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.ExecContext(ctx, `
+        UPDATE user_sanctions SET revoked_at = now(), revoked_by = $2
+        WHERE id = $1 AND revoked_at IS NULL`, s.ID, moderatorID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := logAction(ctx, tx, &entity.ModAction{ModeratorID: moderatorID, Action: entity.ModRevokeSanction,
+		TargetType: entity.TargetUser, TargetID: s.UserID, BoardID: s.BoardID, Reason: s.Reason}, map[string]any{
+		"sanction_id": s.ID, "kind": s.Kind,
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sanctionRepository) ListByUser(ctx context.Context, userID int64) ([]entity.Sanction, error) {
+	return r.list(ctx, sanctionSelect+`
+        WHERE us.user_id = $1
+        ORDER BY us.created_at DESC, us.id DESC`, userID)
+}
+
+func (r *sanctionRepository) Current(ctx context.Context, userID int64) ([]entity.Sanction, error) {
+	return r.list(ctx, sanctionSelect+`
+        WHERE us.user_id = $1 AND us.revoked_at IS NULL
+          AND (us.seen_at IS NULL OR (us.kind <> 'warning' AND (us.ends_at IS NULL OR us.ends_at > now())))
+        ORDER BY us.created_at DESC, us.id DESC`, userID)
+}
+
+func (r *sanctionRepository) list(ctx context.Context, query string, args ...any) ([]entity.Sanction, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []entity.Sanction
+	for rows.Next() {
+		var s entity.Sanction
+		if err := scanSanction(rows, &s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *sanctionRepository) MarkSeen(ctx context.Context, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE user_sanctions SET seen_at = now() WHERE user_id = $1 AND seen_at IS NULL`, userID)
+	return err
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+	"time"
+)
+
+type SavedSearchRepository interface {
+	Create(ctx context.Context, s *entity.SavedSearch) (int64, error)
+	ListByUser(ctx context.Context, userID int64) ([]entity.SavedSearch, error)
+	ListAll(ctx context.Context) ([]entity.SavedSearch, error)
+	SetMode(ctx context.Context, id, userID int64, mode string) error
+	Delete(ctx context.Context, id, userID int64) error
+	// Advance stores the publication number a saved search has been
+	// matched up to
+	Advance(ctx context.Context, id, lastSeq int64, runAt time.Time) error
+	// LatestSeq returns the highest publication number of posts and comments
+	LatestSeq(ctx context.Context) (int64, error)
+	// SettledSeq returns a publication number no uncommitted post or
+	// comment is numbered at or below, as of the previous call at the latest
+	SettledSeq(ctx context.Context) (int64, error)
+}
+
+func NewSavedSearchRepository(db *sql.DB) SavedSearchRepository {
+	return &savedSearchRepository{db: db}
+}
+
+type savedSearchRepository struct{ db *sql.DB }
+
+const savedSearchColumns = `id, user_id, query, mode, last_seq, last_run_at, created_at`
+
+func scanSavedSearches(rows *sql.Rows) ([]entity.SavedSearch, error) {
+	defer rows.Close()
+	var res []entity.SavedSearch
+	for rows.Next() {
+		var s entity.SavedSearch
+		if err := rows.Scan(&s.ID, &s.UserID, &s.Query, &s.Mode, &s.LastSeq, &s.LastRunAt, &s.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
+
+func (r *savedSearchRepository) Create(ctx context.Context, s *entity.SavedSearch) (int64, error) {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO saved_searches (user_id, query, mode, last_seq)
+        VALUES ($1,$2,$3,$4)
+        ON CONFLICT (user_id, query) DO UPDATE SET mode=EXCLUDED.mode
+        RETURNING id, last_run_at, created_at`,
+		s.UserID, s.Query, s.Mode, s.LastSeq).Scan(&s.ID, &s.LastRunAt, &s.CreatedAt)
+	return s.ID, err
+}
+
+func (r *savedSearchRepository) ListByUser(ctx context.Context, userID int64) ([]entity.SavedSearch, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE user_id=$1 ORDER BY created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSavedSearches(rows)
+}
+
+func (r *savedSearchRepository) ListAll(ctx context.Context) ([]entity.SavedSearch, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	return scanSavedSearches(rows)
+}
+
+func (r *savedSearchRepository) SetMode(ctx context.Context, id, userID int64, mode string) error {
+	res, err := r.db.ExecContext(ctx, `UPDATE saved_searches SET mode=$3 WHERE id=$1 AND user_id=$2`, id, userID, mode)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *savedSearchRepository) Delete(ctx context.Context, id, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM saved_searches WHERE id=$1 AND user_id=$2`, id, userID)
+	return err
+}
+
+func (r *savedSearchRepository) Advance(ctx context.Context, id, lastSeq int64, runAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE saved_searches SET last_seq=$2, last_run_at=$3
+        WHERE id=$1`, id, lastSeq, runAt)
+	return err
+}
+
+func (r *savedSearchRepository) LatestSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT GREATEST((SELECT COALESCE(MAX(published_seq), 0) FROM posts),
+                        (SELECT COALESCE(MAX(published_seq), 0) FROM comments))`).Scan(&seq)
+	return seq, err
+}
+
+func (r *savedSearchRepository) SettledSeq(ctx context.Context) (int64, error) {
+	// the snapshot is taken by a later statement than the number, so every
+	// transaction that took a number up to seq has an id below its xmax
+	var seq int64
+	err := r.db.QueryRowContext(ctx, `
+        SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM publish_seq`).Scan(&seq)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.db.ExecContext(ctx, `
+        INSERT INTO publish_marks (seq, snapshot_xmax)
+        VALUES ($1, pg_snapshot_xmax(pg_current_snapshot()))`, seq); err != nil {
+		return 0, err
+	}
+	// the latest mark no running transaction is older than, earlier ones
+	// aren't needed anymore
+	err = r.db.QueryRowContext(ctx, `
+        WITH settled AS (
+            SELECT id, seq FROM publish_marks
+            WHERE snapshot_xmax <= pg_snapshot_xmin(pg_current_snapshot())
+            ORDER BY id DESC LIMIT 1
+        ), pruned AS (
+            DELETE FROM publish_marks WHERE id < (SELECT id FROM settled)
+        )
+        SELECT seq FROM settled`).Scan(&seq)
+	return seq, err
+}
@@ -0,0 +1,321 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"forum1/internal/entity"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// Markers ts_headline puts around matched terms. They are private use code
+// points, so they can't clash with user text: the snippet is HTML-escaped
+// first and only then the markers become <mark> tags.
+const (
+	highlightStart = "\ue000"
+	highlightStop  = "\ue001"
+)
+
+func highlightHTML(s string) template.HTML {
+	s = html.EscapeString(s)
+	s = strings.ReplaceAll(s, highlightStart, "<mark>")
+	s = strings.ReplaceAll(s, highlightStop, "</mark>")
+	return template.HTML(s)
+}
+
+// searchQuerySQL turns the user query ($1) into a tsquery matching both the
+// Russian and the English stemming of its words. websearch_to_tsquery never
+// fails on user input: it understands "phrases", OR and -excluded words.
+const searchQuerySQL = `(websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1))`
+
+// prefixQuerySQL is the typeahead variant: $1 is already a valid
+// to_tsquery string like "foo:* & ba:*".
+const prefixQuerySQL = `(to_tsquery('russian', $1) || to_tsquery('english', $1))`
+
+var (
+	titleHeadline   = "HighlightAll=true, StartSel=" + highlightStart + ", StopSel=" + highlightStop
+	snippetHeadline = "MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \", StartSel=" + highlightStart + ", StopSel=" + highlightStop
+)
+
+type SearchRepository interface {
+	SearchPosts(ctx context.Context, q entity.SearchQuery, limit, offset int) ([]entity.PostHit, int, error)
+	SearchComments(ctx context.Context, q entity.SearchQuery, limit, offset int) ([]entity.CommentHit, int, error)
+	SearchBoards(ctx context.Context, text string, limit, offset int) ([]entity.Board, int, error)
+	SearchUsers(ctx context.Context, prefix string, limit, offset int) ([]entity.UserHit, int, error)
+	// Suggest returns up to limit suggestions of every type for a
+	// to_tsquery prefix query and a username prefix.
+	Suggest(ctx context.Context, prefixQuery, userPrefix string, limit int) ([]entity.Suggestion, error)
+}
+
+func NewSearchRepository(db *sql.DB) SearchRepository {
+	return &searchRepository{db: db}
+}
+
+type searchRepository struct{ db *sql.DB }
+
+// WebsearchText renders the words and phrases of q back into
+// websearch_to_tsquery syntax.
+func WebsearchText(q entity.SearchQuery) string {
+	parts := make([]string, 0, len(q.Terms)+len(q.Phrases))
+	parts = append(parts, q.Terms...)
+	for _, p := range q.Phrases {
+		parts = append(parts, `"`+p+`"`)
+	}
+	return strings.Join(parts, " ")
+}
+
+// excludedText joins the excluded words and phrases with OR, so a document
+// matching any of them is dropped.
+func excludedText(q entity.SearchQuery) string {
+	parts := make([]string, len(q.Excluded))
+	for i, e := range q.Excluded {
+		if strings.Contains(e, " ") {
+			e = `"` + e + `"`
+		}
+		parts[i] = e
+	}
+	return strings.Join(parts, " or ")
+}
+
+// searchSQL collects the WHERE conditions and arguments of a search
+type searchSQL struct {
+	where []string
+	args  []any
+}
+
+func (s *searchSQL) arg(v any) string {
+	s.args = append(s.args, v)
+	return fmt.Sprintf("$%d", len(s.args))
+}
+
+// match adds the text conditions of q against vector and returns the
+// tsquery expression, or "" if q has filters only.
+func (s *searchSQL) match(q entity.SearchQuery, vector string) string {
+	query := ""
+	if q.HasText() {
+		query = strings.ReplaceAll(searchQuerySQL, "$1", s.arg(WebsearchText(q)))
+		s.where = append(s.where, vector+" @@ "+query)
+	}
+	if len(q.Excluded) > 0 {
+		s.where = append(s.where, "NOT "+vector+" @@ "+strings.ReplaceAll(searchQuerySQL, "$1", s.arg(excludedText(q))))
+	}
+	return query
+}
+
+// filters adds the filters of q. seq, author, created and text are the
+// columns of the searched document, p and b are its post and board.
+func (s *searchSQL) filters(q entity.SearchQuery, seq, author, created, text string) {
+	if q.AfterSeq > 0 {
+		s.where = append(s.where, seq+" > "+s.arg(q.AfterSeq))
+	}
+	if q.UpToSeq > 0 {
+		s.where = append(s.where, seq+" <= "+s.arg(q.UpToSeq))
+	}
+	if q.ExcludeAuthorID > 0 {
+		s.where = append(s.where, author+" <> "+s.arg(q.ExcludeAuthorID))
+	}
+	if q.Board != "" {
+		s.where = append(s.where, "b.slug = "+s.arg(q.Board))
+	}
+	if q.Author != "" {
+		s.where = append(s.where, author+" IN (SELECT id FROM users WHERE lower(username) = lower("+s.arg(q.Author)+"))")
+	}
+	for _, tag := range q.Tags {
+		// tags are checked against tagPattern, only "-" needs escaping
+		re := `(^|[^[:alnum:]_])#` + strings.ReplaceAll(tag, "-", `\-`) + `([^[:alnum:]_-]|$)`
+		s.where = append(s.where, text+" ~* "+s.arg(re))
+	}
+	if !q.Before.IsZero() {
+		s.where = append(s.where, created+" < "+s.arg(q.Before))
+	}
+	if !q.After.IsZero() {
+		s.where = append(s.where, created+" >= "+s.arg(q.After.AddDate(0, 0, 1)))
+	}
+	if q.HasImage {
+		s.where = append(s.where, "(p.image_data IS NOT NULL OR coalesce(p.image_url, '') <> '')")
+	}
+	if q.HasLink {
+		s.where = append(s.where, "coalesce(p.link_url, '') <> ''")
+	}
+}
+
+func (s *searchSQL) cond() string {
+	if len(s.where) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(s.where, " AND ")
+}
+
+// SearchPosts returns a page of posts matching q, with highlighted title
+// and content snippet, and the total number of matches. Posts are ordered
+// by relevance, or by date when q has filters only. Held posts are not
+// searchable.
+func (r *searchRepository) SearchPosts(ctx context.Context, q entity.SearchQuery, limit, offset int) ([]entity.PostHit, int, error) {
+	var s searchSQL
+	rank, title, snippet, order := "0::real", "p.title", "left(coalesce(p.content, ''), 200)", "p.created_at DESC, p.id DESC"
+	if query := s.match(q, "p.search_vector"); query != "" {
+		rank = "ts_rank(p.search_vector, " + query + ")"
+		title = "ts_headline('russian', p.title, " + query + ", " + s.arg(titleHeadline) + ")"
+		snippet = "ts_headline('russian', coalesce(p.content, ''), " + query + ", " + s.arg(snippetHeadline) + ")"
+		order = "rank DESC, p.id DESC"
+	}
+	s.filters(q, "p.published_seq", "p.author_id", "p.created_at", "(p.title || ' ' || coalesce(p.content, ''))")
+	s.where = append(s.where, boardVisible("b.id", s.arg(viewerID(ctx))), "NOT p.pending")
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT p.id, COALESCE(p.published_seq, 0), p.board_id, b.slug, b.title, p.author_id, p.created_at,
+               `+rank+` AS rank, `+title+`, `+snippet+`,
+               COUNT(*) OVER () AS total
+        FROM posts p
+        JOIN boards b ON b.id = p.board_id
+        WHERE `+s.cond()+`
+        ORDER BY `+order+`
+        LIMIT `+s.arg(limit)+` OFFSET `+s.arg(offset), s.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []entity.PostHit
+	total := 0
+	for rows.Next() {
+		var h entity.PostHit
+		var title, snippet string
+		if err := rows.Scan(&h.ID, &h.Seq, &h.BoardID, &h.BoardSlug, &h.BoardTitle, &h.AuthorID, &h.CreatedAt,
+			&h.Rank, &title, &snippet, &total); err != nil {
+			return nil, 0, err
+		}
+		h.Title, h.Snippet = highlightHTML(title), highlightHTML(snippet)
+		res = append(res, h)
+	}
+	return res, total, rows.Err()
+}
+
+// SearchComments is SearchPosts for comments. Board and has: filters apply
+// to the post of the comment.
+func (r *searchRepository) SearchComments(ctx context.Context, q entity.SearchQuery, limit, offset int) ([]entity.CommentHit, int, error) {
+	var s searchSQL
+	rank, snippet, order := "0::real", "left(c.content, 200)", "c.created_at DESC, c.id DESC"
+	if query := s.match(q, "c.search_vector"); query != "" {
+		rank = "ts_rank(c.search_vector, " + query + ")"
+		snippet = "ts_headline('russian', c.content, " + query + ", " + s.arg(snippetHeadline) + ")"
+		order = "rank DESC, c.id DESC"
+	}
+	s.filters(q, "c.published_seq", "c.author_id", "c.created_at", "c.content")
+	s.where = append(s.where, boardVisible("b.id", s.arg(viewerID(ctx))), "NOT c.pending", "NOT p.pending")
+
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT c.id, COALESCE(c.published_seq, 0), c.post_id, p.title, c.author_id, u.username, c.created_at,
+               `+rank+` AS rank, `+snippet+`,
+               COUNT(*) OVER () AS total
+        FROM comments c
+        JOIN posts p ON p.id = c.post_id
+        JOIN boards b ON b.id = p.board_id
+        JOIN users u ON u.id = c.author_id
+        WHERE `+s.cond()+`
+        ORDER BY `+order+`
+        LIMIT `+s.arg(limit)+` OFFSET `+s.arg(offset), s.args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []entity.CommentHit
+	total := 0
+	for rows.Next() {
+		var h entity.CommentHit
+		var snippet string
+		if err := rows.Scan(&h.ID, &h.Seq, &h.PostID, &h.PostTitle, &h.AuthorID, &h.AuthorName, &h.CreatedAt,
+			&h.Rank, &snippet, &total); err != nil {
+			return nil, 0, err
+		}
+		h.Snippet = highlightHTML(snippet)
+		res = append(res, h)
+	}
+	return res, total, rows.Err()
+}
+
+func (r *searchRepository) SearchBoards(ctx context.Context, text string, limit, offset int) ([]entity.Board, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT b.id, b.slug, b.title, COALESCE(b.description, ''), COUNT(*) OVER ()
+        FROM boards b
+        CROSS JOIN (SELECT `+searchQuerySQL+` AS query) q
+        WHERE b.search_vector @@ q.query AND `+boardVisible("b.id", "$4")+`
+        ORDER BY ts_rank(b.search_vector, q.query) DESC, b.title
+        LIMIT $2 OFFSET $3`, text, limit, offset, viewerID(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []entity.Board
+	total := 0
+	for rows.Next() {
+		var b entity.Board
+		if err := rows.Scan(&b.ID, &b.Slug, &b.Title, &b.Description, &total); err != nil {
+			return nil, 0, err
+		}
+		res = append(res, b)
+	}
+	return res, total, rows.Err()
+}
+
+// likePrefix escapes the LIKE wildcards of a prefix
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return strings.ToLower(r.Replace(prefix)) + "%"
+}
+
+// SearchUsers finds users whose name starts with prefix, shortest names
+// (the closest matches) first.
+func (r *searchRepository) SearchUsers(ctx context.Context, prefix string, limit, offset int) ([]entity.UserHit, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT id, username, created_at, COUNT(*) OVER ()
+        FROM users
+        WHERE lower(username) LIKE $1
+        ORDER BY length(username), lower(username)
+        LIMIT $2 OFFSET $3`, likePrefix(prefix), limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var res []entity.UserHit
+	total := 0
+	for rows.Next() {
+		var u entity.UserHit
+		if err := rows.Scan(&u.ID, &u.Username, &u.CreatedAt, &total); err != nil {
+			return nil, 0, err
+		}
+		res = append(res, u)
+	}
+	return res, total, rows.Err()
+}
+
+func (r *searchRepository) Suggest(ctx context.Context, prefixQuery, userPrefix string, limit int) ([]entity.Suggestion, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH q AS (SELECT `+prefixQuerySQL+` AS query)
+        (SELECT 'board', b.title, '/board/' || b.slug
+         FROM boards b, q WHERE b.search_vector @@ q.query AND `+boardVisible("b.id", "$4")+`
+         ORDER BY ts_rank(b.search_vector, q.query) DESC LIMIT $3)
+        UNION ALL
+        (SELECT 'post', p.title, '/post/' || p.id
+         FROM posts p, q WHERE p.search_vector @@ q.query AND NOT p.pending AND `+boardVisible("p.board_id", "$4")+`
+         ORDER BY ts_rank(p.search_vector, q.query) DESC, p.id DESC LIMIT $3)
+        UNION ALL
+        (SELECT 'user', u.username, '/profile/' || u.id
+         FROM users u WHERE lower(u.username) LIKE $2
+         ORDER BY length(u.username), lower(u.username) LIMIT $3)`,
+		prefixQuery, likePrefix(userPrefix), limit, viewerID(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.Suggestion
+	for rows.Next() {
+		var s entity.Suggestion
+		if err := rows.Scan(&s.Type, &s.Label, &s.URL); err != nil {
+			return nil, err
+		}
+		res = append(res, s)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"forum1/internal/entity"
+
+	"github.com/lib/pq"
+)
+
+// SpamRepository stores the spam filter model and reads the moderator
+// decisions it learns from
+type SpamRepository interface {
+	Model(ctx context.Context) (*entity.SpamModel, error)
+	// TokenCounts returns the counts of the tokens the model knows
+	TokenCounts(ctx context.Context, tokens []string) (map[string]entity.SpamTokenCount, error)
+	// Decisions returns up to limit approvals and deletions as spam logged
+	// after the audit log entry afterID, oldest first
+	Decisions(ctx context.Context, afterID int64, limit int) ([]entity.SpamExample, error)
+	// Train adds the tokens of the example to the model, replacing an
+	// earlier example of the same target. Examples the model has already
+	// gone past are skipped, without tokens the model only moves past it.
+	Train(ctx context.Context, ex *entity.SpamExample, tokens []string) error
+	// Reset empties the model
+	Reset(ctx context.Context) error
+}
+
+func NewSpamRepository(db *sql.DB) SpamRepository {
+	return &spamRepository{db: db}
+}
+
+type spamRepository struct{ db *sql.DB }
+
+func (r *spamRepository) Model(ctx context.Context) (*entity.SpamModel, error) {
+	var m entity.SpamModel
+	err := r.db.QueryRowContext(ctx, `SELECT spam_docs, ham_docs, last_action_id, updated_at FROM spam_model`).
+		Scan(&m.SpamDocs, &m.HamDocs, &m.LastActionID, &m.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *spamRepository) TokenCounts(ctx context.Context, tokens []string) (map[string]entity.SpamTokenCount, error) {
+	counts := make(map[string]entity.SpamTokenCount)
+	if len(tokens) == 0 {
+		return counts, nil
+	}
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT token, spam_count, ham_count FROM spam_tokens WHERE token = ANY($1)`, pq.Array(tokens))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token string
+		var c entity.SpamTokenCount
+		if err := rows.Scan(&token, &c.Spam, &c.Ham); err != nil {
+			return nil, err
+		}
+		counts[token] = c
+	}
+	return counts, rows.Err()
+}
+
+// Approvals are read with the snapshot the approval logged, or the live
+// row for approvals logged before they had one.
+func (r *spamRepository) Decisions(ctx context.Context, afterID int64, limit int) ([]entity.SpamExample, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT a.id, a.target_type, a.target_id, a.action IN ('delete_post', 'delete_comment'),
+               COALESCE(a.snapshot, CASE a.target_type
+                   WHEN 'post' THEN (SELECT `+postSnapshot+` FROM posts WHERE id = a.target_id)
+                   ELSE (SELECT `+commentSnapshot+` FROM comments WHERE id = a.target_id) END)
+        FROM mod_actions a
+        WHERE a.id > $1 AND a.target_id IS NOT NULL
+          AND (a.action IN ('approve_post', 'approve_comment')
+               OR (a.action IN ('delete_post', 'delete_comment') AND COALESCE((a.details->>'spam')::boolean, false)))
+        ORDER BY a.id
+        LIMIT $2`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var examples []entity.SpamExample
+	for rows.Next() {
+		var ex entity.SpamExample
+		var snapshot []byte
+		if err := rows.Scan(&ex.ActionID, &ex.TargetType, &ex.TargetID, &ex.Spam, &snapshot); err != nil {
+			return nil, err
+		}
+		// the content of an old approval may be gone, the example is then empty
+		if len(snapshot) > 0 {
+			if err := json.Unmarshal(snapshot, &ex.Doc); err != nil {
+				return nil, err
+			}
+		}
+		examples = append(examples, ex)
+	}
+	return examples, rows.Err()
+}
+
+// addTokens adds n to the spam or ham count of the tokens
+func addTokens(ctx context.Context, tx *sql.Tx, tokens []string, spam bool, n int) error {
+	spamN, hamN := 0, n
+	if spam {
+		spamN, hamN = n, 0
+	}
+	_, err := tx.ExecContext(ctx, `
+        INSERT INTO spam_tokens (token, spam_count, ham_count)
+        SELECT t, $2, $3 FROM unnest($1::text[]) t
+        ON CONFLICT (token) DO UPDATE
+        SET spam_count = spam_tokens.spam_count + EXCLUDED.spam_count, ham_count = spam_tokens.ham_count + EXCLUDED.ham_count`,
+		pq.Array(tokens), spamN, hamN)
+	return err
+}
+
+func (r *spamRepository) Train(ctx context.Context, ex *entity.SpamExample, tokens []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var last int64
+	if err := tx.QueryRowContext(ctx, `SELECT last_action_id FROM spam_model FOR UPDATE`).Scan(&last); err != nil {
+		return err
+	}
+	if ex.ActionID <= last {
+		return nil
+	}
+	if len(tokens) > 0 {
+		var prevSpam bool
+		var prevTokens pq.StringArray
+		err := tx.QueryRowContext(ctx, `
+            SELECT spam, tokens FROM spam_examples WHERE target_type=$1 AND target_id=$2`, ex.TargetType, ex.TargetID).
+			Scan(&prevSpam, &prevTokens)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		default:
+			if err := addTokens(ctx, tx, prevTokens, prevSpam, -1); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `
+                DELETE FROM spam_tokens WHERE token = ANY($1) AND spam_count <= 0 AND ham_count <= 0`, prevTokens); err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, `
+                UPDATE spam_model SET spam_docs = spam_docs - $1::int, ham_docs = ham_docs - (1 - $1::int)`, boolInt(prevSpam)); err != nil {
+				return err
+			}
+		}
+		if err := addTokens(ctx, tx, tokens, ex.Spam, 1); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+            INSERT INTO spam_examples (target_type, target_id, spam, tokens) VALUES ($1, $2, $3, $4)
+            ON CONFLICT (target_type, target_id) DO UPDATE SET spam = EXCLUDED.spam, tokens = EXCLUDED.tokens`,
+			ex.TargetType, ex.TargetID, ex.Spam, pq.Array(tokens)); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+            UPDATE spam_model SET spam_docs = spam_docs + $1::int, ham_docs = ham_docs + (1 - $1::int)`, boolInt(ex.Spam)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE spam_model SET last_action_id=$1, updated_at=now()`, ex.ActionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *spamRepository) Reset(ctx context.Context) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `
+        UPDATE spam_model SET spam_docs=0, ham_docs=0, last_action_id=0, updated_at=now()`); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `TRUNCATE spam_tokens, spam_examples`); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+	"time"
+)
+
+// TrustRepository reads what trust levels are computed from and stores
+// the levels
+type TrustRepository interface {
+	// Stats returns the trust stats of every user, or of the user with id
+	// userID when it isn't 0; flags count from flagsSince on
+	Stats(ctx context.Context, userID int64, flagsSince time.Time) ([]entity.TrustStats, error)
+	SetLevel(ctx context.Context, userID int64, level int) error
+	// RecordView marks the post read by the user, repeated views count once
+	RecordView(ctx context.Context, postID, userID int64) error
+}
+
+func NewTrustRepository(db *sql.DB) TrustRepository {
+	return &trustRepository{db: db}
+}
+
+type trustRepository struct{ db *sql.DB }
+
+// Reads are of the posts of other users. Flags are upheld reports (any
+// resolution but dismiss) and moderator deletions, attributed by the author
+// in the audit log entry; votes are the likes of other users.
+func (r *trustRepository) Stats(ctx context.Context, userID int64, flagsSince time.Time) ([]entity.TrustStats, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH reads AS (
+            SELECT pv.user_id, COUNT(*) AS n FROM post_views pv JOIN posts p ON p.id = pv.post_id
+            WHERE p.author_id <> pv.user_id
+            GROUP BY pv.user_id
+        ), made AS (
+            SELECT author_id, COUNT(*) AS n FROM (
+                SELECT author_id FROM posts WHERE NOT pending
+                UNION ALL
+                SELECT author_id FROM comments WHERE NOT pending) m
+            GROUP BY author_id
+        ), likes AS (
+            SELECT author_id, COUNT(*) AS n FROM (
+                SELECT p.author_id FROM post_votes v JOIN posts p ON p.id = v.post_id
+                WHERE v.value = 1 AND v.user_id <> p.author_id
+                UNION ALL
+                SELECT c.author_id FROM comment_votes v JOIN comments c ON c.id = v.comment_id
+                WHERE v.value = 1 AND v.user_id <> c.author_id) l
+            GROUP BY author_id
+        ), flags AS (
+            SELECT COALESCE(details->>'author_id', snapshot->>'author_id')::int AS user_id, COUNT(*) AS n
+            FROM mod_actions
+            WHERE created_at >= $2
+              AND (action IN ('delete_post', 'delete_comment')
+                   OR (action = 'resolve_reports' AND details->>'action' <> 'dismiss'))
+            GROUP BY 1
+        )
+        SELECT u.id, u.trust_level, EXTRACT(DAY FROM now() - u.created_at)::int,
+               COALESCE(rd.n, 0), COALESCE(m.n, 0), COALESCE(l.n, 0), COALESCE(f.n, 0)
+        FROM users u
+        LEFT JOIN reads rd ON rd.user_id = u.id
+        LEFT JOIN made m ON m.author_id = u.id
+        LEFT JOIN likes l ON l.author_id = u.id
+        LEFT JOIN flags f ON f.user_id = u.id
+        WHERE $1 = 0 OR u.id = $1
+        ORDER BY u.id`, userID, flagsSince)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []entity.TrustStats
+	for rows.Next() {
+		var st entity.TrustStats
+		if err := rows.Scan(&st.UserID, &st.Level, &st.AccountAgeDays, &st.PostsRead,
+			&st.Contributions, &st.VotesReceived, &st.FlagsReceived); err != nil {
+			return nil, err
+		}
+		res = append(res, st)
+	}
+	return res, rows.Err()
+}
+
+func (r *trustRepository) SetLevel(ctx context.Context, userID int64, level int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET trust_level=$2, trust_updated_at=now() WHERE id=$1`, userID, level)
+	return err
+}
+
+func (r *trustRepository) RecordView(ctx context.Context, postID, userID int64) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO post_views (post_id, user_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, postID, userID)
+	return err
+}
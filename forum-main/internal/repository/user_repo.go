@@ -10,6 +10,7 @@ type UserRepository interface {
 	CreateUser(ctx context.Context, u *entity.User) (int64, error)
 	GetUserByName(ctx context.Context, username string) (*entity.User, error)
 	GetUserByID(ctx context.Context, id int64) (*entity.User, error)
+	SetRole(ctx context.Context, username, role string) error
 }
 
 type userRepository struct{ db *sql.DB }
@@ -29,11 +30,11 @@ func (r *userRepository) CreateUser(ctx context.Context, u *entity.User) (int64,
 
 func (r *userRepository) GetUserByName(ctx context.Context, username string) (*entity.User, error) {
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, username, email, password, created_at, updated_at FROM users WHERE username=$1`,
+		`SELECT id, username, COALESCE(email, ''), password, role, trust_level, created_at, updated_at FROM users WHERE username=$1`,
 		username,
 	)
 	var u entity.User
-	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.Role, &u.TrustLevel, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		return nil, err
 	}
 	return &u, nil
@@ -41,12 +42,17 @@ func (r *userRepository) GetUserByName(ctx context.Context, username string) (*e
 
 func (r *userRepository) GetUserByID(ctx context.Context, id int64) (*entity.User, error) {
 	row := r.db.QueryRowContext(ctx,
-		`SELECT id, username, email, password, created_at, updated_at FROM users WHERE id=$1`,
+		`SELECT id, username, COALESCE(email, ''), password, role, trust_level, created_at, updated_at FROM users WHERE id=$1`,
 		id,
 	)
 	var u entity.User
-	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.CreatedAt, &u.UpdatedAt); err != nil {
+	if err := row.Scan(&u.ID, &u.Username, &u.Email, &u.Password, &u.Role, &u.TrustLevel, &u.CreatedAt, &u.UpdatedAt); err != nil {
 		return nil, err
 	}
 	return &u, nil
 }
+
+func (r *userRepository) SetRole(ctx context.Context, username, role string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET role=$2, updated_at=now() WHERE username=$1`, username, role)
+	return err
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"forum1/internal/entity"
+)
+
+// viewerID is the id of the user in ctx, 0 for guests. Reads of boards,
+// posts, comments, events and search results only return what this user
+// may see.
+func viewerID(ctx context.Context) int64 {
+	if u := entity.UserFromContext(ctx); u != nil {
+		return u.ID
+	}
+	return 0
+}
+
+// clubVisible is an SQL condition that holds when the club with id clubID
+// (an SQL expression, NULL for no club) is visible to the user with id
+// param viewer: private clubs are visible to members and site admins only.
+func clubVisible(clubID, viewer string) string {
+	return `NOT EXISTS (SELECT 1 FROM clubs vc
+            WHERE vc.id = ` + clubID + ` AND vc.private
+              AND NOT EXISTS (SELECT 1 FROM club_members vm WHERE vm.club_id = vc.id AND vm.user_id = ` + viewer + `)
+              AND NOT EXISTS (SELECT 1 FROM users vu WHERE vu.id = ` + viewer + ` AND vu.role = 'admin'))`
+}
+
+// boardVisible is clubVisible for the club of the board with id boardID
+func boardVisible(boardID, viewer string) string {
+	return clubVisible(`(SELECT club_id FROM boards WHERE id = `+boardID+`)`, viewer)
+}
+
+// postVisible is boardVisible for the board of the post with id postID,
+// and the post itself must not be held from the viewer
+func postVisible(postID, viewer string) string {
+	return boardVisible(`(SELECT board_id FROM posts WHERE id = `+postID+`)`, viewer) +
+		` AND EXISTS (SELECT 1 FROM posts vp WHERE vp.id = ` + postID + ` AND ` + heldVisible("vp", viewer) + `)`
+}
+
+// heldVisible is an SQL condition that holds when the post or comment
+// (table or alias row) is visible to the user with id param viewer: held
+// content is visible to its author and moderators only
+func heldVisible(row, viewer string) string {
+	return `(NOT ` + row + `.pending OR ` + row + `.author_id = ` + viewer + `
+            OR EXISTS (SELECT 1 FROM users vu WHERE vu.id = ` + viewer + ` AND vu.role IN ('moderator', 'admin')))`
+}
@@ -0,0 +1,30 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"forum1/internal/entity"
+)
+
+// audit returns a as done by the user in ctx, with before as its snapshot
+// when not nil, for a repository write to record in its own transaction;
+// nil without a user
+func audit(ctx context.Context, a entity.ModAction, before any) *entity.ModAction {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return nil
+	}
+	a.ModeratorID = u.ID
+	if b := jsonOf(before); string(b) != "null" {
+		a.Snapshot = b
+	}
+	return &a
+}
+
+func jsonOf(v any) json.RawMessage {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
@@ -3,18 +3,144 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"forum1/internal/entity"
 	"forum1/internal/repository"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrForbidden is returned when the user in the context lacks the role
+	// an action needs
+	ErrForbidden = errors.New("forbidden")
+	// ErrBoardArchived is returned for new content in an archived board
+	ErrBoardArchived = repository.ErrBoardArchived
+	ErrSlugTaken     = repository.ErrSlugTaken
+)
+
+// slugPattern is what a board slug may look like: it is a single URL path
+// segment under /board/.
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+const (
+	maxSlugLength             = 32
+	maxBoardTitleLength       = 100
+	maxBoardDescriptionLength = 500
 )
 
 type BoardService interface {
 	GetBySlug(ctx context.Context, slug string) (*entity.Board, error)
+	GetByID(ctx context.Context, id int64) (*entity.Board, error)
 	List(ctx context.Context) ([]entity.Board, error)
+	// Directory is the boards list page: categories with their boards,
+	// sub-boards and statistics
+	Directory(ctx context.Context) ([]entity.CategoryBoards, error)
+	// Breadcrumbs is the navigation path down to the board
+	Breadcrumbs(ctx context.Context, b *entity.Board) ([]entity.Breadcrumb, error)
+	Categories(ctx context.Context) ([]entity.BoardCategory, error)
+	// CheckPosting tells whether the user in ctx may post in the board, and
+	// CheckPost also applies the image and link settings to the post and
+	// CheckComment the link settings to a comment in the board
+	CheckPosting(ctx context.Context, b *entity.Board) error
+	CheckPost(ctx context.Context, p *entity.Post) error
+	CheckComment(ctx context.Context, boardID int64, content string) error
+	// HoldReason tells why new posts and comments of the user in ctx in the
+	// board wait for a moderator, "" when they are published right away
+	HoldReason(ctx context.Context, boardID int64) (string, error)
+
+	// Admin only, the admin is the user in ctx
+	Create(ctx context.Context, b *entity.Board) (int64, error)
+	Update(ctx context.Context, b *entity.Board) error
+	UpdateSettings(ctx context.Context, id int64, st entity.BoardSettings) error
+	SetArchived(ctx context.Context, id int64, archived bool) error
+	Reorder(ctx context.Context, ids []int64) error
+	CreateCategory(ctx context.Context, c *entity.BoardCategory) (int64, error)
+	UpdateCategory(ctx context.Context, c *entity.BoardCategory) error
+	DeleteCategory(ctx context.Context, id int64) error
+	ReorderCategories(ctx context.Context, ids []int64) error
+}
+
+// BoardServiceOption customizes the board service, see NewBoardService
+type BoardServiceOption func(s *boardService)
+
+// WithBoardTrust gates images and links in new posts by trust level
+func WithBoardTrust(t TrustService) BoardServiceOption {
+	return func(s *boardService) { s.trust = t }
+}
+
+func NewBoardService(repo repository.BoardRepository, opts ...BoardServiceOption) BoardService {
+	s := &boardService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-func NewBoardService(repo repository.BoardRepository) BoardService { return &boardService{repo: repo} }
+type boardService struct {
+	repo  repository.BoardRepository
+	trust TrustService
+}
+
+// requireRole checks the role of the user in ctx
+func requireRole(ctx context.Context, role string) error {
+	if !entity.UserFromContext(ctx).HasRole(role) {
+		return ErrForbidden
+	}
+	return nil
+}
 
-type boardService struct{ repo repository.BoardRepository }
+// validateBoard normalizes and checks the editable fields of b
+func validateBoard(b *entity.Board) error {
+	b.Slug = strings.ToLower(strings.TrimSpace(b.Slug))
+	b.Title = strings.TrimSpace(b.Title)
+	b.Description = strings.TrimSpace(b.Description)
+	if len(b.Slug) > maxSlugLength || !slugPattern.MatchString(b.Slug) {
+		return ErrInvalidInput
+	}
+	if b.Title == "" || utf8.RuneCountInString(b.Title) > maxBoardTitleLength ||
+		utf8.RuneCountInString(b.Description) > maxBoardDescriptionLength {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+// checkPlacement validates the category and parent of b: sub-boards are
+// one level deep, so the parent must be a top level board and b must have
+// no sub-boards of its own.
+func (s *boardService) checkPlacement(ctx context.Context, b *entity.Board) error {
+	if b.CategoryID != 0 {
+		if _, err := s.repo.GetCategory(ctx, b.CategoryID); err != nil {
+			return ErrInvalidInput
+		}
+	}
+	if b.ParentID == 0 {
+		return nil
+	}
+	if b.ParentID == b.ID {
+		return ErrInvalidInput
+	}
+	parent, err := s.repo.GetByID(ctx, b.ParentID)
+	if err != nil || parent.ParentID != 0 {
+		return ErrInvalidInput
+	}
+	if b.ID != 0 {
+		boards, err := s.repo.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, other := range boards {
+			if other.ParentID == b.ID {
+				return ErrInvalidInput
+			}
+		}
+	}
+	// a sub-board is listed in the category of its parent, which migration
+	// 026 keeps in step when the parent moves
+	b.CategoryID = parent.CategoryID
+	return nil
+}
 
 func (s *boardService) GetBySlug(ctx context.Context, slug string) (*entity.Board, error) {
 	if slug == "" {
@@ -22,4 +148,209 @@ func (s *boardService) GetBySlug(ctx context.Context, slug string) (*entity.Boar
 	}
 	return s.repo.GetBySlug(ctx, slug)
 }
+
+func (s *boardService) GetByID(ctx context.Context, id int64) (*entity.Board, error) {
+	if id == 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.GetByID(ctx, id)
+}
+
 func (s *boardService) List(ctx context.Context) ([]entity.Board, error) { return s.repo.List(ctx) }
+
+func (s *boardService) Create(ctx context.Context, b *entity.Board) (int64, error) {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return 0, err
+	}
+	if err := validateBoard(b); err != nil {
+		return 0, err
+	}
+	if err := s.checkPlacement(ctx, b); err != nil {
+		return 0, err
+	}
+	id, err := s.repo.Create(ctx, b, audit(ctx, entity.ModAction{Action: entity.ModCreateBoard, TargetType: entity.TargetBoard}, nil))
+	if errors.Is(err, repository.ErrUnknownReference) {
+		// the club, unlike the category and parent, isn't checked up front
+		return 0, ErrInvalidInput
+	}
+	return id, err
+}
+
+func (s *boardService) Update(ctx context.Context, b *entity.Board) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if b.ID == 0 {
+		return ErrInvalidInput
+	}
+	if err := validateBoard(b); err != nil {
+		return err
+	}
+	if err := s.checkPlacement(ctx, b); err != nil {
+		return err
+	}
+	before, _ := s.repo.GetByID(ctx, b.ID)
+	err := s.repo.Update(ctx, b, audit(ctx, entity.ModAction{Action: entity.ModUpdateBoard, TargetType: entity.TargetBoard, TargetID: b.ID, BoardID: b.ID}, before))
+	if errors.Is(err, repository.ErrUnknownReference) {
+		return ErrInvalidInput
+	}
+	return err
+}
+
+func (s *boardService) SetArchived(ctx context.Context, id int64, archived bool) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if id == 0 {
+		return ErrInvalidInput
+	}
+	action := entity.ModArchiveBoard
+	if !archived {
+		action = entity.ModUnarchiveBoard
+	}
+	return s.repo.SetArchived(ctx, id, archived, audit(ctx, entity.ModAction{Action: action, TargetType: entity.TargetBoard, TargetID: id, BoardID: id}, nil))
+}
+
+func (s *boardService) Reorder(ctx context.Context, ids []int64) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if !uniqueIDs(ids) {
+		return ErrInvalidInput
+	}
+	return s.repo.Reorder(ctx, ids, audit(ctx, entity.ModAction{Action: entity.ModReorderBoards, TargetType: entity.TargetBoard,
+		Details: jsonOf(map[string]any{"order": ids})}, nil))
+}
+
+func (s *boardService) Directory(ctx context.Context) ([]entity.CategoryBoards, error) {
+	categories, err := s.repo.ListCategories(ctx)
+	if err != nil {
+		return nil, err
+	}
+	boards, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repo.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// boards come ordered by position, so children keep that order too
+	children := make(map[int64][]entity.BoardNode)
+	known := make(map[int64]bool, len(boards))
+	for _, b := range boards {
+		known[b.ID] = true
+	}
+	for _, b := range boards {
+		if b.ParentID != 0 && known[b.ParentID] {
+			children[b.ParentID] = append(children[b.ParentID], entity.BoardNode{Board: b, Stats: stats[b.ID]})
+		}
+	}
+	byCategory := make(map[int64][]entity.BoardNode)
+	for _, b := range boards {
+		if b.ParentID != 0 && known[b.ParentID] {
+			continue
+		}
+		node := entity.BoardNode{Board: b, Stats: stats[b.ID], Children: children[b.ID]}
+		byCategory[b.CategoryID] = append(byCategory[b.CategoryID], node)
+	}
+
+	res := make([]entity.CategoryBoards, 0, len(categories)+1)
+	for _, c := range categories {
+		res = append(res, entity.CategoryBoards{BoardCategory: c, Boards: byCategory[c.ID]})
+	}
+	if rest := byCategory[0]; len(rest) > 0 {
+		res = append(res, entity.CategoryBoards{BoardCategory: entity.BoardCategory{Title: "Прочее"}, Boards: rest})
+	}
+	return res, nil
+}
+
+func (s *boardService) Breadcrumbs(ctx context.Context, b *entity.Board) ([]entity.Breadcrumb, error) {
+	crumbs := []entity.Breadcrumb{{Title: "Доски", URL: "/boards"}}
+	if b.CategoryID != 0 {
+		c, err := s.repo.GetCategory(ctx, b.CategoryID)
+		if err != nil {
+			return nil, err
+		}
+		crumbs = append(crumbs, entity.Breadcrumb{Title: c.Title, URL: fmt.Sprintf("/boards#category-%d", c.ID)})
+	}
+	if b.ParentID != 0 {
+		parent, err := s.repo.GetByID(ctx, b.ParentID)
+		if err != nil {
+			return nil, err
+		}
+		crumbs = append(crumbs, entity.Breadcrumb{Title: parent.Title, URL: "/board/" + parent.Slug})
+	}
+	return append(crumbs, entity.Breadcrumb{Title: b.Title, URL: "/board/" + b.Slug}), nil
+}
+
+func (s *boardService) Categories(ctx context.Context) ([]entity.BoardCategory, error) {
+	return s.repo.ListCategories(ctx)
+}
+
+func validateCategory(c *entity.BoardCategory) error {
+	c.Title = strings.TrimSpace(c.Title)
+	if c.Title == "" || utf8.RuneCountInString(c.Title) > maxBoardTitleLength {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+func (s *boardService) CreateCategory(ctx context.Context, c *entity.BoardCategory) (int64, error) {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return 0, err
+	}
+	if err := validateCategory(c); err != nil {
+		return 0, err
+	}
+	return s.repo.CreateCategory(ctx, c, audit(ctx, entity.ModAction{Action: entity.ModCreateCategory, TargetType: entity.TargetCategory}, nil))
+}
+
+func (s *boardService) UpdateCategory(ctx context.Context, c *entity.BoardCategory) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if c.ID == 0 {
+		return ErrInvalidInput
+	}
+	if err := validateCategory(c); err != nil {
+		return err
+	}
+	before, _ := s.repo.GetCategory(ctx, c.ID)
+	return s.repo.UpdateCategory(ctx, c, audit(ctx, entity.ModAction{Action: entity.ModUpdateCategory, TargetType: entity.TargetCategory, TargetID: c.ID}, before))
+}
+
+func (s *boardService) DeleteCategory(ctx context.Context, id int64) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if id == 0 {
+		return ErrInvalidInput
+	}
+	before, _ := s.repo.GetCategory(ctx, id)
+	return s.repo.DeleteCategory(ctx, id, audit(ctx, entity.ModAction{Action: entity.ModDeleteCategory, TargetType: entity.TargetCategory, TargetID: id}, before))
+}
+
+func (s *boardService) ReorderCategories(ctx context.Context, ids []int64) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if !uniqueIDs(ids) {
+		return ErrInvalidInput
+	}
+	return s.repo.ReorderCategories(ctx, ids, audit(ctx, entity.ModAction{Action: entity.ModReorderCategories, TargetType: entity.TargetCategory,
+		Details: jsonOf(map[string]any{"order": ids})}, nil))
+}
+
+// uniqueIDs reports whether ids are non-zero and distinct
+func uniqueIDs(ids []int64) bool {
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if id == 0 || seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
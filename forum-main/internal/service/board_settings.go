@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrMembersOnly is returned when a non member posts in a members only board
+	ErrMembersOnly = errors.New("only members may post in this board")
+	// ErrAccountTooNew is returned when the author's account is younger than
+	// the board's minimum account age
+	ErrAccountTooNew  = errors.New("account is too new to post in this board")
+	ErrImagesDisabled = errors.New("images are not allowed in this board")
+	ErrLinksDisabled  = errors.New("links are not allowed in this board")
+)
+
+// Limits of board settings
+const (
+	maxBoardRulesLength   = 5000
+	maxPostTemplateLength = 5000
+	maxMinAccountAgeDays  = 3650
+	maxPremodMinApproved  = 1000
+)
+
+func validateBoardSettings(st *entity.BoardSettings) error {
+	st.Rules = strings.TrimSpace(st.Rules)
+	st.PostTemplate = strings.TrimSpace(st.PostTemplate)
+	if st.PostPermission == "" {
+		st.PostPermission = entity.PostEveryone
+	}
+	switch st.PostPermission {
+	case entity.PostEveryone, entity.PostMembers, entity.PostModerators:
+	default:
+		return ErrInvalidInput
+	}
+	if len([]rune(st.Rules)) > maxBoardRulesLength || len([]rune(st.PostTemplate)) > maxPostTemplateLength {
+		return ErrInvalidInput
+	}
+	if st.MinAccountAgeDays < 0 || st.MinAccountAgeDays > maxMinAccountAgeDays {
+		return ErrInvalidInput
+	}
+	if st.PremodAccountAgeDays < 0 || st.PremodAccountAgeDays > maxMinAccountAgeDays ||
+		st.PremodMinApproved < 0 || st.PremodMinApproved > maxPremodMinApproved {
+		return ErrInvalidInput
+	}
+	return nil
+}
+
+func (s *boardService) UpdateSettings(ctx context.Context, id int64, st entity.BoardSettings) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrInvalidInput
+	}
+	if err := validateBoardSettings(&st); err != nil {
+		return err
+	}
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdateSettings(ctx, id, st,
+		audit(ctx, entity.ModAction{Action: entity.ModBoardSettings, TargetType: entity.TargetBoard, TargetID: id, BoardID: id}, before.Settings))
+}
+
+// CheckPosting tells whether the user in ctx may start a post in the board.
+// Moderators are exempt from the board settings.
+func (s *boardService) CheckPosting(ctx context.Context, b *entity.Board) error {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return ErrForbidden
+	}
+	if b.Archived {
+		return ErrBoardArchived
+	}
+	if u.HasRole(entity.RoleModerator) {
+		return nil
+	}
+	switch b.Settings.PostPermission {
+	case entity.PostModerators:
+		return ErrForbidden
+	case entity.PostMembers:
+		ok, err := s.repo.IsMember(ctx, b.ID, u.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrMembersOnly
+		}
+	}
+	minAge := time.Duration(b.Settings.MinAccountAgeDays) * 24 * time.Hour
+	if time.Since(u.CreatedAt) < minAge {
+		return ErrAccountTooNew
+	}
+	return nil
+}
+
+// CheckPost applies the board settings and the trust level of the user in
+// ctx to their new post
+func (s *boardService) CheckPost(ctx context.Context, p *entity.Post) error {
+	b, err := s.GetByID(ctx, int64(p.BoardID))
+	if err != nil {
+		return err
+	}
+	if err := s.CheckPosting(ctx, b); err != nil {
+		return err
+	}
+	if entity.UserFromContext(ctx).HasRole(entity.RoleModerator) {
+		return nil
+	}
+	if !b.Settings.AllowImages && len(p.ImageData) > 0 {
+		return ErrImagesDisabled
+	}
+	if s.trust != nil && len(p.ImageData) > 0 {
+		if err := s.trust.Require(ctx, entity.CapImages); err != nil {
+			return err
+		}
+	}
+	if p.LinkURL != "" || spamURLPattern.MatchString(p.Title+"\n"+p.Content) {
+		return s.checkLinks(ctx, b)
+	}
+	return nil
+}
+
+// CheckComment applies the link settings of the board and the trust level
+// of the user in ctx to their new comment
+func (s *boardService) CheckComment(ctx context.Context, boardID int64, content string) error {
+	if !spamURLPattern.MatchString(content) || entity.UserFromContext(ctx).HasRole(entity.RoleModerator) {
+		return nil
+	}
+	b, err := s.GetByID(ctx, boardID)
+	if err != nil {
+		return err
+	}
+	return s.checkLinks(ctx, b)
+}
+
+// checkLinks tells whether the user in ctx may link to other sites in the
+// board, whether from the link of a post or in the text
+func (s *boardService) checkLinks(ctx context.Context, b *entity.Board) error {
+	if !b.Settings.AllowLinks {
+		return ErrLinksDisabled
+	}
+	if s.trust != nil {
+		return s.trust.Require(ctx, entity.CapLinks)
+	}
+	return nil
+}
+
+// HoldReason applies the pre-moderation settings of the board. Moderators
+// are never held.
+func (s *boardService) HoldReason(ctx context.Context, boardID int64) (string, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil || u.HasRole(entity.RoleModerator) {
+		return "", nil
+	}
+	b, err := s.GetByID(ctx, boardID)
+	if err != nil {
+		return "", err
+	}
+	st := b.Settings
+	if days := st.PremodAccountAgeDays; days > 0 && time.Since(u.CreatedAt) < time.Duration(days)*24*time.Hour {
+		return fmt.Sprintf("account is younger than %d days", days), nil
+	}
+	if st.PremodMinApproved > 0 {
+		n, err := s.repo.ApprovedContributions(ctx, u.ID)
+		if err != nil {
+			return "", err
+		}
+		if n < st.PremodMinApproved {
+			return fmt.Sprintf("fewer than %d approved posts and comments", st.PremodMinApproved), nil
+		}
+	}
+	return "", nil
+}
@@ -2,39 +2,428 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"forum1/internal/entity"
 	"forum1/internal/repository"
+	"strings"
 )
 
+var (
+	ErrAlreadyMember = errors.New("already a member of the club")
+	ErrNotMember     = errors.New("not a member of the club")
+	// ErrInviteOnly is returned when joining an invite only club uninvited
+	ErrInviteOnly = errors.New("the club is invite only")
+	// ErrOwnerCannotLeave is returned when the owner leaves their own club
+	ErrOwnerCannotLeave = errors.New("the owner cannot leave the club")
+)
+
+// Limits of club fields
+const (
+	maxClubNameLength        = 100
+	maxClubTopicLength       = 100
+	maxClubDescriptionLength = 1000
+)
+
+// ClubService manages clubs and their members. The acting user is the user
+// in ctx; managing requests, invites and members takes an officer, roles,
+// the join policy and privacy the owner. The boards of a private club are
+// hidden from non members by the repositories.
 type ClubService interface {
+	// Create makes the user in ctx the owner of the new club
 	Create(ctx context.Context, club *entity.Club) (int64, error)
+	// GetByID returns the club with its boards
 	GetByID(ctx context.Context, id int64) (*entity.Club, error)
 	List(ctx context.Context) ([]entity.Club, error)
+	SetJoinPolicy(ctx context.Context, clubID int64, policy string) error
+	SetPrivate(ctx context.Context, clubID int64, private bool) error
+
+	// Role is the role of the user in ctx, "" for guests and non members
+	Role(ctx context.Context, clubID int64) (string, error)
+	// Members lists the members; those of a private club are listed to its
+	// members and site admins only
+	Members(ctx context.Context, clubID int64) ([]entity.ClubMember, error)
+	// Join adds the user to an open club or to a club they are invited to
+	// and returns true, or files a join request and returns false
+	Join(ctx context.Context, clubID int64) (bool, error)
+	// Leave leaves the club, or withdraws a pending request or invitation
+	Leave(ctx context.Context, clubID int64) error
+	Kick(ctx context.Context, clubID, userID int64) error
+	SetMemberRole(ctx context.Context, clubID, userID int64, role string) error
+
+	// Requests are the pending requests and invitations of the club
+	Requests(ctx context.Context, clubID int64) ([]entity.ClubRequest, error)
+	// PendingRequest is the kind of the pending request of the user in ctx
+	PendingRequest(ctx context.Context, clubID int64) (string, error)
+	Approve(ctx context.Context, clubID, userID int64) error
+	Deny(ctx context.Context, clubID, userID int64) error
+	Invite(ctx context.Context, clubID int64, username string) error
+	// Invitations lists the clubs the user in ctx is invited to
+	Invitations(ctx context.Context) ([]entity.ClubRequest, error)
+	// CreateBoard adds a top level board to the club, officers only
+	CreateBoard(ctx context.Context, clubID int64, b *entity.Board) (int64, error)
+}
+
+// ClubServiceOption customizes the club service, see NewClubService
+type ClubServiceOption func(s *clubService)
+
+// WithClubTrust gates creating boards in clubs by trust level
+func WithClubTrust(t TrustService) ClubServiceOption {
+	return func(s *clubService) { s.trust = t }
 }
 
-func NewClubService(repo repository.ClubRepository) ClubService {
-	return &clubService{repo: repo}
+// NewClubService builds the club service, notes may be nil
+func NewClubService(repo repository.ClubRepository, boards repository.BoardRepository, users repository.UserRepository, notes NotificationService, opts ...ClubServiceOption) ClubService {
+	s := &clubService{repo: repo, boards: boards, users: users, notes: notes}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 type clubService struct {
-	repo repository.ClubRepository
+	repo   repository.ClubRepository
+	boards repository.BoardRepository
+	users  repository.UserRepository
+	notes  NotificationService
+	trust  TrustService
+}
+
+func validateClub(c *entity.Club) error {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Topic = strings.TrimSpace(c.Topic)
+	c.Description = strings.TrimSpace(c.Description)
+	if c.Name == "" || len([]rune(c.Name)) > maxClubNameLength {
+		return ErrInvalidInput
+	}
+	if len([]rune(c.Topic)) > maxClubTopicLength || len([]rune(c.Description)) > maxClubDescriptionLength {
+		return ErrInvalidInput
+	}
+	if c.JoinPolicy == "" {
+		c.JoinPolicy = entity.JoinOpen
+	}
+	return validJoinPolicy(c.JoinPolicy)
+}
+
+func validJoinPolicy(policy string) error {
+	switch policy {
+	case entity.JoinOpen, entity.JoinApproval, entity.JoinInvite:
+		return nil
+	}
+	return ErrInvalidInput
+}
+
+// notify tells the user about a club event, failures only cost the notice
+func (s *clubService) notify(ctx context.Context, userID, clubID int64, kind, title string) {
+	if s.notes == nil {
+		return
+	}
+	if err := s.notes.Notify(ctx, userID, kind, title, fmt.Sprintf("/clubs/%d", clubID)); err != nil {
+		fmt.Println("club notification:", err)
+	}
+}
+
+// actor returns the user in ctx and their role in the club
+func (s *clubService) actor(ctx context.Context, clubID int64) (*entity.User, string, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return nil, "", ErrForbidden
+	}
+	if clubID <= 0 {
+		return nil, "", ErrInvalidInput
+	}
+	role, err := s.repo.Role(ctx, clubID, u.ID)
+	return u, role, err
+}
+
+// require returns the user in ctx when they hold role min in the club
+func (s *clubService) require(ctx context.Context, clubID int64, min string) (*entity.User, error) {
+	u, role, err := s.actor(ctx, clubID)
+	if err != nil {
+		return nil, err
+	}
+	if !entity.ClubRoleAtLeast(role, min) {
+		return nil, ErrForbidden
+	}
+	return u, nil
 }
 
 func (s *clubService) Create(ctx context.Context, club *entity.Club) (int64, error) {
-	if club.Name == "" {
-		return 0, errors.New("club name required")
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return 0, ErrForbidden
 	}
-	return s.repo.Create(ctx, club)
+	if err := validateClub(club); err != nil {
+		return 0, err
+	}
+	return s.repo.Create(ctx, club, u.ID)
 }
 
 func (s *clubService) GetByID(ctx context.Context, id int64) (*entity.Club, error) {
 	if id <= 0 {
-		return nil, errors.New("invalid club id")
+		return nil, ErrInvalidInput
+	}
+	c, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	return s.repo.GetByID(ctx, id)
+	if c.Boards, err = s.boards.ListByClub(ctx, id); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
 func (s *clubService) List(ctx context.Context) ([]entity.Club, error) {
 	return s.repo.List(ctx)
 }
+
+func (s *clubService) SetJoinPolicy(ctx context.Context, clubID int64, policy string) error {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOwner); err != nil {
+		return err
+	}
+	if err := validJoinPolicy(policy); err != nil {
+		return err
+	}
+	return s.repo.SetJoinPolicy(ctx, clubID, policy)
+}
+
+func (s *clubService) SetPrivate(ctx context.Context, clubID int64, private bool) error {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOwner); err != nil {
+		return err
+	}
+	return s.repo.SetPrivate(ctx, clubID, private)
+}
+
+func (s *clubService) Role(ctx context.Context, clubID int64) (string, error) {
+	if entity.UserFromContext(ctx) == nil {
+		return "", nil
+	}
+	_, role, err := s.actor(ctx, clubID)
+	return role, err
+}
+
+func (s *clubService) Members(ctx context.Context, clubID int64) ([]entity.ClubMember, error) {
+	if clubID <= 0 {
+		return nil, ErrInvalidInput
+	}
+	club, err := s.repo.GetByID(ctx, clubID)
+	if err != nil {
+		return nil, err
+	}
+	// like their content, the members of a private club are shown to
+	// members and site admins only
+	if u := entity.UserFromContext(ctx); club.Private && !u.HasRole(entity.RoleAdmin) {
+		if u == nil {
+			return nil, ErrForbidden
+		}
+		role, err := s.repo.Role(ctx, clubID, u.ID)
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, ErrForbidden
+		}
+	}
+	return s.repo.Members(ctx, clubID)
+}
+
+func (s *clubService) Join(ctx context.Context, clubID int64) (bool, error) {
+	u, role, err := s.actor(ctx, clubID)
+	if err != nil {
+		return false, err
+	}
+	if role != "" {
+		return false, ErrAlreadyMember
+	}
+	club, err := s.repo.GetByID(ctx, clubID)
+	if err != nil {
+		return false, err
+	}
+	pending, err := s.repo.Request(ctx, clubID, u.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+	if club.JoinPolicy == entity.JoinOpen || pending == entity.ClubRequestInvite {
+		return true, s.repo.AddMember(ctx, clubID, u.ID, entity.ClubRoleMember)
+	}
+	if club.JoinPolicy == entity.JoinInvite {
+		return false, ErrInviteOnly
+	}
+	return false, s.repo.CreateRequest(ctx, clubID, u.ID, entity.ClubRequestJoin)
+}
+
+func (s *clubService) Leave(ctx context.Context, clubID int64) error {
+	u, role, err := s.actor(ctx, clubID)
+	if err != nil {
+		return err
+	}
+	switch role {
+	case entity.ClubRoleOwner:
+		return ErrOwnerCannotLeave
+	case "":
+		if err := s.repo.DeleteRequest(ctx, clubID, u.ID); errors.Is(err, sql.ErrNoRows) {
+			return ErrNotMember
+		} else if err != nil {
+			return err
+		}
+		return nil
+	}
+	return s.repo.RemoveMember(ctx, clubID, u.ID)
+}
+
+func (s *clubService) Kick(ctx context.Context, clubID, userID int64) error {
+	u, role, err := s.actor(ctx, clubID)
+	if err != nil {
+		return err
+	}
+	if userID == u.ID || !entity.ClubRoleAtLeast(role, entity.ClubRoleOfficer) {
+		return ErrForbidden
+	}
+	target, err := s.repo.Role(ctx, clubID, userID)
+	if err != nil {
+		return err
+	}
+	if target == "" {
+		return ErrNotMember
+	}
+	// only members of a lower rank can be kicked
+	if entity.ClubRoleAtLeast(target, role) {
+		return ErrForbidden
+	}
+	return s.repo.RemoveMember(ctx, clubID, userID)
+}
+
+func (s *clubService) SetMemberRole(ctx context.Context, clubID, userID int64, role string) error {
+	u, err := s.require(ctx, clubID, entity.ClubRoleOwner)
+	if err != nil {
+		return err
+	}
+	// ownership stays with the owner, roles of others go between officer and member
+	if userID == u.ID || (role != entity.ClubRoleOfficer && role != entity.ClubRoleMember) {
+		return ErrInvalidInput
+	}
+	if err := s.repo.SetMemberRole(ctx, clubID, userID, role); errors.Is(err, sql.ErrNoRows) {
+		return ErrNotMember
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *clubService) Requests(ctx context.Context, clubID int64) ([]entity.ClubRequest, error) {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOfficer); err != nil {
+		return nil, err
+	}
+	return s.repo.Requests(ctx, clubID)
+}
+
+func (s *clubService) PendingRequest(ctx context.Context, clubID int64) (string, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return "", nil
+	}
+	kind, err := s.repo.Request(ctx, clubID, u.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return kind, err
+}
+
+// joinRequest checks that the user has a pending join request
+func (s *clubService) joinRequest(ctx context.Context, clubID, userID int64) error {
+	kind, err := s.repo.Request(ctx, clubID, userID)
+	if err != nil {
+		return err
+	}
+	if kind != entity.ClubRequestJoin {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *clubService) Approve(ctx context.Context, clubID, userID int64) error {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOfficer); err != nil {
+		return err
+	}
+	if err := s.joinRequest(ctx, clubID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.AddMember(ctx, clubID, userID, entity.ClubRoleMember); err != nil {
+		return err
+	}
+	if club, err := s.repo.GetByID(ctx, clubID); err == nil {
+		s.notify(ctx, userID, clubID, entity.NotificationClubJoined, "Вас приняли в клуб «"+club.Name+"»")
+	}
+	return nil
+}
+
+func (s *clubService) Deny(ctx context.Context, clubID, userID int64) error {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOfficer); err != nil {
+		return err
+	}
+	if err := s.joinRequest(ctx, clubID, userID); err != nil {
+		return err
+	}
+	return s.repo.DeleteRequest(ctx, clubID, userID)
+}
+
+func (s *clubService) Invite(ctx context.Context, clubID int64, username string) error {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOfficer); err != nil {
+		return err
+	}
+	user, err := s.users.GetUserByName(ctx, strings.TrimSpace(username))
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidInput
+	} else if err != nil {
+		return err
+	}
+	role, err := s.repo.Role(ctx, clubID, user.ID)
+	if err != nil {
+		return err
+	}
+	if role != "" {
+		return ErrAlreadyMember
+	}
+	club, err := s.repo.GetByID(ctx, clubID)
+	if err != nil {
+		return err
+	}
+	// inviting someone who asked to join is approving them
+	if s.joinRequest(ctx, clubID, user.ID) == nil {
+		if err := s.repo.AddMember(ctx, clubID, user.ID, entity.ClubRoleMember); err != nil {
+			return err
+		}
+		s.notify(ctx, user.ID, clubID, entity.NotificationClubJoined, "Вас приняли в клуб «"+club.Name+"»")
+		return nil
+	}
+	if err := s.repo.CreateRequest(ctx, clubID, user.ID, entity.ClubRequestInvite); err != nil {
+		return err
+	}
+	s.notify(ctx, user.ID, clubID, entity.NotificationClubInvite, "Приглашение в клуб «"+club.Name+"»")
+	return nil
+}
+
+func (s *clubService) Invitations(ctx context.Context) ([]entity.ClubRequest, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return nil, nil
+	}
+	return s.repo.Invitations(ctx, u.ID)
+}
+
+func (s *clubService) CreateBoard(ctx context.Context, clubID int64, b *entity.Board) (int64, error) {
+	if _, err := s.require(ctx, clubID, entity.ClubRoleOfficer); err != nil {
+		return 0, err
+	}
+	if s.trust != nil {
+		if err := s.trust.Require(ctx, entity.CapClubBoards); err != nil {
+			return 0, err
+		}
+	}
+	if err := validateBoard(b); err != nil {
+		return 0, err
+	}
+	b.ClubID, b.CategoryID, b.ParentID = clubID, 0, 0
+	return s.boards.Create(ctx, b, nil)
+}
@@ -3,30 +3,103 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"forum1/internal/entity"
 	"forum1/internal/repository"
 )
 
 type CommentService interface {
+	// CreateComment fails with ErrBoardArchived or ErrPostLocked when the
+	// thread takes no new comments, and with ErrSanctioned when the author
+	// may not write there. A comment held for pre-moderation is created
+	// with c.Pending set.
 	CreateComment(ctx context.Context, c *entity.Comment) (int64, error)
 	GetCommentsByPost(ctx context.Context, postID int64) ([]entity.Comment, error)
 	GetCommentByID(ctx context.Context, id int64) (*entity.Comment, error)
 	DeleteComment(ctx context.Context, id int64, requesterID int64) error
-	ForceDeleteComment(ctx context.Context, id int64) error
 	SetCommentVote(ctx context.Context, commentID int64, userID int64, value int) error
 	GetCommentVotes(ctx context.Context, commentID int64) (likes int, dislikes int, err error)
 }
 
-func NewCommentService(repo repository.CommentRepository) CommentService {
-	return &commentService{repo: repo}
+// CommentServiceOption customizes the comment service, see NewCommentService
+type CommentServiceOption func(s *commentService)
+
+// WithPremoderation holds new comments by the pre-moderation settings of
+// the board of their post, the author is then the user in ctx
+func WithPremoderation(boards BoardService, posts PostService) CommentServiceOption {
+	return func(s *commentService) { s.boards, s.posts = boards, posts }
+}
+
+// WithCommentSpamFilter holds new comments the spam filter scores as
+// likely spam, the author is then the user in ctx
+func WithCommentSpamFilter(spam SpamService) CommentServiceOption {
+	return func(s *commentService) { s.spam = spam }
+}
+
+// WithCommentContentRules applies the admin content rules to new
+// comments, the author is then the user in ctx
+func WithCommentContentRules(rules ContentRuleService) CommentServiceOption {
+	return func(s *commentService) { s.rules = rules }
+}
+
+// WithCommentTrust gates voting on comments by trust level, the voter is
+// then the user in ctx
+func WithCommentTrust(t TrustService) CommentServiceOption {
+	return func(s *commentService) { s.trust = t }
+}
+
+func NewCommentService(repo repository.CommentRepository, opts ...CommentServiceOption) CommentService {
+	s := &commentService{repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-type commentService struct{ repo repository.CommentRepository }
+type commentService struct {
+	repo   repository.CommentRepository
+	boards BoardService
+	posts  PostService
+	trust  TrustService
+	spam   SpamService
+	rules  ContentRuleService
+}
 
 func (s *commentService) CreateComment(ctx context.Context, c *entity.Comment) (int64, error) {
 	if c.PostID == 0 || c.AuthorID == 0 || c.Content == "" {
 		return 0, errors.New("invalid input")
 	}
+	c.Pending, c.HoldReason = false, ""
+	if s.boards != nil {
+		// a post that isn't visible is left to the repository to refuse
+		if p, err := s.posts.GetPostByID(ctx, c.PostID); err == nil {
+			if err := s.boards.CheckComment(ctx, int64(p.BoardID), c.Content); err != nil {
+				return 0, err
+			}
+			reason, err := s.boards.HoldReason(ctx, int64(p.BoardID))
+			if err != nil {
+				return 0, err
+			}
+			c.Pending, c.HoldReason = reason != "", reason
+		}
+	}
+	if s.rules != nil {
+		reason, err := s.rules.Apply(ctx, nil, &c.Content, "")
+		if err != nil {
+			return 0, err
+		}
+		if !c.Pending && reason != "" {
+			c.Pending, c.HoldReason = true, reason
+		}
+	}
+	if s.spam != nil && !c.Pending {
+		reason, err := s.spam.HoldReason(ctx, entity.SpamDoc{Content: c.Content})
+		if err != nil {
+			// a broken filter must not stop commenting
+			fmt.Println("spam filter:", err)
+		}
+		c.Pending, c.HoldReason = reason != "", reason
+	}
 	return s.repo.CreateComment(ctx, c)
 }
 func (s *commentService) GetCommentsByPost(ctx context.Context, postID int64) ([]entity.Comment, error) {
@@ -48,17 +121,15 @@ func (s *commentService) DeleteComment(ctx context.Context, id int64, requesterI
 	return s.repo.DeleteComment(ctx, id)
 }
 
-func (s *commentService) ForceDeleteComment(ctx context.Context, id int64) error {
-	if id == 0 {
-		return errors.New("id required")
-	}
-	return s.repo.ForceDeleteComment(ctx, id)
-}
-
 func (s *commentService) SetCommentVote(ctx context.Context, commentID int64, userID int64, value int) error {
 	if commentID == 0 || userID == 0 || (value != -1 && value != 1) {
 		return errors.New("invalid input")
 	}
+	if s.trust != nil {
+		if err := s.trust.Require(ctx, entity.CapVote); err != nil {
+			return err
+		}
+	}
 	return s.repo.SetCommentVote(ctx, commentID, userID, value)
 }
 
@@ -0,0 +1,328 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// ErrContentBlocked is returned for posts and comments refused by the
+	// content rules, the error wraps it with what refused them
+	ErrContentBlocked = errors.New("content is not allowed")
+	// ErrRuleExists is returned when a rule of the same kind and pattern exists
+	ErrRuleExists = repository.ErrRuleExists
+)
+
+const (
+	maxRulePatternLength = 200
+	maxRuleNoteLength    = 500
+	// defaultCensorReplacement replaces censored words of rules without one
+	defaultCensorReplacement = "***"
+	// contentRulesTTL is how long the rules are cached, so that changes made
+	// through another instance apply within it
+	contentRulesTTL = time.Minute
+)
+
+// domainPattern is a link domain of a domain rule
+var domainPattern = regexp.MustCompile(`^[\p{L}\p{N}-]+(\.[\p{L}\p{N}-]+)+$`)
+
+// ContentRuleService holds the admin rules applied to posts and comments
+// when they are saved
+type ContentRuleService interface {
+	// List, Create and Delete manage the rules, admins only; the admin is
+	// the user in ctx
+	List(ctx context.Context) ([]entity.ContentRule, error)
+	Create(ctx context.Context, rule *entity.ContentRule) (int64, error)
+	Delete(ctx context.Context, id int64) error
+	// Test applies the rules to sample text and saves nothing, admins only
+	Test(ctx context.Context, title, content, linkURL string) (*entity.ContentRuleResult, error)
+	// Apply runs the rules on a new or edited post or comment of the user in
+	// ctx; title is nil for comments. Title and content are censored in
+	// place, banned words and refused links fail with ErrContentBlocked, and
+	// the hold reason is set when a rule forces moderation. Moderators are
+	// never held.
+	Apply(ctx context.Context, title, content *string, linkURL string) (holdReason string, err error)
+}
+
+func NewContentRuleService(repo repository.ContentRuleRepository) ContentRuleService {
+	return &contentRuleService{repo: repo}
+}
+
+type contentRuleService struct {
+	repo repository.ContentRuleRepository
+
+	mu       sync.Mutex
+	rules    []compiledRule // nil when not loaded
+	loadedAt time.Time
+}
+
+// compiledRule is a rule ready to match, re is nil for domain rules
+type compiledRule struct {
+	entity.ContentRule
+	re *regexp.Regexp
+}
+
+func isDomainRule(kind string) bool {
+	return kind == entity.RuleAllowDomain || kind == entity.RuleDenyDomain
+}
+
+func compileRule(rule entity.ContentRule) (compiledRule, error) {
+	c := compiledRule{ContentRule: rule}
+	if isDomainRule(rule.Kind) {
+		return c, nil
+	}
+	expr := rule.Pattern
+	if !rule.Regex {
+		expr = "(?i)" + regexp.QuoteMeta(rule.Pattern)
+	}
+	var err error
+	c.re, err = regexp.Compile(expr)
+	return c, err
+}
+
+// validateRule normalizes and checks a new rule
+func validateRule(rule *entity.ContentRule) error {
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	rule.Note = strings.TrimSpace(rule.Note)
+	if !slices.Contains(entity.ContentRuleKinds, rule.Kind) || rule.Pattern == "" ||
+		utf8.RuneCountInString(rule.Pattern) > maxRulePatternLength || utf8.RuneCountInString(rule.Note) > maxRuleNoteLength {
+		return ErrInvalidInput
+	}
+	if isDomainRule(rule.Kind) {
+		// accept a pasted address, the rule is its domain
+		d := strings.ToLower(rule.Pattern)
+		d = strings.TrimPrefix(strings.TrimPrefix(d, "http://"), "https://")
+		rule.Pattern = strings.TrimPrefix(strings.TrimRight(d, "/"), "www.")
+		if rule.Regex || !domainPattern.MatchString(rule.Pattern) {
+			return ErrInvalidInput
+		}
+	}
+	if rule.Kind == entity.RuleCensor {
+		if rule.Replacement == "" {
+			rule.Replacement = defaultCensorReplacement
+		}
+	} else {
+		rule.Replacement = ""
+	}
+	if utf8.RuneCountInString(rule.Replacement) > maxRulePatternLength {
+		return ErrInvalidInput
+	}
+	if _, err := compileRule(*rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidInput, err)
+	}
+	return nil
+}
+
+func (s *contentRuleService) List(ctx context.Context) ([]entity.ContentRule, error) {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return nil, err
+	}
+	return s.repo.List(ctx)
+}
+
+func (s *contentRuleService) Create(ctx context.Context, rule *entity.ContentRule) (int64, error) {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return 0, err
+	}
+	if err := validateRule(rule); err != nil {
+		return 0, err
+	}
+	rule.CreatedBy = entity.UserFromContext(ctx).ID
+	id, err := s.repo.Create(ctx, rule, audit(ctx, entity.ModAction{Action: entity.ModCreateContentRule, TargetType: entity.TargetRule,
+		Details: jsonOf(rule)}, nil))
+	if err != nil {
+		return 0, err
+	}
+	s.invalidate()
+	return id, nil
+}
+
+func (s *contentRuleService) Delete(ctx context.Context, id int64) error {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrInvalidInput
+	}
+	before, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id, audit(ctx, entity.ModAction{Action: entity.ModDeleteContentRule, TargetType: entity.TargetRule, TargetID: id}, before)); err != nil {
+		return err
+	}
+	s.invalidate()
+	return nil
+}
+
+func (s *contentRuleService) invalidate() {
+	s.mu.Lock()
+	s.rules = nil
+	s.mu.Unlock()
+}
+
+// compiled returns the cached rules, reloading them when stale. A rule that
+// no longer compiles is left out rather than stopping every post.
+func (s *contentRuleService) compiled(ctx context.Context) ([]compiledRule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rules != nil && time.Since(s.loadedAt) < contentRulesTTL {
+		return s.rules, nil
+	}
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]compiledRule, 0, len(list))
+	for _, rule := range list {
+		c, err := compileRule(rule)
+		if err != nil {
+			fmt.Println("content rule", rule.ID, err)
+			continue
+		}
+		rules = append(rules, c)
+	}
+	s.rules, s.loadedAt = rules, time.Now()
+	return rules, nil
+}
+
+func isWordRune(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }
+
+// matches returns where the rule matches text; word rules only match
+// whole words
+func (c *compiledRule) matches(text string) [][]int {
+	var out [][]int
+	for _, loc := range c.re.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if !c.Regex {
+			before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+			after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+			if isWordRune(before) || isWordRune(after) {
+				continue
+			}
+		}
+		out = append(out, loc)
+	}
+	return out
+}
+
+// censor replaces the matches of the rule in text
+func (c *compiledRule) censor(text string) (string, bool) {
+	locs := c.matches(text)
+	if len(locs) == 0 {
+		return text, false
+	}
+	var b strings.Builder
+	prev := 0
+	for _, loc := range locs {
+		b.WriteString(text[prev:loc[0]])
+		b.WriteString(c.Replacement)
+		prev = loc[1]
+	}
+	b.WriteString(text[prev:])
+	return b.String(), true
+}
+
+// domainListed reports whether a link domain or one of its parents is in
+// the list
+func domainListed(domains []string, list map[string]bool) bool {
+	for _, d := range domains {
+		if list[d] {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate applies the rules: banned words and moderation patterns are
+// looked for in the text as written, then the censor rules rewrite it
+func evaluate(rules []compiledRule, title, content, linkURL string) *entity.ContentRuleResult {
+	res := &entity.ContentRuleResult{Title: title, Content: content}
+	text := title + "\n" + content
+	allow, deny := map[string]bool{}, map[string]bool{}
+	for i := range rules {
+		c := &rules[i]
+		switch c.Kind {
+		case entity.RuleBlock:
+			if len(c.matches(text)) > 0 {
+				res.Blocked = append(res.Blocked, c.ContentRule)
+			}
+		case entity.RuleModerate:
+			if len(c.matches(text)) > 0 {
+				res.Held = append(res.Held, c.ContentRule)
+			}
+		case entity.RuleCensor:
+			var t, ct bool
+			res.Title, t = c.censor(res.Title)
+			res.Content, ct = c.censor(res.Content)
+			res.Censored = res.Censored || t || ct
+		case entity.RuleAllowDomain:
+			allow[c.Pattern] = true
+		case entity.RuleDenyDomain:
+			deny[c.Pattern] = true
+		}
+	}
+	links := spamURLPattern.FindAllString(text, -1)
+	if linkURL != "" {
+		links = append([]string{linkURL}, links...)
+	}
+	for _, link := range links {
+		domains := linkDomains(link)
+		if len(domains) == 0 || slices.Contains(res.DeniedDomains, domains[0]) {
+			continue
+		}
+		if domainListed(domains, deny) || (len(allow) > 0 && !domainListed(domains, allow)) {
+			res.DeniedDomains = append(res.DeniedDomains, domains[0])
+		}
+	}
+	return res
+}
+
+func (s *contentRuleService) Test(ctx context.Context, title, content, linkURL string) (*entity.ContentRuleResult, error) {
+	if err := requireRole(ctx, entity.RoleAdmin); err != nil {
+		return nil, err
+	}
+	rules, err := s.compiled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return evaluate(rules, title, content, linkURL), nil
+}
+
+func (s *contentRuleService) Apply(ctx context.Context, title, content *string, linkURL string) (string, error) {
+	rules, err := s.compiled(ctx)
+	if err != nil {
+		return "", err
+	}
+	var t string
+	if title != nil {
+		t = *title
+	}
+	res := evaluate(rules, t, *content, linkURL)
+	if len(res.Blocked) > 0 {
+		return "", fmt.Errorf("%w: %q is banned", ErrContentBlocked, res.Blocked[0].Pattern)
+	}
+	if len(res.DeniedDomains) > 0 {
+		return "", fmt.Errorf("%w: links to %s are not allowed", ErrContentBlocked, strings.Join(res.DeniedDomains, ", "))
+	}
+	if title != nil {
+		*title = res.Title
+	}
+	*content = res.Content
+	if len(res.Held) == 0 || entity.UserFromContext(ctx).HasRole(entity.RoleModerator) {
+		return "", nil
+	}
+	return fmt.Sprintf("matches the moderation rule %q", res.Held[0].Pattern), nil
+}
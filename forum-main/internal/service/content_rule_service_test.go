@@ -0,0 +1,144 @@
+package service
+
+import (
+	"forum1/internal/entity"
+	"reflect"
+	"testing"
+)
+
+func mustCompileRules(t *testing.T, rules ...entity.ContentRule) []compiledRule {
+	t.Helper()
+	out := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		if err := validateRule(&rule); err != nil {
+			t.Fatalf("rule %+v: %v", rule, err)
+		}
+		c, err := compileRule(rule)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = c
+	}
+	return out
+}
+
+func TestContentRuleCensor(t *testing.T) {
+	tests := []struct {
+		name string
+		rule entity.ContentRule
+		in   string
+		want string
+	}{
+		{"word", entity.ContentRule{Pattern: "darn"}, "darn it, DARN", "*** it, ***"},
+		{"not inside words", entity.ContentRule{Pattern: "ass"}, "a class assignment, ass", "a class assignment, ***"},
+		{"cyrillic word", entity.ContentRule{Pattern: "дурак"}, "Сам ДУРАК, дурачок", "Сам ***, дурачок"},
+		{"cyrillic boundaries", entity.ContentRule{Pattern: "кот"}, "кот, котик, скот, (кот)", "***, котик, скот, (***)"},
+		{"digits are word characters", entity.ContentRule{Pattern: "abc"}, "abc1 1abc abc", "abc1 1abc ***"},
+		{"replacement", entity.ContentRule{Pattern: "heck", Replacement: "h*ck"}, "what the heck", "what the h*ck"},
+		{"phrase", entity.ContentRule{Pattern: "buy now"}, "Buy now! buy nowhere", "***! buy nowhere"},
+		{"regex matches anywhere", entity.ContentRule{Pattern: `\d{3}-\d{4}`, Regex: true}, "call 555-1234x", "call ***x"},
+		{"no match", entity.ContentRule{Pattern: "darn"}, "nothing here", "nothing here"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.rule.Kind = entity.RuleCensor
+			rules := mustCompileRules(t, tt.rule)
+			got, changed := rules[0].censor(tt.in)
+			if got != tt.want || changed != (tt.in != tt.want) {
+				t.Errorf("got %q (changed %v), want %q", got, changed, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentRuleEvaluate(t *testing.T) {
+	rules := mustCompileRules(t,
+		entity.ContentRule{Kind: entity.RuleBlock, Pattern: "казино"},
+		entity.ContentRule{Kind: entity.RuleModerate, Pattern: `t\.me/\w+`, Regex: true},
+		entity.ContentRule{Kind: entity.RuleCensor, Pattern: "блин"},
+		entity.ContentRule{Kind: entity.RuleDenyDomain, Pattern: "https://www.bad.example/"},
+	)
+	tests := []struct {
+		name            string
+		title, content  string
+		link            string
+		blocked, held   []string
+		denied          []string
+		wantTitle, want string
+	}{
+		{name: "clean", title: "Привет", content: "как дела", wantTitle: "Привет", want: "как дела"},
+		{name: "banned word", content: "лучшее КАЗИНО", blocked: []string{"казино"}, want: "лучшее КАЗИНО"},
+		{name: "banned word inside another", content: "казиноград", want: "казиноград"},
+		{name: "banned word in the title", title: "казино", content: "x", blocked: []string{"казино"}, wantTitle: "казино", want: "x"},
+		{name: "held", content: "пишите в t.me/someone", held: []string{`t\.me/\w+`}, want: "пишите в t.me/someone"},
+		{name: "censored", title: "Блин!", content: "ну блин, блинчик", wantTitle: "***!", want: "ну ***, блинчик"},
+		{name: "denied link", link: "https://shop.bad.example/x", denied: []string{"shop.bad.example"}},
+		{name: "denied link in text", content: "see http://bad.example/a and http://bad.example/b",
+			denied: []string{"bad.example"}, want: "see http://bad.example/a and http://bad.example/b"},
+		{name: "other domain", content: "see https://good.example", want: "see https://good.example"},
+	}
+	patterns := func(rules []entity.ContentRule) []string {
+		var out []string
+		for _, r := range rules {
+			out = append(out, r.Pattern)
+		}
+		return out
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := evaluate(rules, tt.title, tt.content, tt.link)
+			if got := patterns(res.Blocked); !reflect.DeepEqual(got, tt.blocked) {
+				t.Errorf("blocked by %q, want %q", got, tt.blocked)
+			}
+			if got := patterns(res.Held); !reflect.DeepEqual(got, tt.held) {
+				t.Errorf("held by %q, want %q", got, tt.held)
+			}
+			if !reflect.DeepEqual(res.DeniedDomains, tt.denied) {
+				t.Errorf("denied %q, want %q", res.DeniedDomains, tt.denied)
+			}
+			if res.Title != tt.wantTitle || res.Content != tt.want {
+				t.Errorf("got %q / %q, want %q / %q", res.Title, res.Content, tt.wantTitle, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentRuleAllowList(t *testing.T) {
+	rules := mustCompileRules(t, entity.ContentRule{Kind: entity.RuleAllowDomain, Pattern: "example.com"})
+	tests := []struct {
+		link   string
+		denied bool
+	}{
+		{"https://example.com/a", false},
+		{"https://docs.example.com/a", false},
+		{"https://www.example.com", false},
+		{"https://example.org", true},
+		{"https://notexample.com", true},
+	}
+	for _, tt := range tests {
+		if res := evaluate(rules, "", "", tt.link); (len(res.DeniedDomains) > 0) != tt.denied {
+			t.Errorf("%s: denied %v, want %v", tt.link, res.DeniedDomains, tt.denied)
+		}
+	}
+}
+
+func TestValidateRule(t *testing.T) {
+	tests := []struct {
+		rule entity.ContentRule
+		ok   bool
+	}{
+		{entity.ContentRule{Kind: entity.RuleBlock, Pattern: " spam "}, true},
+		{entity.ContentRule{Kind: entity.RuleBlock, Pattern: "   "}, false},
+		{entity.ContentRule{Kind: "nope", Pattern: "x"}, false},
+		{entity.ContentRule{Kind: entity.RuleModerate, Pattern: "(", Regex: true}, false},
+		{entity.ContentRule{Kind: entity.RuleDenyDomain, Pattern: "https://www.Example.com/"}, true},
+		{entity.ContentRule{Kind: entity.RuleDenyDomain, Pattern: "localhost"}, false},
+		{entity.ContentRule{Kind: entity.RuleDenyDomain, Pattern: "example.com", Regex: true}, false},
+	}
+	for _, tt := range tests {
+		rule := tt.rule
+		if err := validateRule(&rule); (err == nil) != tt.ok {
+			t.Errorf("%+v: err %v, want ok %v", tt.rule, err, tt.ok)
+		}
+	}
+}
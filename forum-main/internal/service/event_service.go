@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"sort"
+	"strings"
+	"time"
+	// event time zones work without a system zoneinfo database
+	_ "time/tzdata"
+	"unicode/utf8"
+)
+
+// Limits of event fields
+const (
+	maxEventTitleLength       = 200
+	maxEventDescriptionLength = 5000
+	maxEventLocationLength    = 300
+	maxEventDuration          = 30 * 24 * time.Hour
+)
+
+// UpcomingDays is how far ahead the upcoming events view looks
+const UpcomingDays = 60
+
+// EventService manages club and board events. Club officers create club
+// events, board events take the right to post in the board; the author,
+// club officers and moderators may delete an event. Events of boards and
+// clubs the user in ctx can't see are hidden by the repository.
+type EventService interface {
+	Create(ctx context.Context, e *entity.Event) (int64, error)
+	GetByID(ctx context.Context, id int64) (*entity.Event, error)
+	Delete(ctx context.Context, id int64) error
+	// CanManage tells whether the user in ctx may delete the event
+	CanManage(ctx context.Context, e *entity.Event) (bool, error)
+	// Upcoming expands the events matching f into occurrences in [from, to)
+	Upcoming(ctx context.Context, f entity.EventFilter, from, to time.Time) ([]entity.EventOccurrence, error)
+	// Occurrences expands a single event
+	Occurrences(e entity.Event, from, to time.Time) []entity.EventOccurrence
+	// Feed lists the events of a calendar feed: f without Since and Until
+	// keeps past events too, as calendar apps expect
+	Feed(ctx context.Context, f entity.EventFilter) ([]entity.Event, error)
+
+	// RSVP answers going, maybe or no for the user in ctx
+	RSVP(ctx context.Context, eventID int64, status string) error
+	RSVPs(ctx context.Context, eventID int64) ([]entity.EventRSVP, error)
+
+	// CalendarToken returns the feed token of the user in ctx, making one
+	// on first use; ResetCalendarToken replaces it, revoking old feed URLs
+	CalendarToken(ctx context.Context) (string, error)
+	ResetCalendarToken(ctx context.Context) (string, error)
+	// CalendarUser returns the user a feed token belongs to
+	CalendarUser(ctx context.Context, token string) (*entity.User, error)
+}
+
+func NewEventService(repo repository.EventRepository, clubs repository.ClubRepository, boards BoardService, users repository.UserRepository) EventService {
+	return &eventService{repo: repo, clubs: clubs, boards: boards, users: users}
+}
+
+type eventService struct {
+	repo   repository.EventRepository
+	clubs  repository.ClubRepository
+	boards BoardService
+	users  repository.UserRepository
+}
+
+// validateEvent normalizes and checks the fields of e
+func validateEvent(e *entity.Event) error {
+	e.Title = strings.TrimSpace(e.Title)
+	e.Description = strings.TrimSpace(e.Description)
+	e.Location = strings.TrimSpace(e.Location)
+	if e.Title == "" || utf8.RuneCountInString(e.Title) > maxEventTitleLength ||
+		utf8.RuneCountInString(e.Description) > maxEventDescriptionLength ||
+		utf8.RuneCountInString(e.Location) > maxEventLocationLength {
+		return ErrInvalidInput
+	}
+	if (e.ClubID == 0) == (e.BoardID == 0) {
+		return ErrInvalidInput
+	}
+	if e.Timezone == "" {
+		e.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(e.Timezone); err != nil {
+		return ErrInvalidInput
+	}
+	if e.StartsAt.IsZero() || e.EndsAt.Before(e.StartsAt) || e.EndsAt.Sub(e.StartsAt) > maxEventDuration {
+		return ErrInvalidInput
+	}
+	rec, err := parseRRule(e.RRule)
+	if err != nil {
+		return err
+	}
+	e.RRule = rec.String()
+	return nil
+}
+
+func (s *eventService) Create(ctx context.Context, e *entity.Event) (int64, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return 0, ErrForbidden
+	}
+	if err := validateEvent(e); err != nil {
+		return 0, err
+	}
+	if e.ClubID != 0 {
+		role, err := s.clubs.Role(ctx, e.ClubID, u.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !entity.ClubRoleAtLeast(role, entity.ClubRoleOfficer) {
+			return 0, ErrForbidden
+		}
+	} else {
+		b, err := s.boards.GetByID(ctx, e.BoardID)
+		if err != nil {
+			return 0, err
+		}
+		if err := s.boards.CheckPosting(ctx, b); err != nil {
+			return 0, err
+		}
+	}
+	e.AuthorID = u.ID
+	return s.repo.Create(ctx, e)
+}
+
+func (s *eventService) GetByID(ctx context.Context, id int64) (*entity.Event, error) {
+	if id <= 0 {
+		return nil, ErrInvalidInput
+	}
+	e, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	inZone(e)
+	return e, nil
+}
+
+func (s *eventService) CanManage(ctx context.Context, e *entity.Event) (bool, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return false, nil
+	}
+	if u.ID == e.AuthorID || u.HasRole(entity.RoleModerator) {
+		return true, nil
+	}
+	if e.ClubID == 0 {
+		return false, nil
+	}
+	role, err := s.clubs.Role(ctx, e.ClubID, u.ID)
+	return entity.ClubRoleAtLeast(role, entity.ClubRoleOfficer), err
+}
+
+func (s *eventService) Delete(ctx context.Context, id int64) error {
+	e, err := s.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	ok, err := s.CanManage(ctx, e)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrForbidden
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *eventService) Upcoming(ctx context.Context, f entity.EventFilter, from, to time.Time) ([]entity.EventOccurrence, error) {
+	if !to.After(from) {
+		return nil, ErrInvalidInput
+	}
+	f.Since, f.Until = from, to
+	events, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	var out []entity.EventOccurrence
+	for _, e := range events {
+		out = append(out, occurrences(e, from, to)...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, nil
+}
+
+func (s *eventService) Occurrences(e entity.Event, from, to time.Time) []entity.EventOccurrence {
+	return occurrences(e, from, to)
+}
+
+func (s *eventService) Feed(ctx context.Context, f entity.EventFilter) ([]entity.Event, error) {
+	events, err := s.repo.List(ctx, f)
+	for i := range events {
+		inZone(&events[i])
+	}
+	return events, err
+}
+
+func (s *eventService) RSVP(ctx context.Context, eventID int64, status string) error {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return ErrForbidden
+	}
+	switch status {
+	case entity.RSVPGoing, entity.RSVPMaybe, entity.RSVPNo:
+	default:
+		return ErrInvalidInput
+	}
+	if eventID <= 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.SetRSVP(ctx, eventID, u.ID, status)
+}
+
+func (s *eventService) RSVPs(ctx context.Context, eventID int64) ([]entity.EventRSVP, error) {
+	// the event must be visible to the user in ctx
+	if _, err := s.GetByID(ctx, eventID); err != nil {
+		return nil, err
+	}
+	return s.repo.RSVPs(ctx, eventID)
+}
+
+func newCalendarToken() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *eventService) CalendarToken(ctx context.Context) (string, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return "", ErrForbidden
+	}
+	token, err := s.repo.CalendarToken(ctx, u.ID)
+	if err != nil || token != "" {
+		return token, err
+	}
+	return s.ResetCalendarToken(ctx)
+}
+
+func (s *eventService) ResetCalendarToken(ctx context.Context) (string, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return "", ErrForbidden
+	}
+	token, err := newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+	return token, s.repo.SetCalendarToken(ctx, u.ID, token)
+}
+
+func (s *eventService) CalendarUser(ctx context.Context, token string) (*entity.User, error) {
+	if token == "" {
+		return nil, ErrInvalidInput
+	}
+	id, err := s.repo.UserByCalendarToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return s.users.GetUserByID(ctx, id)
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+)
+
+// FeedService builds the front page: posts from all boards except the ones
+// the user chose to hide, hot-ranked by default.
+type FeedService interface {
+	Front(ctx context.Context, userID int64, opts entity.PostListOptions) (*entity.PostPage, error)
+	ExcludedBoards(ctx context.Context, userID int64) ([]int64, error)
+	ExcludeBoard(ctx context.Context, userID, boardID int64) error
+	IncludeBoard(ctx context.Context, userID, boardID int64) error
+}
+
+func NewFeedService(posts PostService, repo repository.FeedRepository) FeedService {
+	return &feedService{posts: posts, repo: repo}
+}
+
+type feedService struct {
+	posts PostService
+	repo  repository.FeedRepository
+}
+
+func (s *feedService) Front(ctx context.Context, userID int64, opts entity.PostListOptions) (*entity.PostPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = entity.SortHot
+	}
+	opts.BoardID = 0
+	if userID != 0 {
+		excluded, err := s.repo.ListExcludedBoards(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		opts.ExcludeBoardIDs = excluded
+	}
+	return s.posts.ListPosts(ctx, opts)
+}
+
+func (s *feedService) ExcludedBoards(ctx context.Context, userID int64) ([]int64, error) {
+	if userID == 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.ListExcludedBoards(ctx, userID)
+}
+
+func (s *feedService) ExcludeBoard(ctx context.Context, userID, boardID int64) error {
+	if userID == 0 || boardID == 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.ExcludeBoard(ctx, userID, boardID)
+}
+
+func (s *feedService) IncludeBoard(ctx context.Context, userID, boardID int64) error {
+	if userID == 0 || boardID == 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.IncludeBoard(ctx, userID, boardID)
+}
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"forum1/utils"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+var ErrInvalidLink = errors.New("invalid link")
+
+const (
+	previewFetchTimeout = 5 * time.Second
+	previewMaxBody      = 512 << 10
+	previewTTL          = 24 * time.Hour
+	previewFailedTTL    = time.Hour
+)
+
+type LinkPreviewService interface {
+	// Get returns the preview for rawURL from cache, fetching it when missing
+	// or stale. A link that could not be unfurled yields a Failed preview.
+	Get(ctx context.Context, rawURL string) (*entity.LinkPreview, error)
+}
+
+// NewLinkPreviewService creates the unfurling service. All fetches go through
+// client; pass nil to use utils.NewSafeHTTPClient, which refuses to connect
+// to private and loopback addresses.
+func NewLinkPreviewService(repo repository.LinkPreviewRepository, client *http.Client) LinkPreviewService {
+	if client == nil {
+		client = utils.NewSafeHTTPClient(previewFetchTimeout)
+	}
+	return &linkPreviewService{repo: repo, client: client}
+}
+
+type linkPreviewService struct {
+	repo   repository.LinkPreviewRepository
+	client *http.Client
+}
+
+// ValidateLinkURL checks that a user supplied link is an absolute http(s) URL.
+func ValidateLinkURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return nil, ErrInvalidLink
+	}
+	return u, nil
+}
+
+func (s *linkPreviewService) Get(ctx context.Context, rawURL string) (*entity.LinkPreview, error) {
+	u, err := ValidateLinkURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	key := u.String()
+	cached, err := s.repo.Get(ctx, key)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if cached != nil {
+		ttl := previewTTL
+		if cached.Failed {
+			ttl = previewFailedTTL
+		}
+		if time.Since(cached.FetchedAt) < ttl {
+			return cached, nil
+		}
+	}
+
+	p := s.fetch(ctx, u)
+	if err := ctx.Err(); err != nil {
+		// the caller gave up, which says nothing about the link: caching the
+		// failure would blank the preview for everyone
+		return nil, err
+	}
+	if err := s.repo.Save(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *linkPreviewService) fetch(ctx context.Context, u *url.URL) *entity.LinkPreview {
+	p := &entity.LinkPreview{URL: u.String(), FetchedAt: time.Now(), Failed: true}
+	ctx, cancel := context.WithTimeout(ctx, previewFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.URL, nil)
+	if err != nil {
+		return p
+	}
+	req.Header.Set("User-Agent", "ForumLinkPreview/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return p
+	}
+	defer resp.Body.Close()
+	ct := resp.Header.Get("Content-Type")
+	if mt, _, _ := mime.ParseMediaType(ct); resp.StatusCode != http.StatusOK ||
+		(mt != "text/html" && mt != "application/xhtml+xml") {
+		return p
+	}
+	body, err := charset.NewReader(io.LimitReader(resp.Body, previewMaxBody), ct)
+	if err != nil {
+		return p
+	}
+	// relative og:image URLs resolve against the final URL after redirects
+	parsePreview(body, resp.Request.URL, p)
+	p.Failed = p.Title == ""
+	return p
+}
+
+// parsePreview fills p from the OpenGraph and Twitter card <meta> tags of an
+// HTML document, falling back to <title> and the description meta tag.
+func parsePreview(r io.Reader, base *url.URL, p *entity.LinkPreview) {
+	meta := map[string]string{}
+	var title strings.Builder
+	inTitle := false
+	z := html.NewTokenizer(r)
+loop:
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			break loop
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				break loop
+			case atom.Title:
+				inTitle = true
+			case atom.Meta:
+				var key, content string
+				for hasAttr {
+					var k, v []byte
+					k, v, hasAttr = z.TagAttr()
+					switch string(k) {
+					case "property", "name":
+						key = strings.ToLower(string(v))
+					case "content":
+						content = string(v)
+					}
+				}
+				if _, seen := meta[key]; key != "" && !seen {
+					meta[key] = strings.TrimSpace(content)
+				}
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				break loop
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(z.Text())
+			}
+		}
+	}
+
+	first := func(keys ...string) string {
+		for _, k := range keys {
+			if v := meta[k]; v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	p.Title = truncate(first("og:title", "twitter:title"), 300)
+	if p.Title == "" {
+		p.Title = truncate(strings.Join(strings.Fields(title.String()), " "), 300)
+	}
+	p.Description = truncate(first("og:description", "twitter:description", "description"), 500)
+	p.SiteName = truncate(first("og:site_name"), 100)
+	if p.SiteName == "" {
+		p.SiteName = base.Hostname()
+	}
+	if img := first("og:image:secure_url", "og:image", "twitter:image", "twitter:image:src"); img != "" {
+		if u, err := base.Parse(img); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+			p.ImageURL = u.String()
+		}
+	}
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"forum1/internal/entity"
+	"forum1/utils"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memPreviewRepo struct {
+	mu    sync.Mutex
+	saved map[string]*entity.LinkPreview
+}
+
+func (r *memPreviewRepo) Get(ctx context.Context, url string) (*entity.LinkPreview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.saved[url]; ok {
+		return p, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *memPreviewRepo) Save(ctx context.Context, p *entity.LinkPreview) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.saved == nil {
+		r.saved = map[string]*entity.LinkPreview{}
+	}
+	r.saved[p.URL] = p
+	return nil
+}
+
+// safeClientFor is the safe client, except that it may dial the test
+// server, which listens on loopback
+func safeClientFor(srv *httptest.Server) *http.Client {
+	c := utils.NewSafeHTTPClient(time.Second)
+	tr := c.Transport.(*http.Transport).Clone()
+	dial := tr.DialContext
+	allowed := srv.Listener.Addr().String()
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == allowed {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		}
+		return dial(ctx, network, addr)
+	}
+	c.Transport = tr
+	return c
+}
+
+func TestLinkPreviewGet(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<html><head><title>Fallback</title>
+			<meta property="og:title" content="Hello">
+			<meta name="description" content="A page">
+			<meta property="og:image" content="/img.png"></head><body></body></html>`))
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"title":"no"}`))
+	})
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><head><!--" + strings.Repeat("x", previewMaxBody) + "--><title>Too far</title></head></html>"))
+	})
+	mux.HandleFunc("/private", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "http://10.0.0.1/", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path      string
+		wantTitle string
+	}{
+		{"/page", "Hello"},
+		{"/json", ""},
+		{"/big", ""},
+		{"/private", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			repo := &memPreviewRepo{}
+			s := NewLinkPreviewService(repo, safeClientFor(srv))
+			p, err := s.Get(context.Background(), srv.URL+tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Title != tt.wantTitle || p.Failed != (tt.wantTitle == "") {
+				t.Errorf("got title %q failed %v, want %q", p.Title, p.Failed, tt.wantTitle)
+			}
+			if _, err := repo.Get(context.Background(), p.URL); err != nil {
+				t.Errorf("preview not cached: %v", err)
+			}
+		})
+	}
+
+	repo := &memPreviewRepo{}
+	p, _ := NewLinkPreviewService(repo, safeClientFor(srv)).Get(context.Background(), srv.URL+"/page")
+	if p.Description != "A page" || p.ImageURL != srv.URL+"/img.png" {
+		t.Errorf("got description %q image %q", p.Description, p.ImageURL)
+	}
+}
+
+func TestLinkPreviewCancelledNotCached(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	repo := &memPreviewRepo{}
+	s := NewLinkPreviewService(repo, safeClientFor(srv))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Get(ctx, srv.URL+"/slow"); err == nil {
+		t.Fatal("expected an error once the caller gave up")
+	}
+	if len(repo.saved) != 0 {
+		t.Errorf("a cancelled fetch was cached: %v", repo.saved)
+	}
+}
+
+func TestValidateLinkURL(t *testing.T) {
+	for _, raw := range []string{"https://example.com/a", " http://example.com "} {
+		if _, err := ValidateLinkURL(raw); err != nil {
+			t.Errorf("%q: %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"", "example.com", "ftp://example.com", "javascript:alert(1)", "https://user:pw@example.com", "http:///x"} {
+		if _, err := ValidateLinkURL(raw); err == nil {
+			t.Errorf("%q: expected an error", raw)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrEmptyRange is returned when a split selects no comments of the post
+var ErrEmptyRange = repository.ErrEmptyRange
+
+// Page sizes of the moderation log and the pre-moderation queue
+const (
+	modLogPageSize = 50
+	heldPageSize   = 50
+)
+
+const maxModReasonLength = 1000
+
+// ModerationService holds the moderator tools for threads and the audit
+// log. All methods but Redirect and BoardLog are moderators only, the
+// moderator is the user in ctx.
+type ModerationService interface {
+	MovePost(ctx context.Context, postID, boardID int64) error
+	MergePosts(ctx context.Context, fromID, intoID int64) error
+	// SplitPost returns the id of the new post
+	SplitPost(ctx context.Context, postID, fromCommentID, toCommentID int64, title string) (int64, error)
+	// Redirect returns the post a merged post now lives in
+	Redirect(ctx context.Context, postID int64) (int64, error)
+	// DeletePost and DeleteComment delete someone's content, keeping a
+	// snapshot of it in the log; the reason is required. Content deleted as
+	// spam teaches the spam filter.
+	DeletePost(ctx context.Context, postID int64, reason string, spam bool) error
+	DeleteComment(ctx context.Context, commentID int64, reason string, spam bool) error
+	// Held returns a page of the posts and comments waiting in
+	// pre-moderation, oldest first; boardID 0 is all boards
+	Held(ctx context.Context, boardID int64, page int) ([]entity.HeldItem, error)
+	// ApprovePost and ApproveComment publish held content, rejecting it is
+	// DeletePost or DeleteComment
+	ApprovePost(ctx context.Context, postID int64) error
+	ApproveComment(ctx context.Context, commentID int64) error
+	// Log returns a page of the matching audit log entries, newest first
+	Log(ctx context.Context, f entity.ModActionFilter, page int) ([]entity.ModAction, error)
+	// BoardLog is the public log of a board that publishes it: who acted,
+	// snapshots, details and sanctioned users are left out
+	BoardLog(ctx context.Context, boardID int64, page int) ([]entity.ModAction, error)
+}
+
+// ModerationServiceOption customizes the moderation service, see
+// NewModerationService
+type ModerationServiceOption func(s *moderationService)
+
+// WithSpamTraining teaches the spam filter the approvals and the deletions
+// as spam as they are made
+func WithSpamTraining(spam SpamService) ModerationServiceOption {
+	return func(s *moderationService) { s.spam = spam }
+}
+
+func NewModerationService(repo repository.ModerationRepository, boards BoardService, opts ...ModerationServiceOption) ModerationService {
+	s := &moderationService{repo: repo, boards: boards}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type moderationService struct {
+	repo   repository.ModerationRepository
+	boards BoardService
+	spam   SpamService
+}
+
+// moderator returns the moderator in ctx
+func moderator(ctx context.Context) (*entity.User, error) {
+	if err := requireRole(ctx, entity.RoleModerator); err != nil {
+		return nil, err
+	}
+	return entity.UserFromContext(ctx), nil
+}
+
+func (s *moderationService) MovePost(ctx context.Context, postID, boardID int64) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if postID <= 0 || boardID <= 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.MovePost(ctx, mod.ID, postID, boardID)
+}
+
+func (s *moderationService) MergePosts(ctx context.Context, fromID, intoID int64) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if fromID <= 0 || intoID <= 0 || fromID == intoID {
+		return ErrInvalidInput
+	}
+	return s.repo.MergePosts(ctx, mod.ID, fromID, intoID)
+}
+
+func (s *moderationService) SplitPost(ctx context.Context, postID, fromCommentID, toCommentID int64, title string) (int64, error) {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return 0, err
+	}
+	title = strings.TrimSpace(title)
+	if postID <= 0 || fromCommentID <= 0 || toCommentID < fromCommentID || title == "" {
+		return 0, ErrInvalidInput
+	}
+	return s.repo.SplitPost(ctx, mod.ID, postID, fromCommentID, toCommentID, title)
+}
+
+func (s *moderationService) Redirect(ctx context.Context, postID int64) (int64, error) {
+	if postID <= 0 {
+		return 0, ErrInvalidInput
+	}
+	return s.repo.Redirect(ctx, postID)
+}
+
+// modReason checks the reason of a moderator action
+func modReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" || utf8.RuneCountInString(reason) > maxModReasonLength {
+		return "", ErrInvalidInput
+	}
+	return reason, nil
+}
+
+// learnSpam passes a new decision on to the spam filter; a failure is
+// caught up on with the next decision
+func (s *moderationService) learnSpam(ctx context.Context) {
+	if s.spam == nil {
+		return
+	}
+	if _, err := s.spam.Learn(ctx); err != nil {
+		fmt.Println("spam filter:", err)
+	}
+}
+
+// spamDetails marks a deletion as spam in the log
+func spamDetails(spam bool) json.RawMessage {
+	if !spam {
+		return nil
+	}
+	return json.RawMessage(`{"spam":true}`)
+}
+
+func (s *moderationService) DeletePost(ctx context.Context, postID int64, reason string, spam bool) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if reason, err = modReason(reason); err != nil || postID <= 0 {
+		return ErrInvalidInput
+	}
+	if err := s.repo.DeletePost(ctx, &entity.ModAction{ModeratorID: mod.ID, Action: entity.ModDeletePost,
+		TargetType: entity.TargetPost, TargetID: postID, Reason: reason, Details: spamDetails(spam)}); err != nil {
+		return err
+	}
+	if spam {
+		s.learnSpam(ctx)
+	}
+	return nil
+}
+
+func (s *moderationService) DeleteComment(ctx context.Context, commentID int64, reason string, spam bool) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if reason, err = modReason(reason); err != nil || commentID <= 0 {
+		return ErrInvalidInput
+	}
+	if err := s.repo.DeleteComment(ctx, &entity.ModAction{ModeratorID: mod.ID, Action: entity.ModDeleteComment,
+		TargetType: entity.TargetComment, TargetID: commentID, Reason: reason, Details: spamDetails(spam)}); err != nil {
+		return err
+	}
+	if spam {
+		s.learnSpam(ctx)
+	}
+	return nil
+}
+
+func (s *moderationService) Held(ctx context.Context, boardID int64, page int) ([]entity.HeldItem, error) {
+	if _, err := moderator(ctx); err != nil {
+		return nil, err
+	}
+	if boardID < 0 {
+		return nil, ErrInvalidInput
+	}
+	page = max(page, 1)
+	return s.repo.ListHeld(ctx, boardID, heldPageSize, (page-1)*heldPageSize)
+}
+
+func (s *moderationService) ApprovePost(ctx context.Context, postID int64) error {
+	return s.approve(ctx, entity.ModApprovePost, entity.TargetPost, postID)
+}
+
+func (s *moderationService) ApproveComment(ctx context.Context, commentID int64) error {
+	return s.approve(ctx, entity.ModApproveComment, entity.TargetComment, commentID)
+}
+
+func (s *moderationService) approve(ctx context.Context, action, targetType string, id int64) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrInvalidInput
+	}
+	if err := s.repo.Approve(ctx, &entity.ModAction{ModeratorID: mod.ID, Action: action, TargetType: targetType, TargetID: id}); err != nil {
+		return err
+	}
+	s.learnSpam(ctx)
+	return nil
+}
+
+// modLogTargets are the target types the log can be filtered by
+var modLogTargets = []string{entity.TargetPost, entity.TargetComment, entity.TargetUser, entity.TargetBoard, entity.TargetCategory, entity.TargetRule}
+
+func (s *moderationService) Log(ctx context.Context, f entity.ModActionFilter, page int) ([]entity.ModAction, error) {
+	if _, err := moderator(ctx); err != nil {
+		return nil, err
+	}
+	if f.TargetType != "" && !slices.Contains(modLogTargets, f.TargetType) {
+		return nil, ErrInvalidInput
+	}
+	page = max(page, 1)
+	f.Limit, f.Offset = modLogPageSize, (page-1)*modLogPageSize
+	return s.repo.ListActions(ctx, f)
+}
+
+func (s *moderationService) BoardLog(ctx context.Context, boardID int64, page int) ([]entity.ModAction, error) {
+	b, err := s.boards.GetByID(ctx, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if !b.Settings.PublicModLog {
+		return nil, ErrForbidden
+	}
+	page = max(page, 1)
+	actions, err := s.repo.ListActions(ctx, entity.ModActionFilter{BoardID: b.ID, Limit: modLogPageSize, Offset: (page - 1) * modLogPageSize})
+	if err != nil {
+		return nil, err
+	}
+	for i := range actions {
+		a := &actions[i]
+		a.ModeratorID, a.ModeratorName, a.Snapshot, a.Details = 0, "", nil, nil
+		if a.TargetType == entity.TargetUser {
+			a.TargetID = 0
+		}
+	}
+	return actions, nil
+}
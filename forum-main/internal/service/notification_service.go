@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	notificationPageSize = 50
+	maxNotificationTitle = 300
+)
+
+// NotificationService delivers notifications to users; the notifications
+// page and the unread counter read them back.
+type NotificationService interface {
+	Notify(ctx context.Context, userID int64, kind, title, url string) error
+	List(ctx context.Context, userID int64) ([]entity.Notification, error)
+	UnreadCount(ctx context.Context, userID int64) (int, error)
+	MarkAllRead(ctx context.Context, userID int64) error
+}
+
+func NewNotificationService(repo repository.NotificationRepository) NotificationService {
+	return &notificationService{repo: repo}
+}
+
+type notificationService struct {
+	repo repository.NotificationRepository
+}
+
+func (s *notificationService) Notify(ctx context.Context, userID int64, kind, title, url string) error {
+	title = strings.TrimSpace(title)
+	if userID == 0 || kind == "" || title == "" {
+		return ErrInvalidInput
+	}
+	if utf8.RuneCountInString(title) > maxNotificationTitle {
+		title = string([]rune(title)[:maxNotificationTitle-1]) + "…"
+	}
+	_, err := s.repo.Create(ctx, &entity.Notification{UserID: userID, Kind: kind, Title: title, URL: url})
+	return err
+}
+
+func (s *notificationService) List(ctx context.Context, userID int64) ([]entity.Notification, error) {
+	if userID == 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.ListByUser(ctx, userID, notificationPageSize)
+}
+
+func (s *notificationService) UnreadCount(ctx context.Context, userID int64) (int, error) {
+	if userID == 0 {
+		return 0, ErrInvalidInput
+	}
+	return s.repo.CountUnread(ctx, userID)
+}
+
+func (s *notificationService) MarkAllRead(ctx context.Context, userID int64) error {
+	if userID == 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.MarkAllRead(ctx, userID)
+}
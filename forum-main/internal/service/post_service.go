@@ -2,27 +2,103 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
 	"forum1/internal/entity"
 	"forum1/internal/repository"
+	"forum1/utils"
+	"net/http"
 )
 
-var ErrInvalidInput = errors.New("invalid input")
+var (
+	ErrInvalidInput = errors.New("invalid input")
+	ErrNoImage      = errors.New("post has no image")
+	// ErrPostLocked is returned for comments and votes on a locked post
+	ErrPostLocked = repository.ErrPostLocked
+)
+
+// MaxImagePixels caps width*height of uploaded images so that small files
+// which decompress into huge bitmaps are rejected before decoding.
+var MaxImagePixels = 40_000_000
+
+// Page sizes of post listings
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ImageWidths are the widths of the resized copies served for post images;
+// a requested width is rounded up to the nearest of them.
+var ImageWidths = []int{160, 320, 640, 1280}
 
 type PostService interface {
 	GetAllPosts(ctx context.Context) ([]entity.Post, error)
 	GetPostByID(ctx context.Context, id int64) (*entity.Post, error)
 	CreatePost(ctx context.Context, post *entity.Post) (int64, error)
+	// UpdatePost edits a post of the user in ctx, ErrForbidden for anyone
+	// else. The edit goes through the checks of a new post.
 	UpdatePost(ctx context.Context, post *entity.Post) error
 	DeletePost(ctx context.Context, id int64) error
 	GetPostsByBoard(ctx context.Context, boardID int64) ([]entity.Post, error)
+	ListPosts(ctx context.Context, opts entity.PostListOptions) (*entity.PostPage, error)
 	SetPostVote(ctx context.Context, postID int64, userID int64, value int) error
 	GetPostVotes(ctx context.Context, postID int64) (likes int, dislikes int, err error)
+	GetPostImage(ctx context.Context, postID int64, width int) (*entity.ImageVariant, error)
+
+	// Moderators only, the moderator is the user in ctx
+	SetPinned(ctx context.Context, id int64, pinned bool) error
+	SetLocked(ctx context.Context, id int64, locked bool) error
+}
+
+type postService struct {
+	repo   repository.PostRepository
+	hot    entity.HotConfig
+	boards BoardService
+	trust  TrustService
+	spam   SpamService
+	rules  ContentRuleService
 }
 
-type postService struct{ repo repository.PostRepository }
+// PostServiceOption customizes the post service, see NewPostService
+type PostServiceOption func(s *postService)
+
+// WithHotConfig sets the ranking used for entity.SortHot listings
+func WithHotConfig(cfg entity.HotConfig) PostServiceOption {
+	return func(s *postService) { s.hot = cfg }
+}
 
-func NewPostService(repo repository.PostRepository) PostService { return &postService{repo: repo} }
+// WithBoardSettings enforces the posting settings of boards on new posts
+// and holds them for pre-moderation, the author is then the user in ctx
+func WithBoardSettings(boards BoardService) PostServiceOption {
+	return func(s *postService) { s.boards = boards }
+}
+
+// WithPostSpamFilter holds new posts the spam filter scores as likely
+// spam, the author is then the user in ctx
+func WithPostSpamFilter(spam SpamService) PostServiceOption {
+	return func(s *postService) { s.spam = spam }
+}
+
+// WithPostContentRules applies the admin content rules to new and edited
+// posts, the author is then the user in ctx
+func WithPostContentRules(rules ContentRuleService) PostServiceOption {
+	return func(s *postService) { s.rules = rules }
+}
+
+// WithPostTrust gates voting on posts by trust level, the voter is then
+// the user in ctx
+func WithPostTrust(t TrustService) PostServiceOption {
+	return func(s *postService) { s.trust = t }
+}
+
+func NewPostService(repo repository.PostRepository, opts ...PostServiceOption) PostService {
+	s := &postService{repo: repo, hot: entity.DefaultHotConfig()}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
 
 func (s *postService) GetAllPosts(ctx context.Context) ([]entity.Post, error) {
 	return s.repo.GetAllPosts(ctx)
@@ -36,19 +112,91 @@ func (s *postService) GetPostByID(ctx context.Context, id int64) (*entity.Post,
 }
 
 func (s *postService) CreatePost(ctx context.Context, post *entity.Post) (int64, error) {
-	if post.Title == "" || post.Content == "" || post.AuthorID == 0 || post.BoardID == 0 {
+	if post.AuthorID == 0 {
 		return 0, ErrInvalidInput
 	}
+	if err := s.vet(ctx, post); err != nil {
+		return 0, err
+	}
 	return s.repo.CreatePost(ctx, post)
 }
 
 func (s *postService) UpdatePost(ctx context.Context, post *entity.Post) error {
-	if post.ID == 0 {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return ErrForbidden
+	}
+	if post.ID <= 0 {
 		return ErrInvalidInput
 	}
+	old, err := s.repo.GetPostByID(ctx, int64(post.ID))
+	if err != nil {
+		return err
+	}
+	if int64(old.AuthorID) != u.ID {
+		return ErrForbidden
+	}
+	post.AuthorID = old.AuthorID
+	// an edit only ever puts a post back in the queue, never out of it
+	if err := s.vet(ctx, post); err != nil {
+		return err
+	}
 	return s.repo.UpdatePost(ctx, post)
 }
 
+// vet checks a new or edited post against its board and the content rules,
+// normalizes its link and image and decides whether it is held for
+// pre-moderation
+func (s *postService) vet(ctx context.Context, post *entity.Post) error {
+	if post.Title == "" || post.Content == "" || post.BoardID == 0 {
+		return ErrInvalidInput
+	}
+	post.Pending, post.HoldReason = false, ""
+	if post.LinkURL != "" {
+		u, err := ValidateLinkURL(post.LinkURL)
+		if err != nil {
+			return err
+		}
+		post.LinkURL = u.String()
+	}
+	if s.boards != nil {
+		if err := s.boards.CheckPost(ctx, post); err != nil {
+			return err
+		}
+		reason, err := s.boards.HoldReason(ctx, int64(post.BoardID))
+		if err != nil {
+			return err
+		}
+		post.Pending, post.HoldReason = reason != "", reason
+	}
+	if s.rules != nil {
+		reason, err := s.rules.Apply(ctx, &post.Title, &post.Content, post.LinkURL)
+		if err != nil {
+			return err
+		}
+		if !post.Pending && reason != "" {
+			post.Pending, post.HoldReason = true, reason
+		}
+	}
+	if s.spam != nil && !post.Pending {
+		reason, err := s.spam.HoldReason(ctx, entity.SpamDoc{Title: post.Title, Content: post.Content, LinkURL: post.LinkURL})
+		if err != nil {
+			// a broken filter must not stop posting
+			fmt.Println("spam filter:", err)
+		}
+		post.Pending, post.HoldReason = reason != "", reason
+	}
+	if len(post.ImageData) > 0 {
+		// re-encode uploads so that EXIF metadata is never stored
+		data, w, h, err := utils.SanitizeImage(post.ImageData, MaxImagePixels)
+		if err != nil {
+			return err
+		}
+		post.ImageData, post.ImageWidth, post.ImageHeight = data, w, h
+	}
+	return nil
+}
+
 func (s *postService) DeletePost(ctx context.Context, id int64) error {
 	if id == 0 {
 		return ErrInvalidInput
@@ -63,16 +211,136 @@ func (s *postService) GetPostsByBoard(ctx context.Context, boardID int64) ([]ent
 	return s.repo.GetPostsByBoard(ctx, boardID)
 }
 
+// ListPosts returns a page of posts. Empty Sort means newest first, Period
+// only applies to SortTop and defaults to all time.
+func (s *postService) ListPosts(ctx context.Context, opts entity.PostListOptions) (*entity.PostPage, error) {
+	if opts.Sort == "" {
+		opts.Sort = entity.SortNew
+	}
+	if opts.Period == "" {
+		opts.Period = entity.PeriodAll
+	}
+	switch opts.Sort {
+	case entity.SortNew, entity.SortOld, entity.SortTop, entity.SortComments, entity.SortActive, entity.SortHot:
+	default:
+		return nil, ErrInvalidInput
+	}
+	switch opts.Period {
+	case entity.PeriodDay, entity.PeriodWeek, entity.PeriodMonth, entity.PeriodAll:
+	default:
+		return nil, ErrInvalidInput
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Limit > MaxPageSize {
+		opts.Limit = MaxPageSize
+	}
+	opts.Hot = s.hot
+	page, err := s.repo.ListPosts(ctx, opts)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		return nil, ErrInvalidInput
+	}
+	return page, err
+}
+
 func (s *postService) SetPostVote(ctx context.Context, postID int64, userID int64, value int) error {
 	if postID == 0 || userID == 0 || (value != -1 && value != 1) {
 		return ErrInvalidInput
 	}
+	if s.trust != nil {
+		if err := s.trust.Require(ctx, entity.CapVote); err != nil {
+			return err
+		}
+	}
 	return s.repo.SetPostVote(ctx, postID, userID, value)
 }
 
+func (s *postService) SetPinned(ctx context.Context, id int64, pinned bool) error {
+	if err := requireRole(ctx, entity.RoleModerator); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrInvalidInput
+	}
+	action := entity.ModPinPost
+	if !pinned {
+		action = entity.ModUnpinPost
+	}
+	return s.repo.SetPinned(ctx, id, pinned, audit(ctx, entity.ModAction{Action: action, TargetType: entity.TargetPost, PostID: id, TargetID: id}, nil))
+}
+
+func (s *postService) SetLocked(ctx context.Context, id int64, locked bool) error {
+	if err := requireRole(ctx, entity.RoleModerator); err != nil {
+		return err
+	}
+	if id <= 0 {
+		return ErrInvalidInput
+	}
+	action := entity.ModLockPost
+	if !locked {
+		action = entity.ModUnlockPost
+	}
+	return s.repo.SetLocked(ctx, id, locked, audit(ctx, entity.ModAction{Action: action, TargetType: entity.TargetPost, PostID: id, TargetID: id}, nil))
+}
+
 func (s *postService) GetPostVotes(ctx context.Context, postID int64) (likes int, dislikes int, err error) {
 	if postID == 0 {
 		return 0, 0, ErrInvalidInput
 	}
 	return s.repo.GetPostVotes(ctx, postID)
 }
+
+// GetPostImage returns the post image resized to fit width (0 means the
+// original). Resized copies are generated on first request and cached.
+func (s *postService) GetPostImage(ctx context.Context, postID int64, width int) (*entity.ImageVariant, error) {
+	if postID <= 0 || width < 0 {
+		return nil, ErrInvalidInput
+	}
+	width = variantWidth(width)
+	if width > 0 {
+		v, err := s.repo.GetImageVariant(ctx, postID, width)
+		if err == nil {
+			return v, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	orig, err := s.repo.GetPostImage(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	if len(orig.Data) == 0 {
+		return nil, ErrNoImage
+	}
+	orig.ContentType = http.DetectContentType(orig.Data)
+	if width == 0 {
+		return orig, nil
+	}
+	// images we cannot decode or that are already narrow enough are served as is
+	if w, err := utils.ImageWidth(orig.Data); err != nil || w <= width {
+		return orig, nil
+	}
+	data, contentType, err := utils.ResizeImage(orig.Data, width)
+	if err != nil {
+		return orig, nil
+	}
+	v := &entity.ImageVariant{PostID: postID, Width: width, ContentType: contentType, Data: data, Hash: orig.Hash, ModTime: orig.ModTime}
+	if err := s.repo.SaveImageVariant(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// variantWidth rounds width up to one of ImageWidths; widths above the
+// largest variant map to the original image.
+func variantWidth(width int) int {
+	for _, w := range ImageWidths {
+		if width > 0 && width <= w {
+			return w
+		}
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+package service
+
+import (
+	"forum1/internal/entity"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// recurrence is the supported subset of iCalendar RRULE: FREQ of DAILY,
+// WEEKLY, MONTHLY or YEARLY with INTERVAL, COUNT or UNTIL, and BYDAY for
+// weekly rules.
+type recurrence struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	byDay    []time.Weekday
+}
+
+var rruleDays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+const (
+	// maxOccurrenceSteps bounds the expansion of a rule from where it is
+	// wanted, see firstStep
+	maxOccurrenceSteps = 5000
+	maxRRuleCount      = 1000
+)
+
+// parseRRule parses a rule, "" is no recurrence
+func parseRRule(s string) (*recurrence, error) {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+	if s == "" {
+		return nil, nil
+	}
+	rec := &recurrence{interval: 1}
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, ErrInvalidInput
+		}
+		switch key {
+		case "FREQ":
+			switch value {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+				rec.freq = value
+			default:
+				return nil, ErrInvalidInput
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 365 {
+				return nil, ErrInvalidInput
+			}
+			rec.interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRRuleCount {
+				return nil, ErrInvalidInput
+			}
+			rec.count = n
+		case "UNTIL":
+			t, err := time.Parse("20060102T150405Z", value)
+			if err != nil {
+				// a date until includes that whole day
+				d, derr := time.Parse("20060102", value)
+				if derr != nil {
+					return nil, ErrInvalidInput
+				}
+				t = d.Add(24*time.Hour - time.Second)
+			}
+			rec.until = t
+		case "BYDAY":
+			for _, d := range strings.Split(value, ",") {
+				wd, ok := rruleDays[d]
+				if !ok {
+					return nil, ErrInvalidInput
+				}
+				rec.byDay = append(rec.byDay, wd)
+			}
+		default:
+			return nil, ErrInvalidInput
+		}
+	}
+	if rec.freq == "" || (rec.count > 0 && !rec.until.IsZero()) || (len(rec.byDay) > 0 && rec.freq != "WEEKLY") {
+		return nil, ErrInvalidInput
+	}
+	// week days in week order starting on Monday, like the expansion
+	sort.Slice(rec.byDay, func(i, j int) bool { return weekdayIndex(rec.byDay[i]) < weekdayIndex(rec.byDay[j]) })
+	return rec, nil
+}
+
+// weekdayIndex numbers week days from Monday
+func weekdayIndex(d time.Weekday) int { return (int(d) + 6) % 7 }
+
+// String is the normalized rule, UNTIL in UTC as RFC 5545 requires for
+// events with a time zone
+func (rec *recurrence) String() string {
+	if rec == nil {
+		return ""
+	}
+	parts := []string{"FREQ=" + rec.freq}
+	if rec.interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(rec.interval))
+	}
+	if rec.count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(rec.count))
+	}
+	if !rec.until.IsZero() {
+		parts = append(parts, "UNTIL="+rec.until.UTC().Format("20060102T150405Z"))
+	}
+	if len(rec.byDay) > 0 {
+		days := make([]string, len(rec.byDay))
+		for i, d := range rec.byDay {
+			days[i] = strings.ToUpper(d.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// firstStep is the step of the expansion to start from for occurrences
+// starting at notBefore or later. Rules with a COUNT are expanded from the
+// first occurrence to count them; others jump close to notBefore, a step
+// early so that DST shifts and short months can't skip one.
+func (rec *recurrence) firstStep(first, notBefore time.Time) int {
+	if rec.count > 0 || !notBefore.After(first) {
+		return 0
+	}
+	var units int
+	switch rec.freq {
+	case "DAILY":
+		units = int(notBefore.Sub(first).Hours() / 24)
+	case "WEEKLY":
+		units = int(notBefore.Sub(first).Hours() / 24 / 7)
+	case "MONTHLY":
+		units = (notBefore.Year()-first.Year())*12 + int(notBefore.Month()) - int(first.Month())
+	case "YEARLY":
+		units = notBefore.Year() - first.Year()
+	}
+	return max(units/rec.interval-1, 0)
+}
+
+// starts calls fn with the start of every occurrence, in order, from the
+// first one starting at notBefore or later, until fn returns false or the
+// rule ends
+func (rec *recurrence) starts(first, notBefore time.Time, fn func(time.Time) bool) {
+	n := 0
+	emit := func(t time.Time) bool {
+		if t.Before(first) {
+			return true
+		}
+		if !rec.until.IsZero() && t.After(rec.until) {
+			return false
+		}
+		n++
+		if rec.count > 0 && n > rec.count {
+			return false
+		}
+		if t.Before(notBefore) {
+			return true
+		}
+		return fn(t)
+	}
+	// Monday of the first week, same wall clock
+	weekStart := first.AddDate(0, 0, -weekdayIndex(first.Weekday()))
+	from := rec.firstStep(first, notBefore)
+	for step := from; step < from+maxOccurrenceSteps; step++ {
+		k := step * rec.interval
+		switch rec.freq {
+		case "DAILY":
+			if !emit(first.AddDate(0, 0, k)) {
+				return
+			}
+		case "WEEKLY":
+			if len(rec.byDay) == 0 {
+				if !emit(first.AddDate(0, 0, 7*k)) {
+					return
+				}
+				continue
+			}
+			week := weekStart.AddDate(0, 0, 7*k)
+			for _, d := range rec.byDay {
+				if !emit(week.AddDate(0, 0, weekdayIndex(d))) {
+					return
+				}
+			}
+		case "MONTHLY", "YEARLY":
+			months := k
+			if rec.freq == "YEARLY" {
+				months = 12 * k
+			}
+			t := first.AddDate(0, months, 0)
+			// months without the day (the 31st, February 29th) are skipped
+			if t.Day() != first.Day() {
+				continue
+			}
+			if !emit(t) {
+				return
+			}
+		}
+	}
+}
+
+// inZone moves the times of e to its time zone
+func inZone(e *entity.Event) {
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	e.StartsAt, e.EndsAt = e.StartsAt.In(loc), e.EndsAt.In(loc)
+}
+
+// occurrences expands e into its occurrences overlapping [from, to), in
+// the event's time zone
+func occurrences(e entity.Event, from, to time.Time) []entity.EventOccurrence {
+	inZone(&e)
+	duration := e.EndsAt.Sub(e.StartsAt)
+	rec, err := parseRRule(e.RRule)
+	if err != nil || rec == nil {
+		if e.EndsAt.After(from) && e.StartsAt.Before(to) {
+			return []entity.EventOccurrence{{Event: e, Start: e.StartsAt, End: e.EndsAt}}
+		}
+		return nil
+	}
+	var out []entity.EventOccurrence
+	rec.starts(e.StartsAt, from.Add(-duration), func(start time.Time) bool {
+		if !start.Before(to) {
+			return false
+		}
+		if end := start.Add(duration); end.After(from) {
+			out = append(out, entity.EventOccurrence{Event: e, Start: start, End: end})
+		}
+		return true
+	})
+	return out
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"forum1/internal/entity"
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		in, want string // want "" with ok: no recurrence
+		ok       bool
+	}{
+		{"", "", true},
+		{"FREQ=DAILY", "FREQ=DAILY", true},
+		{"rrule:freq=weekly;interval=1", "FREQ=WEEKLY", true},
+		{"FREQ=WEEKLY;BYDAY=FR,MO,WE", "FREQ=WEEKLY;BYDAY=MO,WE,FR", true},
+		{"FREQ=MONTHLY;INTERVAL=2;COUNT=10", "FREQ=MONTHLY;INTERVAL=2;COUNT=10", true},
+		{"FREQ=YEARLY;UNTIL=20300101T000000Z", "FREQ=YEARLY;UNTIL=20300101T000000Z", true},
+		{"FREQ=DAILY;UNTIL=20300101", "FREQ=DAILY;UNTIL=20300101T235959Z", true},
+		{"FREQ=HOURLY", "", false},
+		{"FREQ=DAILY;COUNT=2;UNTIL=20300101", "", false},
+		{"FREQ=MONTHLY;BYDAY=MO", "", false},
+		{"FREQ=WEEKLY;BYDAY=XX", "", false},
+		{"FREQ=DAILY;INTERVAL=0", "", false},
+		{"FREQ=DAILY;COUNT=1001", "", false},
+		{"FREQ=DAILY;BYMONTH=1", "", false},
+		{"INTERVAL=2", "", false},
+		{"FREQ", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			rec, err := parseRRule(tt.in)
+			if (err == nil) != tt.ok {
+				t.Fatalf("err %v, want ok %v", err, tt.ok)
+			}
+			if got := rec.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	at := func(s string) time.Time {
+		d, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			panic(err)
+		}
+		return d
+	}
+	dates := func(occ []entity.EventOccurrence) []string {
+		out := make([]string, len(occ))
+		for i, o := range occ {
+			out[i] = o.Start.Format("2006-01-02 15:04")
+		}
+		return out
+	}
+	tests := []struct {
+		name     string
+		start    string
+		rrule    string
+		from, to string
+		want     []string
+	}{
+		{"single", "2026-03-02 10:00", "", "2026-03-01 00:00", "2026-04-01 00:00",
+			[]string{"2026-03-02 10:00"}},
+		{"single outside", "2026-03-02 10:00", "", "2026-04-01 00:00", "2026-05-01 00:00", nil},
+		{"daily count", "2026-03-02 10:00", "FREQ=DAILY;COUNT=3", "2026-03-01 00:00", "2026-04-01 00:00",
+			[]string{"2026-03-02 10:00", "2026-03-03 10:00", "2026-03-04 10:00"}},
+		{"count before the window", "2026-03-02 10:00", "FREQ=DAILY;COUNT=3", "2026-03-04 00:00", "2026-04-01 00:00",
+			[]string{"2026-03-04 10:00"}},
+		{"until", "2026-03-02 10:00", "FREQ=DAILY;UNTIL=20260303", "2026-03-01 00:00", "2026-04-01 00:00",
+			[]string{"2026-03-02 10:00", "2026-03-03 10:00"}},
+		{"weekly by day", "2026-03-04 18:00", "FREQ=WEEKLY;BYDAY=MO,WE", "2026-03-01 00:00", "2026-03-15 00:00",
+			[]string{"2026-03-04 18:00", "2026-03-09 18:00", "2026-03-11 18:00"}},
+		{"every other week", "2026-03-02 10:00", "FREQ=WEEKLY;INTERVAL=2", "2026-03-01 00:00", "2026-04-01 00:00",
+			[]string{"2026-03-02 10:00", "2026-03-16 10:00", "2026-03-30 10:00"}},
+		{"monthly on the 31st", "2026-01-31 10:00", "FREQ=MONTHLY", "2026-01-01 00:00", "2026-06-01 00:00",
+			[]string{"2026-01-31 10:00", "2026-03-31 10:00", "2026-05-31 10:00"}},
+		{"leap day", "2024-02-29 10:00", "FREQ=YEARLY", "2024-01-01 00:00", "2029-01-01 00:00",
+			[]string{"2024-02-29 10:00", "2028-02-29 10:00"}},
+		// far more steps from DTSTART than an expansion may take
+		{"decades later", "1990-01-01 09:00", "FREQ=DAILY", "2026-03-01 00:00", "2026-03-03 00:00",
+			[]string{"2026-03-01 09:00", "2026-03-02 09:00"}},
+		{"decades later weekly", "1990-01-01 09:00", "FREQ=WEEKLY;INTERVAL=3;BYDAY=MO,TH", "2026-03-01 00:00", "2026-03-31 00:00",
+			[]string{"2026-03-02 09:00", "2026-03-05 09:00", "2026-03-23 09:00", "2026-03-26 09:00"}},
+		{"still running at the window start", "2026-03-01 23:00", "FREQ=DAILY", "2026-03-03 00:30", "2026-03-03 12:00",
+			[]string{"2026-03-02 23:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := at(tt.start)
+			e := entity.Event{StartsAt: start, EndsAt: start.Add(2 * time.Hour), RRule: tt.rrule, Timezone: "UTC"}
+			got := dates(occurrences(e, at(tt.from), at(tt.to)))
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOccurrencesKeepWallClockAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data:", err)
+	}
+	start := time.Date(2026, 3, 27, 9, 0, 0, 0, loc)
+	e := entity.Event{StartsAt: start, EndsAt: start.Add(time.Hour), RRule: "FREQ=DAILY;COUNT=4", Timezone: "Europe/Berlin"}
+	for _, o := range occurrences(e, start, start.AddDate(0, 0, 7)) {
+		if o.Start.Hour() != 9 {
+			t.Errorf("%v: not at 09:00 local time", o.Start)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrAlreadyReported is returned when the user already has an open report
+// of the target
+var ErrAlreadyReported = repository.ErrAlreadyReported
+
+const maxReportDetailsLength = 1000
+
+// ReportService takes reports from users and runs the moderation queue.
+// Queue and Resolve are moderators only, the acting user is the user in ctx.
+type ReportService interface {
+	Report(ctx context.Context, rep *entity.Report) (int64, error)
+	// Queue groups the open reports by target, oldest first
+	Queue(ctx context.Context) ([]entity.ReportGroup, error)
+	// Resolve applies action to the target, closes all of its open reports
+	// and tells the reporters
+	Resolve(ctx context.Context, targetType string, targetID int64, action, note string) error
+}
+
+// NewReportService builds the report service, notes may be nil. Warnings
+// and bans issued from the queue are checked against users as the
+// sanction service checks them.
+func NewReportService(repo repository.ReportRepository, users repository.UserRepository, notes NotificationService) ReportService {
+	return &reportService{repo: repo, users: users, notes: notes}
+}
+
+type reportService struct {
+	repo  repository.ReportRepository
+	users repository.UserRepository
+	notes NotificationService
+}
+
+func (s *reportService) Report(ctx context.Context, rep *entity.Report) (int64, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return 0, ErrForbidden
+	}
+	rep.Details = strings.TrimSpace(rep.Details)
+	if !slices.Contains(entity.ReportReasons, rep.Reason) || utf8.RuneCountInString(rep.Details) > maxReportDetailsLength {
+		return 0, ErrInvalidInput
+	}
+	if rep.Reason == entity.ReasonOther && rep.Details == "" {
+		return 0, ErrInvalidInput
+	}
+	target, err := s.repo.Target(ctx, rep.TargetType, rep.TargetID)
+	if err != nil {
+		return 0, ErrInvalidInput
+	}
+	if target.Deleted || target.AuthorID == u.ID {
+		return 0, ErrInvalidInput
+	}
+	rep.ReporterID = u.ID
+	return s.repo.Create(ctx, rep)
+}
+
+func (s *reportService) Queue(ctx context.Context) ([]entity.ReportGroup, error) {
+	if _, err := moderator(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.Queue(ctx)
+}
+
+// resolvedText is what reporters are told about the outcome
+var resolvedText = map[string]string{
+	entity.ResolveDismiss: "нарушений не найдено",
+	entity.ResolveDelete:  "материал удалён",
+	entity.ResolveWarn:    "автор получил предупреждение",
+	entity.ResolveBan:     "автор заблокирован",
+}
+
+func (s *reportService) Resolve(ctx context.Context, targetType string, targetID int64, action, note string) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	if _, ok := resolvedText[action]; !ok || targetID <= 0 {
+		return ErrInvalidInput
+	}
+	target, err := s.repo.Target(ctx, targetType, targetID)
+	if err != nil {
+		return ErrInvalidInput
+	}
+	// gone content can only be dismissed, users are not deleted
+	if (target.Deleted && action != entity.ResolveDismiss) || (action == entity.ResolveDelete && targetType == entity.ReportUser) {
+		return ErrInvalidInput
+	}
+	note = strings.TrimSpace(note)
+	if action == entity.ResolveWarn || action == entity.ResolveBan {
+		// the note is the reason of the sanction
+		if err := canSanction(ctx, s.users, mod, target.AuthorID, note); err != nil {
+			return err
+		}
+	}
+	reporters, err := s.repo.Resolve(ctx, mod.ID, target, action, note)
+	if err != nil {
+		return err
+	}
+	if s.notes == nil {
+		return nil
+	}
+	url := target.URL
+	if action == entity.ResolveDelete || url == "" {
+		url = "/"
+	}
+	title := "Ваша жалоба рассмотрена: " + resolvedText[action]
+	for _, id := range reporters {
+		if err := s.notes.Notify(ctx, id, entity.NotificationReport, title, url); err != nil {
+			fmt.Println("report notification:", err)
+		}
+	}
+	return nil
+}
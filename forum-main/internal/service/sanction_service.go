@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrSanctioned is returned for writes by a suspended or banned user, it
+// wraps a *SanctionError whose message holds the reason
+var ErrSanctioned = repository.ErrSanctioned
+
+type SanctionError = repository.SanctionError
+
+const maxSanctionReasonLength = 1000
+
+// SanctionService issues warnings, suspensions and bans. Issue, Revoke
+// and other users' lists are moderators only; the acting user is the user
+// in ctx.
+type SanctionService interface {
+	// Issue sanctions s.UserID; only admins may sanction moderators
+	Issue(ctx context.Context, s *entity.Sanction) (int64, error)
+	Revoke(ctx context.Context, id int64) error
+	// ForUser lists all sanctions of the user, for moderators and the user
+	ForUser(ctx context.Context, userID int64) ([]entity.Sanction, error)
+	// Current lists the sanctions of the user in ctx that are in force or
+	// not seen yet
+	Current(ctx context.Context) ([]entity.Sanction, error)
+	// MarkSeen marks the sanctions of the user in ctx as seen
+	MarkSeen(ctx context.Context) error
+}
+
+func NewSanctionService(repo repository.SanctionRepository, users repository.UserRepository) SanctionService {
+	return &sanctionService{repo: repo, users: users}
+}
+
+type sanctionService struct {
+	repo  repository.SanctionRepository
+	users repository.UserRepository
+}
+
+func (s *sanctionService) Issue(ctx context.Context, sn *entity.Sanction) (int64, error) {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sn.Reason = strings.TrimSpace(sn.Reason)
+	if err := canSanction(ctx, s.users, mod, sn.UserID, sn.Reason); err != nil {
+		return 0, err
+	}
+	if !slices.Contains(entity.SanctionKinds, sn.Kind) {
+		return 0, ErrInvalidInput
+	}
+	// warnings don't end, suspensions must, bans may
+	switch {
+	case sn.Kind == entity.SanctionWarning:
+		sn.EndsAt = nil
+	case sn.Kind == entity.SanctionSuspension && sn.EndsAt == nil:
+		return 0, ErrInvalidInput
+	case sn.EndsAt != nil && !sn.EndsAt.After(time.Now()):
+		return 0, ErrInvalidInput
+	}
+	if (sn.Kind == entity.SanctionBoardBan) != (sn.BoardID > 0) {
+		return 0, ErrInvalidInput
+	}
+	sn.ModeratorID = mod.ID
+	id, err := s.repo.Create(ctx, sn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrInvalidInput
+	}
+	return id, err
+}
+
+// canSanction checks that mod may sanction the user for reason: never
+// themselves, moderators only by admins, and always with a reason
+func canSanction(ctx context.Context, users repository.UserRepository, mod *entity.User, userID int64, reason string) error {
+	if userID == mod.ID || reason == "" || utf8.RuneCountInString(reason) > maxSanctionReasonLength {
+		return ErrInvalidInput
+	}
+	target, err := users.GetUserByID(ctx, userID)
+	if err != nil || target == nil {
+		return ErrInvalidInput
+	}
+	if target.HasRole(entity.RoleModerator) && !mod.HasRole(entity.RoleAdmin) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func (s *sanctionService) Revoke(ctx context.Context, id int64) error {
+	mod, err := moderator(ctx)
+	if err != nil {
+		return err
+	}
+	sn, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if target, err := s.users.GetUserByID(ctx, sn.UserID); err == nil && target != nil &&
+		target.HasRole(entity.RoleModerator) && !mod.HasRole(entity.RoleAdmin) {
+		return ErrForbidden
+	}
+	return s.repo.Revoke(ctx, mod.ID, sn)
+}
+
+func (s *sanctionService) ForUser(ctx context.Context, userID int64) ([]entity.Sanction, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil || (u.ID != userID && !u.HasRole(entity.RoleModerator)) {
+		return nil, ErrForbidden
+	}
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func (s *sanctionService) Current(ctx context.Context) ([]entity.Sanction, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return nil, nil
+	}
+	return s.repo.Current(ctx, u.ID)
+}
+
+func (s *sanctionService) MarkSeen(ctx context.Context) error {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return ErrForbidden
+	}
+	return s.repo.MarkSeen(ctx, u.ID)
+}
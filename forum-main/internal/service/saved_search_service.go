@@ -0,0 +1,249 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"html"
+	"html/template"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxSavedSearches = 20
+	// instantAlertLimit is how many matches of one run become separate
+	// notifications; more than that are summed up in a single one.
+	instantAlertLimit     = 10
+	DefaultDigestInterval = 24 * time.Hour
+)
+
+var ErrSavedSearchLimit = errors.New("too many saved searches")
+
+// SavedSearchService keeps the users' saved searches and alerts them about
+// new posts and comments matching those.
+type SavedSearchService interface {
+	List(ctx context.Context, userID int64) ([]entity.SavedSearch, error)
+	Save(ctx context.Context, userID int64, query, mode string) (*entity.SavedSearch, error)
+	SetMode(ctx context.Context, userID, id int64, mode string) error
+	Delete(ctx context.Context, userID, id int64) error
+	// MatchNew runs the content created since the previous run against the
+	// saved searches that are due and sends the alerts.
+	MatchNew(ctx context.Context) error
+	// RunMatcher calls MatchNew every interval until ctx is done; a
+	// non-positive interval disables alerts
+	RunMatcher(ctx context.Context, interval time.Duration)
+}
+
+type SavedSearchOption func(*savedSearchService)
+
+// WithDigestInterval sets how often digest mode searches are run
+func WithDigestInterval(d time.Duration) SavedSearchOption {
+	return func(s *savedSearchService) {
+		if d > 0 {
+			s.digestInterval = d
+		}
+	}
+}
+
+// NewSavedSearchService builds the service; the searches run as their
+// owners, loaded from users
+func NewSavedSearchService(repo repository.SavedSearchRepository, search repository.SearchRepository, users repository.UserRepository,
+	notify NotificationService, opts ...SavedSearchOption) SavedSearchService {
+	s := &savedSearchService{repo: repo, search: search, users: users, notify: notify, digestInterval: DefaultDigestInterval}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+type savedSearchService struct {
+	repo           repository.SavedSearchRepository
+	search         repository.SearchRepository
+	users          repository.UserRepository
+	notify         NotificationService
+	digestInterval time.Duration
+}
+
+func (s *savedSearchService) List(ctx context.Context, userID int64) ([]entity.SavedSearch, error) {
+	if userID == 0 {
+		return nil, ErrInvalidInput
+	}
+	return s.repo.ListByUser(ctx, userID)
+}
+
+func validAlertMode(mode string) bool {
+	return mode == entity.AlertInstant || mode == entity.AlertDigest
+}
+
+func (s *savedSearchService) Save(ctx context.Context, userID int64, query, mode string) (*entity.SavedSearch, error) {
+	query = strings.TrimSpace(query)
+	if mode == "" {
+		mode = entity.AlertInstant
+	}
+	if userID == 0 || !validAlertMode(mode) || utf8.RuneCountInString(query) > maxSearchLength {
+		return nil, ErrInvalidInput
+	}
+	if q := ParseSearchQuery(query); !q.HasText() && len(q.Filters) == 0 {
+		return nil, ErrInvalidInput
+	}
+	existing, err := s.repo.ListByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxSavedSearches {
+		return nil, ErrSavedSearchLimit
+	}
+	// alerts start with the content published from now on
+	seq, err := s.repo.LatestSeq(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ss := &entity.SavedSearch{UserID: userID, Query: query, Mode: mode, LastSeq: seq}
+	if _, err := s.repo.Create(ctx, ss); err != nil {
+		return nil, err
+	}
+	return ss, nil
+}
+
+func (s *savedSearchService) SetMode(ctx context.Context, userID, id int64, mode string) error {
+	if userID == 0 || id == 0 || !validAlertMode(mode) {
+		return ErrInvalidInput
+	}
+	return s.repo.SetMode(ctx, id, userID, mode)
+}
+
+func (s *savedSearchService) Delete(ctx context.Context, userID, id int64) error {
+	if userID == 0 || id == 0 {
+		return ErrInvalidInput
+	}
+	return s.repo.Delete(ctx, id, userID)
+}
+
+func (s *savedSearchService) RunMatcher(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.MatchNew(ctx); err != nil {
+				fmt.Println("saved search matcher:", err)
+			}
+		}
+	}
+}
+
+func (s *savedSearchService) MatchNew(ctx context.Context) error {
+	// everything published up to seq is matched in this run; content
+	// published meanwhile, or still being committed, is left for a later one
+	seq, err := s.repo.SettledSeq(ctx)
+	if err != nil {
+		return err
+	}
+	searches, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, ss := range searches {
+		if ss.Mode == entity.AlertDigest && now.Sub(ss.LastRunAt) < s.digestInterval {
+			continue
+		}
+		if ss.LastSeq >= seq {
+			continue
+		}
+		matched, err := s.match(ctx, ss, seq)
+		if err != nil {
+			fmt.Println("saved search", ss.ID, err)
+		}
+		if matched <= ss.LastSeq {
+			continue
+		}
+		if err := s.repo.Advance(ctx, ss.ID, matched, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alert is one notification of an instant saved search
+type alert struct {
+	seq        int64
+	title, url string
+}
+
+// match sends the alerts of ss for the content published up to seq and
+// returns how far it got: seq, or on failure the last content alerted
+// about, so that no alert is sent twice
+func (s *savedSearchService) match(ctx context.Context, ss entity.SavedSearch, seq int64) (int64, error) {
+	q := ParseSearchQuery(ss.Query)
+	q.ExcludeAuthorID = ss.UserID
+	// search as the owner, role included, so alerts skip boards they can't
+	// see and cover those they moderate
+	owner, err := s.users.GetUserByID(ctx, ss.UserID)
+	if err != nil {
+		return ss.LastSeq, err
+	}
+	if owner == nil {
+		return ss.LastSeq, ErrInvalidInput
+	}
+	ctx = entity.ContextWithUser(ctx, owner)
+
+	q.AfterSeq, q.UpToSeq = ss.LastSeq, seq
+	posts, postTotal, err := s.search.SearchPosts(ctx, q, instantAlertLimit, 0)
+	if err != nil {
+		return ss.LastSeq, err
+	}
+	comments, commentTotal, err := s.search.SearchComments(ctx, q, instantAlertLimit, 0)
+	if err != nil {
+		return ss.LastSeq, err
+	}
+
+	total := postTotal + commentTotal
+	if total == 0 {
+		return seq, nil
+	}
+	if ss.Mode == entity.AlertDigest || total > instantAlertLimit {
+		err := s.notify.Notify(ctx, ss.UserID, entity.NotificationSavedSearch,
+			fmt.Sprintf("«%s»: новых совпадений — %d", ss.Query, total),
+			"/search?q="+url.QueryEscape(ss.Query))
+		if err != nil {
+			return ss.LastSeq, err
+		}
+		return seq, nil
+	}
+	alerts := make([]alert, 0, total)
+	for _, h := range posts {
+		alerts = append(alerts, alert{h.Seq, fmt.Sprintf("«%s»: новый пост «%s»", ss.Query, plainText(h.Title)), fmt.Sprintf("/post/%d", h.ID)})
+	}
+	for _, h := range comments {
+		alerts = append(alerts, alert{h.Seq, fmt.Sprintf("«%s»: новый комментарий к «%s»", ss.Query, h.PostTitle), h.URL()})
+	}
+	// in publication order, so everything before a failed alert was sent
+	slices.SortFunc(alerts, func(a, b alert) int { return cmp.Compare(a.seq, b.seq) })
+	sent := ss.LastSeq
+	for _, a := range alerts {
+		if err := s.notify.Notify(ctx, ss.UserID, entity.NotificationSavedSearch, a.title, a.url); err != nil {
+			return sent, err
+		}
+		sent = a.seq
+	}
+	return seq, nil
+}
+
+// plainText drops the highlighting of a search hit
+func plainText(h template.HTML) string {
+	s := strings.NewReplacer("<mark>", "", "</mark>", "").Replace(string(h))
+	return html.UnescapeString(s)
+}
@@ -0,0 +1,123 @@
+package service
+
+import (
+	"forum1/internal/entity"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,50}$`)
+
+// ParseSearchQuery parses the /search syntax:
+//
+//	board:games author:alice tag:go before:2026-01-01 after:2025-06-01
+//	has:image has:link "exact phrase" -excluded -"excluded phrase"
+//
+// Everything else is a plain word. A filter with an invalid value (like
+// before:yesterday) is searched as a plain word too.
+func ParseSearchQuery(raw string) entity.SearchQuery {
+	q := entity.SearchQuery{Text: strings.TrimSpace(raw)}
+	tokens := splitSearchTokens(q.Text)
+	for i, tok := range tokens {
+		label, ok := parseSearchToken(&q, tok)
+		if !ok || label == "" {
+			continue
+		}
+		rest := make([]string, 0, len(tokens)-1)
+		rest = append(rest, tokens[:i]...)
+		rest = append(rest, tokens[i+1:]...)
+		q.Filters = append(q.Filters, entity.SearchFilter{Label: label, Remove: strings.Join(rest, " ")})
+	}
+	return q
+}
+
+// parseSearchToken adds tok to q. It returns the chip label for filters and
+// excluded words, "" for words and phrases.
+func parseSearchToken(q *entity.SearchQuery, tok string) (string, bool) {
+	if neg := strings.TrimPrefix(tok, "-"); neg != tok && neg != "" {
+		neg = unquote(neg)
+		if neg == "" {
+			return "", false
+		}
+		q.Excluded = append(q.Excluded, neg)
+		return "без: " + neg, true
+	}
+	if strings.HasPrefix(tok, `"`) {
+		if p := unquote(tok); p != "" {
+			q.Phrases = append(q.Phrases, p)
+		}
+		return "", true
+	}
+
+	key, val, found := strings.Cut(tok, ":")
+	val = unquote(val)
+	if found && val != "" {
+		switch key = strings.ToLower(key); key {
+		case "board":
+			q.Board = strings.ToLower(val)
+			return "доска: " + q.Board, true
+		case "author":
+			q.Author = val
+			return "автор: " + val, true
+		case "tag":
+			if tag := strings.ToLower(strings.TrimPrefix(val, "#")); tagPattern.MatchString(tag) {
+				q.Tags = append(q.Tags, tag)
+				return "тег: #" + tag, true
+			}
+		case "before", "after":
+			if d, err := time.Parse("2006-01-02", val); err == nil {
+				if key == "before" {
+					q.Before = d
+					return "до " + d.Format("02.01.2006"), true
+				}
+				q.After = d
+				return "после " + d.Format("02.01.2006"), true
+			}
+		case "has":
+			switch strings.ToLower(val) {
+			case "image":
+				q.HasImage = true
+				return "с картинкой", true
+			case "link":
+				q.HasLink = true
+				return "со ссылкой", true
+			}
+		}
+	}
+	if word := unquote(tok); word != "" {
+		q.Terms = append(q.Terms, word)
+	}
+	return "", true
+}
+
+// splitSearchTokens splits on whitespace, keeping "quoted parts" (also
+// inside a token, as in board:"a b" or -"a b") together.
+func splitSearchTokens(s string) []string {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case unicode.IsSpace(r) && !inQuotes:
+			if cur.Len() > 0 {
+				tokens = append(tokens, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		tokens = append(tokens, cur.String())
+	}
+	return tokens
+}
+
+func unquote(s string) string {
+	return strings.TrimSpace(strings.ReplaceAll(s, `"`, ""))
+}
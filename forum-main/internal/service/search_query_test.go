@@ -0,0 +1,58 @@
+package service
+
+import (
+	"forum1/internal/entity"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	tests := []struct {
+		raw  string
+		want entity.SearchQuery
+	}{
+		{"", entity.SearchQuery{}},
+		{"  go   channels ", entity.SearchQuery{Terms: []string{"go", "channels"}}},
+		{`"race condition" go`, entity.SearchQuery{Phrases: []string{"race condition"}, Terms: []string{"go"}}},
+		{`go -java -"spring boot"`, entity.SearchQuery{Terms: []string{"go"}, Excluded: []string{"java", "spring boot"}}},
+		{`board:Golang author:Ann`, entity.SearchQuery{Board: "golang", Author: "Ann"}},
+		{`board:"a b"`, entity.SearchQuery{Board: "a b"}},
+		{"tag:#Go tag:db", entity.SearchQuery{Tags: []string{"go", "db"}}},
+		{"after:2026-01-01 before:2026-02-01", entity.SearchQuery{After: day("2026-01-01"), Before: day("2026-02-01")}},
+		{"has:image HAS:Link", entity.SearchQuery{HasImage: true, HasLink: true}},
+		// filters that don't parse are searched as words
+		{"before:yesterday has:video tag:", entity.SearchQuery{Terms: []string{"before:yesterday", "has:video", "tag:"}}},
+		{"color:red", entity.SearchQuery{Terms: []string{"color:red"}}},
+		{"- -", entity.SearchQuery{Terms: []string{"-", "-"}}},
+		{`-""`, entity.SearchQuery{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got := ParseSearchQuery(tt.raw)
+			got.Text, got.Filters = "", nil
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryFilters(t *testing.T) {
+	q := ParseSearchQuery(`go board:golang -java has:link`)
+	want := []entity.SearchFilter{
+		{Label: "доска: golang", Remove: "go -java has:link"},
+		{Label: "без: java", Remove: "go board:golang has:link"},
+		{Label: "со ссылкой", Remove: "go board:golang -java"},
+	}
+	if !reflect.DeepEqual(q.Filters, want) {
+		t.Errorf("got %+v, want %+v", q.Filters, want)
+	}
+	if q.Text != `go board:golang -java has:link` {
+		t.Errorf("text %q", q.Text)
+	}
+}
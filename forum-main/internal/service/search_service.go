@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	searchPageSize   = 20
+	searchGroupLimit = 5
+	maxSearchLength  = 200
+	suggestLimit     = 5
+	suggestMinLength = 2
+	// maxSearchPage caps the page number, so that the offset stays a
+	// sane positive number however large a page is asked for
+	maxSearchPage = 500
+)
+
+// SearchService is the single search backend of the HTML and JSON endpoints
+type SearchService interface {
+	Search(ctx context.Context, q entity.SearchQuery) (*entity.SearchResults, error)
+	// Suggest returns typeahead suggestions for a partially typed query
+	Suggest(ctx context.Context, text string) ([]entity.Suggestion, error)
+}
+
+func NewSearchService(repo repository.SearchRepository) SearchService {
+	return &searchService{repo: repo}
+}
+
+type searchService struct{ repo repository.SearchRepository }
+
+func (s *searchService) Search(ctx context.Context, q entity.SearchQuery) (*entity.SearchResults, error) {
+	q.Text = strings.TrimSpace(q.Text)
+	if utf8.RuneCountInString(q.Text) > maxSearchLength {
+		return nil, ErrInvalidInput
+	}
+	switch q.Type {
+	case "", entity.SearchPosts, entity.SearchComments, entity.SearchBoards, entity.SearchUsers:
+	default:
+		return nil, ErrInvalidInput
+	}
+	if q.Page < 1 || q.Type == "" {
+		q.Page = 1
+	}
+	if q.Page > maxSearchPage {
+		q.Page = maxSearchPage
+	}
+	if q.Limit <= 0 || q.Limit > MaxPageSize {
+		q.Limit = searchPageSize
+	}
+	parsed := ParseSearchQuery(q.Text)
+	parsed.Type, parsed.Page, parsed.Limit = q.Type, q.Page, q.Limit
+	q = parsed
+
+	res := &entity.SearchResults{
+		Query: q.Text, Type: q.Type, Filters: q.Filters, Page: q.Page,
+		Posts: []entity.PostHit{}, Comments: []entity.CommentHit{}, Boards: []entity.Board{}, Users: []entity.UserHit{},
+	}
+	if res.Filters == nil {
+		res.Filters = []entity.SearchFilter{}
+	}
+	if !q.HasText() && len(q.Filters) == 0 {
+		return res, nil
+	}
+
+	// window returns limit and offset of a result type: a page of the
+	// selected type, the first few matches of the others (for the counts)
+	window := func(typ string) (int, int) {
+		if typ == q.Type {
+			return q.Limit, (q.Page - 1) * q.Limit
+		}
+		if q.Type == "" && typ == entity.SearchPosts {
+			return q.Limit, 0
+		}
+		return searchGroupLimit, 0
+	}
+	keep := func(typ string) bool { return q.Type == "" || q.Type == typ }
+
+	limit, offset := window(entity.SearchPosts)
+	posts, total, err := s.repo.SearchPosts(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res.Counts.Posts = total
+	if keep(entity.SearchPosts) && posts != nil {
+		res.Posts = posts
+	}
+
+	limit, offset = window(entity.SearchComments)
+	comments, total, err := s.repo.SearchComments(ctx, q, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	res.Counts.Comments = total
+	if keep(entity.SearchComments) && comments != nil {
+		res.Comments = comments
+	}
+
+	// boards and users have no filters, so a filtered search skips them
+	if q.HasText() && len(q.Filters) == 0 {
+		limit, offset = window(entity.SearchBoards)
+		boards, total, err := s.repo.SearchBoards(ctx, repository.WebsearchText(q), limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		res.Counts.Boards = total
+		if keep(entity.SearchBoards) && boards != nil {
+			res.Boards = boards
+		}
+	}
+	if len(q.Terms) > 0 && len(q.Filters) == 0 {
+		limit, offset = window(entity.SearchUsers)
+		users, total, err := s.repo.SearchUsers(ctx, q.Terms[0], limit, offset)
+		if err != nil {
+			return nil, err
+		}
+		res.Counts.Users = total
+		if keep(entity.SearchUsers) && users != nil {
+			res.Users = users
+		}
+	}
+
+	if q.Type != "" {
+		count := map[string]int{
+			entity.SearchPosts:    res.Counts.Posts,
+			entity.SearchComments: res.Counts.Comments,
+			entity.SearchBoards:   res.Counts.Boards,
+			entity.SearchUsers:    res.Counts.Users,
+		}[q.Type]
+		res.HasNext = q.Page*q.Limit < count
+	}
+	return res, nil
+}
+
+func (s *searchService) Suggest(ctx context.Context, text string) ([]entity.Suggestion, error) {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) > maxSearchLength {
+		return nil, ErrInvalidInput
+	}
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if utf8.RuneCountInString(strings.Join(words, "")) < suggestMinLength {
+		return []entity.Suggestion{}, nil
+	}
+	if len(words) > 5 {
+		words = words[:5]
+	}
+	// every word is a prefix, so "го прог" finds "голанг программирование"
+	for i, w := range words {
+		words[i] = w + ":*"
+	}
+	res, err := s.repo.Suggest(ctx, strings.Join(words, " & "), strings.Fields(text)[0], suggestLimit)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = []entity.Suggestion{}
+	}
+	return res, nil
+}
@@ -0,0 +1,205 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxSpamTokens caps the tokens taken from one post or comment
+	maxSpamTokens = 1000
+	// spamLearnBatch is how many decisions Learn reads at a time
+	spamLearnBatch = 500
+	// spamStrength is how many examples of a token it takes for its own
+	// counts to outweigh the neutral 0.5 (Robinson's s)
+	spamStrength = 1.0
+)
+
+// spamURLPattern finds the links in content
+var spamURLPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"')\]]+`)
+
+// SpamService is a naive Bayes spam filter that learns from moderators:
+// approving held content is a ham example, deleting content as spam a spam
+// example.
+type SpamService interface {
+	// Score is the probability that doc is spam, 0 until the filter has
+	// learned enough examples of both kinds
+	Score(ctx context.Context, doc entity.SpamDoc) (float64, error)
+	// HoldReason tells why new content of the user in ctx is held as likely
+	// spam, "" when it isn't. Moderators are never held.
+	HoldReason(ctx context.Context, doc entity.SpamDoc) (string, error)
+	// Learn trains the filter on the decisions logged since it last learned
+	// and returns how many it read
+	Learn(ctx context.Context) (int, error)
+	// Rebuild forgets what the filter learned and learns the whole audit
+	// log again
+	Rebuild(ctx context.Context) (int, error)
+}
+
+func NewSpamService(repo repository.SpamRepository, cfg entity.SpamConfig) SpamService {
+	return &spamService{repo: repo, cfg: cfg}
+}
+
+type spamService struct {
+	repo repository.SpamRepository
+	cfg  entity.SpamConfig
+}
+
+// spamWords splits text into lower case words of 2 to 30 letters and digits
+func spamWords(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if n := utf8.RuneCountInString(w); n >= 2 && n <= 30 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// linkDomains is the host of a link and its parent domains down to two
+// labels: a.b.example.com, b.example.com, example.com
+func linkDomains(link string) []string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	if host == "" {
+		return nil
+	}
+	domains := []string{host}
+	for strings.Count(host, ".") > 1 {
+		host = host[strings.Index(host, ".")+1:]
+		domains = append(domains, host)
+	}
+	return domains
+}
+
+// spamTokens are the distinct tokens of doc: words of the title and of the
+// content, and the domains of the link and of the links in the content,
+// prefixed so that a word in a title and a domain count apart from the
+// same word in the text
+func spamTokens(doc entity.SpamDoc) []string {
+	seen := make(map[string]bool)
+	var tokens []string
+	add := func(t string) {
+		if !seen[t] && len(tokens) < maxSpamTokens {
+			seen[t] = true
+			tokens = append(tokens, t)
+		}
+	}
+	for _, w := range spamWords(doc.Title) {
+		add("title:" + w)
+	}
+	links := spamURLPattern.FindAllString(doc.Content, -1)
+	if doc.LinkURL != "" {
+		links = append([]string{doc.LinkURL}, links...)
+	}
+	for _, link := range links {
+		for _, d := range linkDomains(link) {
+			add("domain:" + d)
+		}
+	}
+	for _, w := range spamWords(doc.Content) {
+		add(w)
+	}
+	return tokens
+}
+
+// Tokens are scored by Robinson's method and the most telling ones are
+// combined as in Graham's "A Plan for Spam".
+func (s *spamService) Score(ctx context.Context, doc entity.SpamDoc) (float64, error) {
+	m, err := s.repo.Model(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if m.SpamDocs < s.cfg.MinExamples || m.HamDocs < s.cfg.MinExamples {
+		return 0, nil
+	}
+	counts, err := s.repo.TokenCounts(ctx, spamTokens(doc))
+	if err != nil {
+		return 0, err
+	}
+	probs := make([]float64, 0, len(counts))
+	for _, c := range counts {
+		spamFreq := float64(c.Spam) / float64(m.SpamDocs)
+		hamFreq := float64(c.Ham) / float64(m.HamDocs)
+		if spamFreq+hamFreq == 0 {
+			continue
+		}
+		n := float64(c.Spam + c.Ham)
+		p := (spamStrength*0.5 + n*spamFreq/(spamFreq+hamFreq)) / (spamStrength + n)
+		probs = append(probs, min(max(p, 0.01), 0.99))
+	}
+	if len(probs) == 0 {
+		return 0.5, nil
+	}
+	sort.Slice(probs, func(i, j int) bool { return math.Abs(probs[i]-0.5) > math.Abs(probs[j]-0.5) })
+	if len(probs) > s.cfg.Interesting {
+		probs = probs[:s.cfg.Interesting]
+	}
+	var logSpam, logHam float64
+	for _, p := range probs {
+		logSpam += math.Log(p)
+		logHam += math.Log(1 - p)
+	}
+	return 1 / (1 + math.Exp(logHam-logSpam)), nil
+}
+
+func (s *spamService) HoldReason(ctx context.Context, doc entity.SpamDoc) (string, error) {
+	if u := entity.UserFromContext(ctx); u == nil || u.HasRole(entity.RoleModerator) {
+		return "", nil
+	}
+	score, err := s.Score(ctx, doc)
+	if err != nil {
+		return "", err
+	}
+	if score < s.cfg.Threshold {
+		return "", nil
+	}
+	return fmt.Sprintf("spam filter score %.2f", score), nil
+}
+
+func (s *spamService) Learn(ctx context.Context) (int, error) {
+	m, err := s.repo.Model(ctx)
+	if err != nil {
+		return 0, err
+	}
+	learned, after := 0, m.LastActionID
+	for {
+		examples, err := s.repo.Decisions(ctx, after, spamLearnBatch)
+		if err != nil {
+			return learned, err
+		}
+		for i := range examples {
+			ex := &examples[i]
+			if err := s.repo.Train(ctx, ex, spamTokens(ex.Doc)); err != nil {
+				return learned, err
+			}
+			after = ex.ActionID
+			learned++
+		}
+		if len(examples) < spamLearnBatch {
+			return learned, nil
+		}
+	}
+}
+
+func (s *spamService) Rebuild(ctx context.Context) (int, error) {
+	if err := s.repo.Reset(ctx); err != nil {
+		return 0, err
+	}
+	return s.Learn(ctx)
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"forum1/internal/entity"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSpamTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  entity.SpamDoc
+		want []string
+	}{
+		{"empty", entity.SpamDoc{}, nil},
+		{"words", entity.SpamDoc{Content: "Buy cheap, buy NOW! a"}, []string{"buy", "cheap", "now"}},
+		{"title apart from content", entity.SpamDoc{Title: "Cheap pills", Content: "cheap"},
+			[]string{"title:cheap", "title:pills", "cheap"}},
+		{"cyrillic", entity.SpamDoc{Content: "Дёшево КУПИТЬ"}, []string{"дёшево", "купить"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := spamTokens(tt.doc); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// a link counts for its host and each parent domain
+	got := spamTokens(entity.SpamDoc{LinkURL: "https://www.shop.example.com/x", Content: "see http://a.b.spam.net/p"})
+	for _, want := range []string{"domain:shop.example.com", "domain:example.com", "domain:a.b.spam.net", "domain:b.spam.net", "domain:spam.net"} {
+		if !slices.Contains(got, want) {
+			t.Errorf("%q missing from %q", want, got)
+		}
+	}
+
+	var long strings.Builder
+	for i := 0; i < 3*maxSpamTokens; i++ {
+		fmt.Fprintf(&long, "w%d ", i)
+	}
+	if n := len(spamTokens(entity.SpamDoc{Content: long.String()})); n != maxSpamTokens {
+		t.Errorf("got %d tokens, want the cap of %d", n, maxSpamTokens)
+	}
+}
+
+type memSpamRepo struct {
+	model  entity.SpamModel
+	counts map[string]entity.SpamTokenCount
+}
+
+func (r *memSpamRepo) Model(ctx context.Context) (*entity.SpamModel, error) { return &r.model, nil }
+
+func (r *memSpamRepo) TokenCounts(ctx context.Context, tokens []string) (map[string]entity.SpamTokenCount, error) {
+	out := make(map[string]entity.SpamTokenCount)
+	for _, t := range tokens {
+		if c, ok := r.counts[t]; ok {
+			out[t] = c
+		}
+	}
+	return out, nil
+}
+
+func (r *memSpamRepo) Decisions(ctx context.Context, afterID int64, limit int) ([]entity.SpamExample, error) {
+	return nil, nil
+}
+
+func (r *memSpamRepo) Train(ctx context.Context, ex *entity.SpamExample, tokens []string) error {
+	return nil
+}
+
+func (r *memSpamRepo) Reset(ctx context.Context) error { return nil }
+
+func TestSpamScore(t *testing.T) {
+	repo := &memSpamRepo{
+		model: entity.SpamModel{SpamDocs: 50, HamDocs: 50},
+		counts: map[string]entity.SpamTokenCount{
+			"viagra":              {Spam: 40, Ham: 0},
+			"casino":              {Spam: 30, Ham: 1},
+			"domain:spam.example": {Spam: 25, Ham: 0},
+			"golang":              {Spam: 0, Ham: 35},
+			"channels":            {Spam: 1, Ham: 20},
+			"the":                 {Spam: 45, Ham: 45},
+		},
+	}
+	s := NewSpamService(repo, entity.DefaultSpamConfig())
+	tests := []struct {
+		name     string
+		doc      entity.SpamDoc
+		min, max float64
+	}{
+		{"spam words", entity.SpamDoc{Content: "viagra casino"}, 0.99, 1},
+		{"spam link", entity.SpamDoc{Content: "the best at https://spam.example/x"}, 0.9, 1},
+		{"ham words", entity.SpamDoc{Content: "golang channels"}, 0, 0.01},
+		{"neutral word", entity.SpamDoc{Content: "the"}, 0.49, 0.51},
+		{"unknown words", entity.SpamDoc{Content: "something else"}, 0.5, 0.5},
+		{"mixed leans to the stronger side", entity.SpamDoc{Content: "viagra casino golang"}, 0.9, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.Score(context.Background(), tt.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got < tt.min || got > tt.max {
+				t.Errorf("score %.4f, want within [%.2f, %.2f]", got, tt.min, tt.max)
+			}
+		})
+	}
+
+	// too few examples of a kind: the filter says nothing yet
+	repo.model.HamDocs = 5
+	if got, _ := s.Score(context.Background(), entity.SpamDoc{Content: "viagra"}); got != 0 {
+		t.Errorf("score %.2f before the filter has learned enough, want 0", got)
+	}
+}
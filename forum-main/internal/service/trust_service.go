@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"forum1/internal/entity"
+	"forum1/internal/repository"
+	"time"
+)
+
+// ErrTrustLevel is returned when the user's trust level is too low for
+// what they do; the error wraps it with the level needed
+var ErrTrustLevel = errors.New("trust level too low")
+
+// DefaultTrustInterval is how often trust levels are recalculated
+const DefaultTrustInterval = time.Hour
+
+// TrustService computes trust levels and gates capabilities by them.
+// Moderators have every capability.
+type TrustService interface {
+	// Require fails with ErrTrustLevel unless the user in ctx has the
+	// capability, one of the entity.Cap* constants
+	Require(ctx context.Context, capability string) error
+	// Progress is the trust level of the user and what the next one takes,
+	// for the user and moderators
+	Progress(ctx context.Context, userID int64) (*entity.TrustProgress, error)
+	// RecordView counts the post as read by the user in ctx
+	RecordView(ctx context.Context, postID int64) error
+	// Recalculate recomputes every user's level and returns how many changed
+	Recalculate(ctx context.Context) (int, error)
+	// RunRecalculation calls Recalculate every interval until ctx is done
+	RunRecalculation(ctx context.Context, interval time.Duration)
+}
+
+// NewTrustService builds the trust service, notes may be nil
+func NewTrustService(repo repository.TrustRepository, cfg entity.TrustConfig, notes NotificationService) TrustService {
+	return &trustService{repo: repo, cfg: cfg, notes: notes}
+}
+
+type trustService struct {
+	repo  repository.TrustRepository
+	cfg   entity.TrustConfig
+	notes NotificationService
+}
+
+func (s *trustService) Require(ctx context.Context, capability string) error {
+	u := entity.UserFromContext(ctx)
+	if u == nil {
+		return ErrForbidden
+	}
+	level := s.cfg.Capabilities[capability]
+	if !u.HasTrust(level) {
+		return fmt.Errorf("%w: %s takes trust level %s", ErrTrustLevel, capability, entity.TrustLevelName(level))
+	}
+	return nil
+}
+
+func (s *trustService) Progress(ctx context.Context, userID int64) (*entity.TrustProgress, error) {
+	u := entity.UserFromContext(ctx)
+	if u == nil || (u.ID != userID && !u.HasRole(entity.RoleModerator)) {
+		return nil, ErrForbidden
+	}
+	stats, err := s.repo.Stats(ctx, userID, time.Now().Add(-s.cfg.FlagWindow))
+	if err != nil {
+		return nil, err
+	}
+	if len(stats) == 0 {
+		return nil, ErrInvalidInput
+	}
+	// the stored level, the one in force until the next recalculation
+	p := &entity.TrustProgress{Level: stats[0].Level, LevelName: entity.TrustLevelName(stats[0].Level), Stats: stats[0]}
+	if next := p.Level + 1; next <= entity.TrustLeader {
+		req := s.cfg.Requirements[next]
+		p.Next, p.NextName = &req, entity.TrustLevelName(next)
+	}
+	return p, nil
+}
+
+func (s *trustService) RecordView(ctx context.Context, postID int64) error {
+	u := entity.UserFromContext(ctx)
+	if u == nil || postID <= 0 {
+		return nil
+	}
+	return s.repo.RecordView(ctx, postID, u.ID)
+}
+
+func (s *trustService) Recalculate(ctx context.Context) (int, error) {
+	stats, err := s.repo.Stats(ctx, 0, time.Now().Add(-s.cfg.FlagWindow))
+	if err != nil {
+		return 0, err
+	}
+	changed := 0
+	for _, st := range stats {
+		level := s.cfg.Level(st)
+		if level == st.Level {
+			continue
+		}
+		if err := s.repo.SetLevel(ctx, st.UserID, level); err != nil {
+			return changed, err
+		}
+		changed++
+		if level > st.Level && s.notes != nil {
+			title := "Ваш уровень доверия повышен: " + entity.TrustLevelName(level)
+			if err := s.notes.Notify(ctx, st.UserID, entity.NotificationTrustLevel, title, "/trust"); err != nil {
+				fmt.Println("trust notification:", err)
+			}
+		}
+	}
+	return changed, nil
+}
+
+func (s *trustService) RunRecalculation(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Recalculate(ctx); err != nil {
+				fmt.Println("trust levels:", err)
+			}
+		}
+	}
+}
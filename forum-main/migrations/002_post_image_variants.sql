@@ -0,0 +1,15 @@
+-- Resized copies of post images, generated lazily on first request
+CREATE TABLE IF NOT EXISTS post_image_variants (
+    post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    width INTEGER NOT NULL,
+    content_type TEXT NOT NULL,
+    data BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (post_id, width)
+);
+
+-- Hash of the original image, computed when it is written and used for the
+-- ETag of the image and its resized copies
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS image_hash TEXT;
+UPDATE posts SET image_hash = encode(sha256(image_data), 'hex')
+WHERE image_hash IS NULL AND image_data IS NOT NULL;
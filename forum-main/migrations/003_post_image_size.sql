@@ -0,0 +1,3 @@
+-- Dimensions of the stored (re-encoded) post image, used for layout
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS image_width INTEGER;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS image_height INTEGER;
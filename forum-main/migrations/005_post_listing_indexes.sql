@@ -0,0 +1,4 @@
+-- Keyset pagination of post listings
+CREATE INDEX IF NOT EXISTS posts_board_created_idx ON posts (board_id, created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS posts_created_idx ON posts (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS comments_post_created_idx ON comments (post_id, created_at);
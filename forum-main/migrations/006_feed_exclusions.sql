@@ -0,0 +1,7 @@
+-- Boards a user has hidden from the front page feed
+CREATE TABLE IF NOT EXISTS feed_board_exclusions (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    board_id INTEGER NOT NULL REFERENCES boards(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, board_id)
+);
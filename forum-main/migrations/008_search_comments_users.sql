@@ -0,0 +1,8 @@
+-- Comments are searchable like posts; users are looked up by username prefix.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('russian', coalesce(content, '')) ||
+    to_tsvector('english', coalesce(content, ''))
+) STORED;
+CREATE INDEX IF NOT EXISTS comments_search_idx ON comments USING GIN (search_vector);
+
+CREATE INDEX IF NOT EXISTS users_username_prefix_idx ON users (lower(username) text_pattern_ops);
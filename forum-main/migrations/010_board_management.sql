@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+DO $$ BEGIN
+    ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+-- Boards are listed by position; archived boards stay readable but take no
+-- new posts, comments or votes.
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS position INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS archived_at TIMESTAMPTZ;
+UPDATE boards SET position = id WHERE position = 0;
@@ -0,0 +1,19 @@
+-- Boards are grouped into ordered categories; a board can also sit under
+-- a parent board (one level deep).
+CREATE TABLE IF NOT EXISTS board_categories (
+    id SERIAL PRIMARY KEY,
+    title TEXT NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS category_id INTEGER REFERENCES board_categories(id) ON DELETE SET NULL;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES boards(id) ON DELETE SET NULL;
+DO $$ BEGIN
+    ALTER TABLE boards ADD CONSTRAINT boards_parent_check CHECK (parent_id <> id);
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+INSERT INTO board_categories (title, position)
+SELECT 'Общее', 1 WHERE NOT EXISTS (SELECT 1 FROM board_categories);
+UPDATE boards SET category_id = (SELECT MIN(id) FROM board_categories) WHERE category_id IS NULL;
@@ -0,0 +1,23 @@
+-- Per-board posting rules. Members are the members of the board's club; a
+-- board without a club has none, so members-only boards of that kind are
+-- effectively moderators-only.
+CREATE TABLE IF NOT EXISTS club_members (
+    club_id INTEGER NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (club_id, user_id)
+);
+
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS rules TEXT NOT NULL DEFAULT '';
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS post_permission TEXT NOT NULL DEFAULT 'everyone';
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS allow_images BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS allow_links BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS min_account_age_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS post_template TEXT NOT NULL DEFAULT '';
+DO $$ BEGIN
+    ALTER TABLE boards ADD CONSTRAINT boards_post_permission_check
+        CHECK (post_permission IN ('everyone', 'members', 'moderators'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+UPDATE boards SET post_permission = 'moderators' WHERE slug = 'news';
@@ -0,0 +1,4 @@
+-- Moderators pin announcements to the top of a board and lock threads
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS locked BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_posts_board_pinned ON posts (board_id) WHERE pinned;
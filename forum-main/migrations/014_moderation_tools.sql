@@ -0,0 +1,20 @@
+-- Old URLs of merged threads keep working
+CREATE TABLE IF NOT EXISTS post_redirects (
+    from_post_id INTEGER PRIMARY KEY,
+    to_post_id INTEGER NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_post_redirects_to ON post_redirects (to_post_id);
+
+-- Audit log of moderator actions. Post ids are not foreign keys: the log
+-- outlives the posts it mentions.
+CREATE TABLE IF NOT EXISTS mod_actions (
+    id SERIAL PRIMARY KEY,
+    moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    action TEXT NOT NULL,
+    post_id INTEGER,
+    target_id INTEGER,
+    details JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_mod_actions_created ON mod_actions (created_at DESC);
@@ -0,0 +1,22 @@
+-- Club roles, join policies, join requests and invitations
+ALTER TABLE clubs ADD COLUMN IF NOT EXISTS join_policy TEXT NOT NULL DEFAULT 'open';
+DO $$ BEGIN
+    ALTER TABLE clubs ADD CONSTRAINT clubs_join_policy_check CHECK (join_policy IN ('open', 'approval', 'invite'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+ALTER TABLE club_members ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member';
+DO $$ BEGIN
+    ALTER TABLE club_members ADD CONSTRAINT club_members_role_check CHECK (role IN ('owner', 'officer', 'member'));
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+CREATE INDEX IF NOT EXISTS idx_club_members_user ON club_members (user_id);
+
+-- A request is the user asking to join, an invite is an officer asking the user
+CREATE TABLE IF NOT EXISTS club_join_requests (
+    club_id INTEGER NOT NULL REFERENCES clubs(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('request', 'invite')),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (club_id, user_id)
+);
@@ -0,0 +1,4 @@
+-- Boards of private clubs, with their posts and comments, are visible to
+-- the club's members only
+ALTER TABLE clubs ADD COLUMN IF NOT EXISTS private BOOLEAN NOT NULL DEFAULT FALSE;
+CREATE INDEX IF NOT EXISTS idx_boards_club ON boards (club_id) WHERE club_id IS NOT NULL;
@@ -0,0 +1,35 @@
+-- Events of a club or a board, with RSVPs and calendar feed tokens
+CREATE TABLE IF NOT EXISTS events (
+    id          SERIAL PRIMARY KEY,
+    club_id     INT REFERENCES clubs(id) ON DELETE CASCADE,
+    board_id    INT REFERENCES boards(id) ON DELETE CASCADE,
+    author_id   INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title       TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    starts_at   TIMESTAMPTZ NOT NULL,
+    ends_at     TIMESTAMPTZ NOT NULL,
+    timezone    TEXT NOT NULL DEFAULT 'UTC',
+    location    TEXT NOT NULL DEFAULT '',
+    rrule       TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((club_id IS NULL) <> (board_id IS NULL)),
+    CHECK (ends_at >= starts_at)
+);
+CREATE INDEX IF NOT EXISTS idx_events_club ON events (club_id, starts_at);
+CREATE INDEX IF NOT EXISTS idx_events_board ON events (board_id, starts_at);
+CREATE INDEX IF NOT EXISTS idx_events_ends ON events (ends_at);
+
+CREATE TABLE IF NOT EXISTS event_rsvps (
+    event_id   INT NOT NULL REFERENCES events(id) ON DELETE CASCADE,
+    user_id    INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status     TEXT NOT NULL CHECK (status IN ('going', 'maybe', 'no')),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (event_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_event_rsvps_user ON event_rsvps (user_id);
+
+-- Calendar apps can't sign in, so .ics feeds are opened with a secret token
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token   TEXT NOT NULL UNIQUE
+);
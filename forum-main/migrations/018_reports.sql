@@ -0,0 +1,18 @@
+-- User reports of posts, comments and users. Target ids are not foreign
+-- keys: reports outlive the content they are about.
+CREATE TABLE IF NOT EXISTS reports (
+    id SERIAL PRIMARY KEY,
+    reporter_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'user')),
+    target_id INTEGER NOT NULL,
+    reason TEXT NOT NULL CHECK (reason IN ('spam', 'abuse', 'offtopic', 'illegal', 'other')),
+    details TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    resolution TEXT NOT NULL DEFAULT '',
+    resolved_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS idx_reports_open ON reports (target_type, target_id) WHERE status = 'open';
+-- one open report per user and target
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reports_open_reporter ON reports (reporter_id, target_type, target_id) WHERE status = 'open';
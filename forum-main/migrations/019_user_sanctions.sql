@@ -0,0 +1,21 @@
+-- Warnings, suspensions, bans and bans from a single board given by
+-- moderators, with their revocation and the moment the user has seen them
+CREATE TABLE IF NOT EXISTS user_sanctions (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('warning', 'suspension', 'ban', 'board_ban')),
+    reason TEXT NOT NULL DEFAULT '',
+    moderator_id INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    board_id INTEGER REFERENCES boards(id) ON DELETE CASCADE,
+    -- NULL never ends; suspensions always have an end
+    ends_at TIMESTAMPTZ,
+    seen_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    revoked_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT user_sanctions_scope_check
+        CHECK ((kind = 'board_ban') = (board_id IS NOT NULL) AND (kind <> 'suspension' OR ends_at IS NOT NULL))
+);
+CREATE INDEX IF NOT EXISTS idx_user_sanctions_user ON user_sanctions (user_id, created_at DESC);
+-- the write guards look up the sanctions in force for a user
+CREATE INDEX IF NOT EXISTS idx_user_sanctions_active ON user_sanctions (user_id) WHERE revoked_at IS NULL AND kind <> 'warning';
@@ -0,0 +1,35 @@
+-- Every moderator and admin action: what kind of object it targeted, the
+-- board it happened in, the moderator's reason and the object as it was
+-- before. The moderator's name is kept so entries outlive the account.
+ALTER TABLE mod_actions ADD COLUMN IF NOT EXISTS moderator_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE mod_actions ADD COLUMN IF NOT EXISTS target_type TEXT NOT NULL DEFAULT '';
+ALTER TABLE mod_actions ADD COLUMN IF NOT EXISTS board_id INTEGER;
+ALTER TABLE mod_actions ADD COLUMN IF NOT EXISTS reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE mod_actions ADD COLUMN IF NOT EXISTS snapshot JSONB;
+CREATE INDEX IF NOT EXISTS idx_mod_actions_board ON mod_actions (board_id, created_at DESC) WHERE board_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_mod_actions_moderator ON mod_actions (moderator_id, created_at DESC);
+
+-- Boards can publish a redacted log of the moderation in them
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS public_mod_log BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP TRIGGER IF EXISTS mod_actions_append_only ON mod_actions;
+UPDATE mod_actions a SET moderator_name = u.username
+FROM users u WHERE u.id = a.moderator_id AND a.moderator_name = '';
+
+-- The log is append-only. The one change allowed is the ON DELETE SET NULL
+-- of moderator_id when a moderator's account is deleted.
+CREATE OR REPLACE FUNCTION mod_actions_append_only() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND NEW.moderator_id IS NULL
+        AND (NEW.id, NEW.action, NEW.post_id, NEW.target_id, NEW.details, NEW.created_at, NEW.moderator_name,
+             NEW.target_type, NEW.board_id, NEW.reason, NEW.snapshot)
+        IS NOT DISTINCT FROM (OLD.id, OLD.action, OLD.post_id, OLD.target_id, OLD.details, OLD.created_at, OLD.moderator_name,
+             OLD.target_type, OLD.board_id, OLD.reason, OLD.snapshot) THEN
+        RETURN NEW;
+    END IF;
+    RAISE EXCEPTION 'mod_actions is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER mod_actions_append_only BEFORE UPDATE OR DELETE ON mod_actions
+    FOR EACH ROW EXECUTE FUNCTION mod_actions_append_only();
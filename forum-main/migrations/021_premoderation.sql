@@ -0,0 +1,14 @@
+-- Pre-moderation: posts and comments of new accounts wait for a moderator
+-- and are visible to their author and moderators only until approved
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS hold_reason TEXT NOT NULL DEFAULT '';
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS pending BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS hold_reason TEXT NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_posts_pending ON posts (created_at) WHERE pending;
+CREATE INDEX IF NOT EXISTS idx_comments_pending ON comments (created_at) WHERE pending;
+
+-- Per-board thresholds, 0 turns a threshold off: accounts younger than
+-- premod_account_age_days or with fewer than premod_min_approved published
+-- posts and comments are held
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS premod_account_age_days INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE boards ADD COLUMN IF NOT EXISTS premod_min_approved INTEGER NOT NULL DEFAULT 0;
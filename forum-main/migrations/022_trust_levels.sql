@@ -0,0 +1,13 @@
+-- Trust levels (0 new .. 4 leader), recalculated by a background job from
+-- account age, posts read, contributions, votes and flags received
+ALTER TABLE users ADD COLUMN IF NOT EXISTS trust_level SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS trust_updated_at TIMESTAMPTZ;
+DO $$ BEGIN
+    ALTER TABLE users ADD CONSTRAINT users_trust_level_check CHECK (trust_level BETWEEN 0 AND 4);
+EXCEPTION WHEN duplicate_object THEN NULL;
+END $$;
+
+-- post_views records the first time a signed in user opened a post
+CREATE INDEX IF NOT EXISTS idx_post_views_user ON post_views (user_id);
+CREATE INDEX IF NOT EXISTS idx_mod_actions_flags ON mod_actions (created_at)
+    WHERE action IN ('delete_post', 'delete_comment', 'resolve_reports');
@@ -0,0 +1,30 @@
+-- Bayesian spam filter learned from moderator decisions: approved posts and
+-- comments are ham, those deleted as spam are spam. spam_model is a single
+-- row with the number of examples of each kind and the last audit log
+-- entry learned from, spam_examples the tokens of each example so that a
+-- later decision on the same target replaces it.
+CREATE TABLE IF NOT EXISTS spam_model (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    spam_docs INTEGER NOT NULL DEFAULT 0,
+    ham_docs INTEGER NOT NULL DEFAULT 0,
+    last_action_id BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+INSERT INTO spam_model (id) VALUES (TRUE) ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS spam_tokens (
+    token TEXT PRIMARY KEY,
+    spam_count INTEGER NOT NULL DEFAULT 0,
+    ham_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS spam_examples (
+    target_type TEXT NOT NULL,
+    target_id INTEGER NOT NULL,
+    spam BOOLEAN NOT NULL,
+    tokens TEXT[] NOT NULL,
+    PRIMARY KEY (target_type, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_mod_actions_spam_decisions ON mod_actions (id)
+    WHERE action IN ('approve_post', 'approve_comment', 'delete_post', 'delete_comment');
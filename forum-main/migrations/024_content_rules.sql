@@ -0,0 +1,13 @@
+-- Admin rules applied to posts and comments on save: banned words, censored
+-- words, patterns that hold content for review and link domain lists
+CREATE TABLE IF NOT EXISTS content_rules (
+    id SERIAL PRIMARY KEY,
+    kind TEXT NOT NULL CHECK (kind IN ('block', 'censor', 'moderate', 'allow_domain', 'deny_domain')),
+    pattern TEXT NOT NULL,
+    regex BOOLEAN NOT NULL DEFAULT FALSE,
+    replacement TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (kind, pattern)
+);
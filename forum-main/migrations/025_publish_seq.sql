@@ -0,0 +1,69 @@
+-- Posts and comments are numbered in the order they become visible: on
+-- creation, or on approval when they were held for moderation. Saved search
+-- alerts follow this number rather than the id, so content approved after
+-- newer content was matched still raises its alert.
+CREATE SEQUENCE IF NOT EXISTS publish_seq;
+ALTER TABLE posts ADD COLUMN IF NOT EXISTS published_seq BIGINT;
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS published_seq BIGINT;
+UPDATE posts SET published_seq = nextval('publish_seq') WHERE published_seq IS NULL AND NOT pending;
+UPDATE comments SET published_seq = nextval('publish_seq') WHERE published_seq IS NULL AND NOT pending;
+CREATE INDEX IF NOT EXISTS idx_posts_published_seq ON posts (published_seq);
+CREATE INDEX IF NOT EXISTS idx_comments_published_seq ON comments (published_seq);
+
+-- Existing saved searches have seen everything published so far
+ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS last_seq BIGINT;
+UPDATE saved_searches SET last_seq = GREATEST(
+    (SELECT COALESCE(MAX(published_seq), 0) FROM posts),
+    (SELECT COALESCE(MAX(published_seq), 0) FROM comments))
+WHERE last_seq IS NULL;
+ALTER TABLE saved_searches ALTER COLUMN last_seq SET DEFAULT 0;
+ALTER TABLE saved_searches ALTER COLUMN last_seq SET NOT NULL;
+
+-- A row is numbered the first time it is stored not pending; held again by
+-- an edit and approved, it keeps its number and alerts aren't sent twice.
+-- The transaction gets its id before the number, see publish_marks
+CREATE OR REPLACE FUNCTION set_published_seq() RETURNS trigger AS $$
+BEGIN
+    IF NOT NEW.pending AND NEW.published_seq IS NULL THEN
+        PERFORM pg_current_xact_id();
+        NEW.published_seq := nextval('publish_seq');
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_published_seq ON posts;
+CREATE TRIGGER posts_published_seq BEFORE INSERT OR UPDATE OF pending ON posts
+    FOR EACH ROW EXECUTE FUNCTION set_published_seq();
+DROP TRIGGER IF EXISTS comments_published_seq ON comments;
+CREATE TRIGGER comments_published_seq BEFORE INSERT OR UPDATE OF pending ON comments
+    FOR EACH ROW EXECUTE FUNCTION set_published_seq();
+
+-- Comments on a held post aren't searchable until the post is approved,
+-- they are numbered again then
+CREATE OR REPLACE FUNCTION renumber_post_comments() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_current_xact_id();
+    UPDATE comments SET published_seq = nextval('publish_seq') WHERE post_id = NEW.id AND NOT pending;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS posts_renumber_comments ON posts;
+CREATE TRIGGER posts_renumber_comments AFTER UPDATE OF pending ON posts
+    FOR EACH ROW WHEN (OLD.published_seq IS NULL AND NEW.published_seq IS NOT NULL)
+    EXECUTE FUNCTION renumber_post_comments();
+
+-- Numbers are taken before commit, so a transaction can commit after one
+-- with a higher number was already matched. The matcher notes the last
+-- number taken with the xmax of a snapshot taken after it; every number up
+-- to it is settled once no transaction older than that xmax is running.
+CREATE TABLE IF NOT EXISTS publish_marks (
+    id BIGSERIAL PRIMARY KEY,
+    seq BIGINT NOT NULL,
+    snapshot_xmax XID8 NOT NULL
+);
+INSERT INTO publish_marks (seq, snapshot_xmax)
+SELECT CASE WHEN is_called THEN last_value ELSE 0 END, pg_snapshot_xmax(pg_current_snapshot())
+FROM publish_seq
+WHERE NOT EXISTS (SELECT 1 FROM publish_marks);
@@ -0,0 +1,18 @@
+-- Sub-boards are listed in the category of their parent. Moving a parent to
+-- another category takes its sub-boards along; this also mends sub-boards
+-- left behind by moves made before.
+UPDATE boards b SET category_id = p.category_id
+FROM boards p WHERE p.id = b.parent_id AND b.category_id IS DISTINCT FROM p.category_id;
+
+CREATE OR REPLACE FUNCTION boards_cascade_category() RETURNS trigger AS $$
+BEGIN
+    UPDATE boards SET category_id = NEW.category_id
+    WHERE parent_id = NEW.id AND category_id IS DISTINCT FROM NEW.category_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS boards_cascade_category ON boards;
+CREATE TRIGGER boards_cascade_category AFTER UPDATE OF category_id ON boards
+    FOR EACH ROW WHEN (OLD.category_id IS DISTINCT FROM NEW.category_id)
+    EXECUTE FUNCTION boards_cascade_category();
@@ -1,20 +1,67 @@
-import React, { useState } from 'react'
+import React, { useEffect, useState } from 'react'
+
+const typeLabels = { board: 'Доска', post: 'Пост', user: 'Пользователь' }
 
 export default function SearchBar({ onSearch }) {
 	const [query, setQuery] = useState('')
+	const [suggestions, setSuggestions] = useState([])
+
+	// typeahead: ask the server once the user stops typing for a moment
+	useEffect(() => {
+		if (query.trim().length < 2) {
+			setSuggestions([])
+			return
+		}
+		const controller = new AbortController()
+		const timer = setTimeout(() => {
+			fetch(`/api/search/suggest?q=${encodeURIComponent(query)}`, {
+				signal: controller.signal,
+			})
+				.then(res => (res.ok ? res.json() : []))
+				.then(setSuggestions)
+				.catch(() => {})
+		}, 200)
+		return () => {
+			clearTimeout(timer)
+			controller.abort()
+		}
+	}, [query])
 
 	const handleChange = e => {
 		setQuery(e.target.value)
-		onSearch(e.target.value)
+		if (onSearch) onSearch(e.target.value)
+	}
+
+	const handleSubmit = e => {
+		e.preventDefault()
+		if (query.trim()) {
+			window.location.href = `/search?q=${encodeURIComponent(query)}`
+		}
 	}
 
 	return (
-		<input
-			type='text'
-			placeholder='Поиск...'
-			value={query}
-			onChange={handleChange}
-			className='border p-2 rounded w-full'
-		/>
+		<form onSubmit={handleSubmit} className='relative'>
+			<input
+				type='text'
+				placeholder='Поиск...'
+				value={query}
+				onChange={handleChange}
+				className='border p-2 rounded w-full'
+			/>
+			{suggestions.length > 0 && (
+				<ul className='absolute z-10 w-full bg-white border rounded shadow'>
+					{suggestions.map(s => (
+						<li key={s.type + s.url} className='p-2 hover:bg-gray-100'>
+							<a href={s.url}>
+								<span className='text-gray-500 text-sm mr-2'>
+									{typeLabels[s.type]}
+								</span>
+								{s.label}
+							</a>
+						</li>
+					))}
+				</ul>
+			)}
+		</form>
 	)
 }
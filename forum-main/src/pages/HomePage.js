@@ -0,0 +1,54 @@
+import React, { useEffect, useState } from 'react'
+
+export default function HomePage() {
+	const [posts, setPosts] = useState([])
+	const [cursor, setCursor] = useState('')
+	const [loading, setLoading] = useState(false)
+
+	const load = async (after = '') => {
+		setLoading(true)
+		try {
+			const params = new URLSearchParams({ sort: 'hot' })
+			if (after) params.set('cursor', after)
+			const res = await fetch('/api/feed?' + params, { credentials: 'include' })
+			if (!res.ok) throw new Error('feed request failed')
+			const page = await res.json()
+			setPosts(prev => (after ? [...prev, ...page.posts] : page.posts))
+			setCursor(page.next_cursor || '')
+		} catch (_) {
+		} finally {
+			setLoading(false)
+		}
+	}
+
+	useEffect(() => {
+		load()
+	}, [])
+
+	return (
+		<div>
+			<h1 className='text-xl font-bold mb-4'>Горячее</h1>
+			<ul>
+				{posts.map(p => (
+					<li key={p.id} className='p-2 border-b'>
+						<a href={'/post/' + p.id} className='font-bold'>
+							{p.title}
+						</a>
+						<div className='text-sm text-gray-500'>
+							▲ {p.likes} ▼ {p.dislikes} · Комментарии: {p.comment_count}
+						</div>
+					</li>
+				))}
+			</ul>
+			{cursor && (
+				<button
+					className='mt-4 p-2 border rounded'
+					disabled={loading}
+					onClick={() => load(cursor)}
+				>
+					Показать ещё
+				</button>
+			)}
+		</div>
+	)
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // register GIF decoding for image.Decode
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// ImageWidth returns the width of an encoded image without decoding pixels.
+func ImageWidth(data []byte) (int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	return cfg.Width, nil
+}
+
+// ResizeImage scales an encoded JPEG, PNG or GIF image down to the given width,
+// keeping the aspect ratio. JPEG stays JPEG; everything else is encoded as PNG
+// (for GIFs only the first frame is kept).
+func ResizeImage(data []byte, width int) ([]byte, string, error) {
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+	b := src.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 82}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	if err := png.Encode(&buf, dst); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(w, h int) []byte {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+	return buf.Bytes()
+}
+
+func encodeGIF(w, h, frames int) []byte {
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, w, h), color.Palette{color.Black, color.White}))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	gif.EncodeAll(&buf, g)
+	return buf.Bytes()
+}
+
+// encodeJPEG encodes a w×h JPEG with an EXIF orientation tag, none when
+// orientation is 0
+func encodeJPEG(w, h, orientation int) []byte {
+	var buf bytes.Buffer
+	jpeg.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)), nil)
+	data := buf.Bytes()
+	if orientation == 0 {
+		return data
+	}
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08" + // big endian, IFD at 8
+		"\x00\x01" + // one entry
+		"\x01\x12\x00\x03\x00\x00\x00\x01\x00\x00\x00\x00" + // orientation, SHORT
+		"\x00\x00\x00\x00") // no next IFD
+	binary.BigEndian.PutUint16(tiff[18:], uint16(orientation))
+	seg := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(seg)+2))
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	out = append(out, seg...)
+	return append(out, data[2:]...)
+}
+
+func TestSanitizeImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		maxPixels int
+		w, h      int
+		err       error
+	}{
+		{"png", encodePNG(40, 30), 10000, 40, 30, nil},
+		{"png too large", encodePNG(200, 100), 10000, 0, 0, ErrImageTooLarge},
+		{"jpeg", encodeJPEG(40, 20, 0), 10000, 40, 20, nil},
+		{"jpeg rotated 90", encodeJPEG(40, 20, 6), 10000, 20, 40, nil},
+		{"jpeg mirrored", encodeJPEG(40, 20, 2), 10000, 40, 20, nil},
+		{"gif", encodeGIF(10, 10, 5), 500, 10, 10, nil},
+		// small frames, but together over the cap
+		{"gif too many frames", encodeGIF(10, 10, 6), 500, 0, 0, ErrImageTooLarge},
+		{"gif truncated", encodeGIF(10, 10, 2)[:40], 500, 0, 0, ErrUnsupportedImage},
+		{"not an image", []byte("hello, world"), 10000, 0, 0, ErrUnsupportedImage},
+		{"empty", nil, 10000, 0, 0, ErrUnsupportedImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, w, h, err := SanitizeImage(tt.data, tt.maxPixels)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("got error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if w != tt.w || h != tt.h {
+				t.Errorf("got %dx%d, want %dx%d", w, h, tt.w, tt.h)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(out))
+			if err != nil || cfg.Width != w || cfg.Height != h {
+				t.Errorf("output decodes as %dx%d, %v", cfg.Width, cfg.Height, err)
+			}
+			if bytes.Contains(out, []byte("Exif")) {
+				t.Error("EXIF metadata kept")
+			}
+		})
+	}
+}
+
+func TestGIFFrames(t *testing.T) {
+	for _, frames := range []int{1, 3, 20} {
+		if n, ok := gifFrames(encodeGIF(4, 4, frames)); !ok || n != frames {
+			t.Errorf("got %d frames (ok %v), want %d", n, ok, frames)
+		}
+	}
+	if _, ok := gifFrames([]byte("GIF89a")); ok {
+		t.Error("a bare header counted as a GIF")
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"html/template"
 	"net/http"
 	"os"
@@ -29,11 +30,33 @@ func ensureTemplatesBase() string {
 	return templatesBase
 }
 
-func RenderTemplate(w http.ResponseWriter, name string, data interface{}) {
+type csrfCtxKey struct{}
+
+// ContextWithCSRFToken stores the CSRF token of the session for the forms
+// rendered in the rest of the request.
+func ContextWithCSRFToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, csrfCtxKey{}, token)
+}
+
+// CSRFTokenFromContext returns the CSRF token of the session, "" for guests.
+func CSRFTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(csrfCtxKey{}).(string)
+	return token
+}
+
+// RenderTemplate renders the page in the layout. Pages put {{ csrfField }}
+// in their POST forms and send {{ csrfToken }} from scripts.
+func RenderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
 	base := ensureTemplatesBase()
 	layout := filepath.Join(base, "layout.html")
 	page := filepath.Join(base, name)
-	tmpl, err := template.ParseFiles(layout, page)
+	token := CSRFTokenFromContext(r.Context())
+	tmpl, err := template.New("layout.html").Funcs(template.FuncMap{
+		"csrfToken": func() string { return token },
+		"csrfField": func() template.HTML {
+			return template.HTML(`<input type="hidden" name="csrf" value="` + template.HTMLEscapeString(token) + `" />`)
+		},
+	}).ParseFiles(layout, page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return